@@ -53,36 +53,21 @@ func demoSparseFloatCoords() {
 	// Baseline: fixed-size encoding (2 * float64).
 	fixedBytes := len(positions) * 2 * 8
 
-	// Quantize to block centers using pure integer math, store the quantized
-	// coordinates as ints in a bounded region around the origin (e.g. +/- 64k),
-	// and then encode them as bounded ints with varfloats.
-	const bits = 12
-	const minInt, maxInt = int64(-64_000), int64(64_000)
+	// Quantize to blocks relative to a bounded world box around the origin
+	// (e.g. +/- 64k) and encode the block offsets as bounded ints with
+	// varfloats. Positions outside the box are clamped to it.
+	world := varfloat.AABB{
+		Min: varfloat.Vec3{X: -64_000, Y: -64_000},
+		Max: varfloat.Vec3{X: 64_000, Y: 64_000},
+	}
+	codec, err := varfloat.NewBoxVec3Codec(world, varfloat.Vec3{X: blockSize, Y: blockSize})
+	if err != nil {
+		panic(err)
+	}
 
 	var vfBuf []byte
-
 	for _, p := range positions {
-		// Integer block quantization.
-		qx := int64(math.Round(p.X/float64(blockSize))) * blockSize
-		qy := int64(math.Round(p.Y/float64(blockSize))) * blockSize
-
-		if qx < minInt {
-			qx = minInt
-		} else if qx > maxInt {
-			qx = maxInt
-		}
-		if qy < minInt {
-			qy = minInt
-		} else if qy > maxInt {
-			qy = maxInt
-		}
-
-		var err error
-		vfBuf, err = varfloat.AppendIntBounded(vfBuf, qx, minInt, maxInt, bits)
-		if err != nil {
-			panic(err)
-		}
-		vfBuf, err = varfloat.AppendIntBounded(vfBuf, qy, minInt, maxInt, bits)
+		vfBuf, err = codec.Append(vfBuf, varfloat.Vec3{X: p.X, Y: p.Y})
 		if err != nil {
 			panic(err)
 		}
@@ -91,24 +76,30 @@ func demoSparseFloatCoords() {
 	fmt.Println("Scenario: 10,000 position vectors in a large float64 world.")
 	fmt.Println("Premise: most users explore within a +/-2k x +/-2k gameplay region around the origin (~4k x 4k total).")
 	fmt.Printf("Fixed-size encoding (2 * float64): %d bytes\n", fixedBytes)
-	fmt.Printf("Varfloat encoding of block-quantized ints with %d bits: %d bytes\n", bits, len(vfBuf))
-	fmt.Printf("Compression vs float64: ≈ %.2fx smaller (with ≤ ~%.1f units quantization error near the origin)\n",
+	fmt.Printf("Box-relative varfloat encoding with %d-unit blocks: %d bytes\n", blockSize, len(vfBuf))
+	fmt.Printf("Compression vs float64: ≈ %.2fx smaller (with ≤ ~%.1f units quantization error inside the world box)\n",
 		float64(fixedBytes)/float64(len(vfBuf)),
-		float64(blockSize)/2)
+		codec.MaxAbsError().X)
 
-	// Show a few sample quantizations for intuition (integer block quantization).
+	// Show a few sample quantizations for intuition.
 	fmt.Println()
 	fmt.Printf("Example block quantization of a few position vectors (block size ≈ %d units):\n", blockSize)
 	fmt.Println("  (orig X,Y) -> (block-quantized X,Y) [|err| in world units]")
 
 	samplesShown := 0
 	for _, p := range positions {
-		qx := float64(int64(math.Round(p.X/float64(blockSize))) * blockSize)
-		qy := float64(int64(math.Round(p.Y/float64(blockSize))) * blockSize)
-		dx := math.Abs(qx - p.X)
-		dy := math.Abs(qy - p.Y)
+		enc, err := codec.Append(nil, varfloat.Vec3{X: p.X, Y: p.Y})
+		if err != nil {
+			panic(err)
+		}
+		q, _, err := codec.Consume(enc)
+		if err != nil {
+			panic(err)
+		}
+		dx := math.Abs(q.X - p.X)
+		dy := math.Abs(q.Y - p.Y)
 		fmt.Printf("  (%.1f, %.1f) -> (%.1f, %.1f) [|err| ≈ (%.2f, %.2f) units]\n",
-			p.X, p.Y, qx, qy, dx, dy)
+			p.X, p.Y, q.X, q.Y, dx, dy)
 		samplesShown++
 		if samplesShown >= 5 {
 			break
@@ -211,27 +202,34 @@ func demoTelemetry() {
 
 	fixedBytes := len(values) * 8 // float64
 
-	// Target max absolute error of about 0.1 units in this range.
-	// Use a relative error that is small enough over [0,500].
-	bits, err := varfloat.BitsForMaxRelError(0.0005) // 0.05%
+	// Target a max absolute error of 0.1 units over the readings' range
+	// (the noise can push them up to 0.1 outside [0,500]).
+	enc, err := varfloat.NewAbsErrorEncoder(-0.1, 500.1, 0.1)
 	if err != nil {
 		panic(err)
 	}
+	bits := enc.Bits
 
 	var vfBuf []byte
+	maxErr := 0.0
 	for _, v := range values {
-		tmp, err := varfloat.EncodeFloat(v, bits)
+		tmp, err := enc.Encode(v)
 		if err != nil {
 			panic(err)
 		}
+		dec, _, err := varfloat.DecodeFloat(tmp, bits)
+		if err != nil {
+			panic(err)
+		}
+		maxErr = math.Max(maxErr, math.Abs(dec-v))
 		vfBuf = append(vfBuf, tmp...)
 	}
 
 	fmt.Println("Scenario: 5,000 telemetry-style readings in [0,500] with small tolerated error.")
 	fmt.Printf("Fixed-size encoding (float64):           %6d bytes\n", fixedBytes)
 	fmt.Printf("Varfloat encoding with %d mantissa bits: %6d bytes\n", bits, len(vfBuf))
-	fmt.Printf("Compression vs float64: ≈ %.2fx smaller\n",
-		float64(fixedBytes)/float64(len(vfBuf)))
+	fmt.Printf("Compression vs float64: ≈ %.2fx smaller (max |err| ≈ %.4f, budget %.1f)\n",
+		float64(fixedBytes)/float64(len(vfBuf)), maxErr, enc.MaxAbsErr)
 }
 
 // demoTimeSeriesDeltas shows a time series where step-to-step changes are
@@ -0,0 +1,96 @@
+package varfloat
+
+import (
+	"errors"
+	"io"
+	"math"
+)
+
+// AdaptiveFloatStreamEncoder writes float chunks like FloatStreamEncoder, but
+// picks the mantissa bits of every chunk itself: the smallest bit count at
+// which every value of that chunk meets Goal. Quiet chunks (or chunks of
+// small integers, zeros, etc.) then cost fewer bits than the worst case the
+// caller would otherwise have to plan for.
+//
+// The bits are recorded in each chunk header as usual, so the output is a
+// normal float stream and FloatStreamDecoder reads it unchanged.
+type AdaptiveFloatStreamEncoder struct {
+	// Goal is the error budget every value must meet.
+	Goal Goal
+
+	// TryEntropy encodes each chunk both plain and entropy-coded at the
+	// chosen bits and writes whichever is smaller.
+	TryEntropy bool
+
+	// ChunkStats and ErrorStats work as for FloatStreamEncoder.
+	ChunkStats bool
+	ErrorStats *ErrorStats
+
+	w io.Writer
+}
+
+// NewAdaptiveFloatStreamEncoder creates an AdaptiveFloatStreamEncoder that
+// writes to w and keeps every value within goal.
+func NewAdaptiveFloatStreamEncoder(w io.Writer, goal Goal) (*AdaptiveFloatStreamEncoder, error) {
+	if err := goal.validate(); err != nil {
+		return nil, err
+	}
+	return &AdaptiveFloatStreamEncoder{Goal: goal, w: w}, nil
+}
+
+// WriteChunk encodes values with the fewest mantissa bits that meet the goal
+// and writes them as one chunk, returning the bits it chose. Values must be
+// finite.
+func (e *AdaptiveFloatStreamEncoder) WriteChunk(values []float64) (int, error) {
+	if err := e.Goal.validate(); err != nil {
+		return 0, err
+	}
+	bits, err := chunkBitsForGoal(values, e.Goal)
+	if err != nil {
+		return 0, err
+	}
+
+	payload, flags, err := encodeFloatsChunk(values, bits, false)
+	if err != nil {
+		return 0, err
+	}
+	if e.TryEntropy {
+		alt, altFlags, err := encodeFloatsChunk(values, bits, true)
+		if err != nil {
+			return 0, err
+		}
+		if len(alt) < len(payload) {
+			payload, flags = alt, altFlags
+		}
+	}
+
+	enc := &FloatStreamEncoder{w: e.w, ChunkStats: e.ChunkStats, ErrorStats: e.ErrorStats}
+	if err := enc.writeEncoded(values, bits, flags, payload); err != nil {
+		return 0, err
+	}
+	return bits, nil
+}
+
+// chunkBitsForGoal returns the smallest mantissa bits at which every value
+// decodes within goal.
+func chunkBitsForGoal(values []float64, goal Goal) (int, error) {
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return 0, errors.New("varfloat: adaptive encoding requires finite values")
+		}
+	}
+	for bits := 0; bits <= 52; bits++ {
+		c := Config{MantissaBits: bits}
+		ok := true
+		for _, v := range values {
+			if !goal.met(v, c.quantize(v)) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return bits, nil
+		}
+	}
+	return 0, errors.New("varfloat: no mantissa bit count meets the goal")
+}
@@ -0,0 +1,114 @@
+package varfloat
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"testing"
+)
+
+func TestAdaptiveFloatStream(t *testing.T) {
+	chunks := [][]float64{
+		{0, 0, 0},
+		{1, 2, 4, -8},
+		{1013.25, 1013.5, 1012.75},
+		sampleFloats(100, 51),
+		nil,
+	}
+	for _, goal := range []Goal{{MaxRelError: 1e-4}, {MaxAbsError: 0.01}, {MaxAbsError: 1, MaxRelError: 1e-3}} {
+		for _, tryEntropy := range []bool{false, true} {
+			var buf bytes.Buffer
+			enc, err := NewAdaptiveFloatStreamEncoder(&buf, goal)
+			if err != nil {
+				t.Fatal(err)
+			}
+			enc.TryEntropy = tryEntropy
+			var chosen []int
+			for _, values := range chunks {
+				bits, err := enc.WriteChunk(values)
+				if err != nil {
+					t.Fatal(err)
+				}
+				chosen = append(chosen, bits)
+			}
+			if chosen[0] != 0 || chosen[1] != 0 || chosen[4] != 0 {
+				t.Errorf("%+v: zeros, powers of two and an empty chunk took %v bits", goal, chosen)
+			}
+
+			dec := NewFloatStreamDecoder(&buf)
+			for i, values := range chunks {
+				got, bits, err := dec.ReadChunk()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if bits != chosen[i] || len(got) != len(values) {
+					t.Fatalf("%+v chunk %d: read %d values at %d bits, wrote %d at %d", goal, i, len(got), bits, len(values), chosen[i])
+				}
+				for j, v := range values {
+					if !goal.met(v, got[j]) {
+						t.Errorf("%+v chunk %d: %g decoded as %g", goal, i, v, got[j])
+					}
+				}
+				// One bit fewer must miss the goal somewhere.
+				if bits > 0 {
+					c := Config{MantissaBits: bits - 1}
+					missed := false
+					for _, v := range values {
+						missed = missed || !goal.met(v, c.quantize(v))
+					}
+					if !missed {
+						t.Errorf("%+v chunk %d: %d bits is not the fewest", goal, i, bits)
+					}
+				}
+			}
+			if _, _, err := dec.ReadChunk(); err != io.EOF {
+				t.Errorf("expected io.EOF after the last chunk, got %v", err)
+			}
+		}
+	}
+}
+
+func TestAdaptiveTryEntropy(t *testing.T) {
+	// Many repeats of a few values compress well under entropy coding.
+	values := make([]float64, 400)
+	for i := range values {
+		values[i] = float64(i%3) + 0.1
+	}
+	size := func(tryEntropy bool) int {
+		var buf bytes.Buffer
+		enc, err := NewAdaptiveFloatStreamEncoder(&buf, Goal{MaxRelError: 1e-3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		enc.TryEntropy = tryEntropy
+		if _, err := enc.WriteChunk(values); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Len()
+	}
+	if plain, best := size(false), size(true); best >= plain {
+		t.Errorf("TryEntropy wrote %d bytes, plain %d", best, plain)
+	}
+}
+
+func TestAdaptiveInvalid(t *testing.T) {
+	if _, err := NewAdaptiveFloatStreamEncoder(io.Discard, Goal{}); err == nil {
+		t.Error("empty goal accepted")
+	}
+	enc, err := NewAdaptiveFloatStreamEncoder(io.Discard, Goal{MaxAbsError: 1e-300})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := enc.WriteChunk(sampleFloats(20, 52)); err == nil {
+		t.Error("impossible goal met")
+	}
+	for _, v := range []float64{math.NaN(), math.Inf(-1)} {
+		if _, err := enc.WriteChunk([]float64{1, v}); err == nil {
+			t.Errorf("%g accepted", v)
+		}
+	}
+	enc.Goal = Goal{MaxRelError: -1}
+	if _, err := enc.WriteChunk([]float64{1}); err == nil {
+		t.Error("goal changed to an invalid one accepted")
+	}
+}
@@ -0,0 +1,223 @@
+package varfloat
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// FloatAggregate summarizes a run of decoded float values. Min and Max are
+// zero when Count is zero. A NaN value makes Min, Max and Sum NaN, however
+// the run was split into chunks.
+type FloatAggregate struct {
+	Count    int
+	Min, Max float64
+	Sum      float64
+}
+
+// Mean returns Sum/Count, or NaN if Count is zero.
+func (a FloatAggregate) Mean() float64 {
+	if a.Count == 0 {
+		return math.NaN()
+	}
+	return a.Sum / float64(a.Count)
+}
+
+// add folds one value into a.
+func (a *FloatAggregate) add(v float64) {
+	if a.Count == 0 {
+		a.Min, a.Max = v, v
+	} else {
+		a.Min = math.Min(a.Min, v)
+		a.Max = math.Max(a.Max, v)
+	}
+	a.Count++
+	a.Sum += v
+}
+
+// merge folds the aggregate o into a.
+func (a *FloatAggregate) merge(o FloatAggregate) {
+	if o.Count == 0 {
+		return
+	}
+	if a.Count == 0 {
+		*a = o
+		return
+	}
+	a.Count += o.Count
+	a.Min = math.Min(a.Min, o.Min)
+	a.Max = math.Max(a.Max, o.Max)
+	a.Sum += o.Sum
+}
+
+// chunkStatsFor returns the statistics of values as they will decode with
+// the given mantissa bits.
+func chunkStatsFor(values []float64, bits int) (FloatAggregate, error) {
+	c := Config{MantissaBits: bits}
+	var a FloatAggregate
+	for _, v := range values {
+		q, err := c.join(c.split(v))
+		if err != nil {
+			return FloatAggregate{}, err
+		}
+		a.add(q)
+	}
+	return a, nil
+}
+
+// appendChunkStats appends a chunk statistics block:
+//
+//	[uvarint count][8-byte min][8-byte max][8-byte sum]
+//
+// with the floats stored as big-endian IEEE 754 float64s.
+func appendChunkStats(dst []byte, a FloatAggregate) []byte {
+	dst = binary.AppendUvarint(dst, uint64(a.Count))
+	dst = append(dst, EncodeFloat64Fixed(a.Min)...)
+	dst = append(dst, EncodeFloat64Fixed(a.Max)...)
+	return append(dst, EncodeFloat64Fixed(a.Sum)...)
+}
+
+// readChunkStats reads a chunk statistics block from r, returning it and the
+// number of bytes read.
+func readChunkStats(r *bufio.Reader) (FloatAggregate, int, error) {
+	cr := countingByteReader{r: r}
+	count, err := binary.ReadUvarint(&cr)
+	if err != nil {
+		return FloatAggregate{}, 0, noEOF(err)
+	}
+	if count > math.MaxInt32 {
+		return FloatAggregate{}, 0, errors.New("varfloat: invalid chunk stats count")
+	}
+	var buf [24]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return FloatAggregate{}, 0, noEOF(err)
+	}
+	a := FloatAggregate{Count: int(count)}
+	a.Min, _, _ = DecodeFloat64Fixed(buf[0:8])
+	a.Max, _, _ = DecodeFloat64Fixed(buf[8:16])
+	a.Sum, _, _ = DecodeFloat64Fixed(buf[16:24])
+	return a, cr.n + len(buf), nil
+}
+
+// countingByteReader counts the bytes read through it, so a uvarint written
+// with redundant continuation bytes is measured by what it took up.
+type countingByteReader struct {
+	r *bufio.Reader
+	n int
+}
+
+func (c *countingByteReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
+
+// parseChunkStats splits a chunk statistics block off the front of buf.
+func parseChunkStats(buf []byte) (FloatAggregate, []byte, error) {
+	count, n := binary.Uvarint(buf)
+	if n <= 0 || count > math.MaxInt32 {
+		return FloatAggregate{}, nil, errors.New("varfloat: invalid chunk stats count")
+	}
+	if len(buf)-n < 24 {
+		return FloatAggregate{}, nil, io.ErrUnexpectedEOF
+	}
+	a := FloatAggregate{Count: int(count)}
+	a.Min, _, _ = DecodeFloat64Fixed(buf[n : n+8])
+	a.Max, _, _ = DecodeFloat64Fixed(buf[n+8 : n+16])
+	a.Sum, _, _ = DecodeFloat64Fixed(buf[n+16 : n+24])
+	return a, buf[n+24:], nil
+}
+
+// Aggregate returns the count, min, max and sum of the decoded values with
+// stream indexes in [start, end), where index 0 is the first value of the
+// first chunk.
+//
+// Chunks written with ChunkStats that lie entirely inside or outside the
+// range are answered from their statistics and skipped without decoding;
+// only chunks that straddle start or end (or have no statistics) are decoded.
+//
+// The decoder reads forward: start must not be before the end of the values
+// already returned by ReadChunk or covered by a previous Aggregate, so
+// consecutive windows can be queried in order. Values of a straddling chunk
+// past end are kept for the next Aggregate or ReadChunk call. If the stream
+// ends before end, the range is truncated to the end of the stream.
+func (d *FloatStreamDecoder) Aggregate(start, end int) (FloatAggregate, error) {
+	if start < d.pos {
+		return FloatAggregate{}, errors.New("varfloat: aggregate range starts before the decoder position")
+	}
+	if end < start {
+		return FloatAggregate{}, errors.New("varfloat: aggregate range end must be >= start")
+	}
+
+	var agg FloatAggregate
+	if len(d.pending) > 0 {
+		d.pending = d.aggregateValues(&agg, d.pending, start, end)
+	}
+	for d.pos < end {
+		bits, flags, byteLen, err := readChunkHeader(d.r, floatStreamFlags)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return FloatAggregate{}, err
+		}
+
+		if flags&chunkFlagStats != 0 {
+			stats, n, err := readChunkStats(d.r)
+			if err != nil {
+				return FloatAggregate{}, err
+			}
+			if uint64(n) > byteLen {
+				return FloatAggregate{}, errors.New("varfloat: chunk stats exceed chunk length")
+			}
+			rest := byteLen - uint64(n)
+			lo, hi := d.pos, d.pos+stats.Count
+			if hi <= start || lo >= start && hi <= end {
+				if _, err := io.CopyN(io.Discard, d.r, int64(min(rest, math.MaxInt64))); err != nil {
+					return FloatAggregate{}, noEOF(err)
+				}
+				if lo >= start {
+					agg.merge(stats)
+				}
+				d.pos = hi
+				continue
+			}
+			byteLen = rest
+		}
+
+		buf, err := readPayload(d.r, byteLen)
+		if err != nil {
+			return FloatAggregate{}, err
+		}
+		var values []float64
+		if byteLen > 0 {
+			values, err = decodeFloatsChunk(buf, bits, flags)
+			if err != nil {
+				return FloatAggregate{}, err
+			}
+		}
+		d.pending, d.pendingBits = d.aggregateValues(&agg, values, start, end), bits
+	}
+	return agg, nil
+}
+
+// aggregateValues folds the values in [start, end) of a run starting at the
+// decoder position into agg, advances the position past every value before
+// end, and returns the values that are left.
+func (d *FloatStreamDecoder) aggregateValues(agg *FloatAggregate, values []float64, start, end int) []float64 {
+	i := 0
+	for ; i < len(values) && d.pos < end; i++ {
+		if d.pos >= start {
+			agg.add(values[i])
+		}
+		d.pos++
+	}
+	if i == len(values) {
+		return nil
+	}
+	return values[i:]
+}
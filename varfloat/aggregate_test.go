@@ -0,0 +1,144 @@
+package varfloat
+
+import (
+	"bufio"
+	"bytes"
+	"math"
+	"slices"
+	"testing"
+)
+
+// writeTestStream writes chunks of sampleFloats with varying sizes and bits and
+// returns the stream, the values it decodes to and the offset of the end of
+// each chunk.
+func writeTestStream(t *testing.T, stats bool) ([]byte, []float64, []int) {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := NewFloatStreamEncoder(&buf)
+	enc.ChunkStats = stats
+	var decoded []float64
+	var ends []int
+	for i, n := range []int{5, 0, 17, 1, 40, 9} {
+		values := sampleFloats(n, uint64(i))
+		bits := 4 + 6*i
+		if err := enc.WriteChunk(values, bits); err != nil {
+			t.Fatal(err)
+		}
+		c := Config{MantissaBits: bits}
+		for _, v := range values {
+			decoded = append(decoded, c.quantize(v))
+		}
+		ends = append(ends, buf.Len())
+	}
+	return buf.Bytes(), decoded, ends
+}
+
+func aggregateOf(values []float64) FloatAggregate {
+	var a FloatAggregate
+	for _, v := range values {
+		a.add(v)
+	}
+	return a
+}
+
+func TestAggregateMatchesDecode(t *testing.T) {
+	windows := [][2]int{{0, 0}, {0, 5}, {0, 72}, {3, 30}, {22, 23}, {22, 63}, {63, 72}, {50, 500}, {72, 80}}
+	for _, stats := range []bool{false, true} {
+		stream, decoded, _ := writeTestStream(t, stats)
+		for _, w := range windows {
+			d := NewFloatStreamDecoder(bytes.NewReader(stream))
+			got, err := d.Aggregate(w[0], w[1])
+			if err != nil {
+				t.Fatalf("stats=%v %v: %v", stats, w, err)
+			}
+			lo, hi := min(w[0], len(decoded)), min(w[1], len(decoded))
+			want := aggregateOf(decoded[lo:hi])
+			if got.Count != want.Count || got.Min != want.Min || got.Max != want.Max ||
+				math.Abs(got.Sum-want.Sum) > 1e-9*math.Abs(want.Sum) {
+				t.Errorf("stats=%v %v: got %+v, want %+v", stats, w, got, want)
+			}
+		}
+	}
+}
+
+func TestAggregateThenReadChunk(t *testing.T) {
+	stream, decoded, _ := writeTestStream(t, true)
+	d := NewFloatStreamDecoder(bytes.NewReader(stream))
+	if _, err := d.Aggregate(0, 10); err != nil {
+		t.Fatal(err)
+	}
+	a, err := d.Aggregate(10, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Count != 10 {
+		t.Errorf("second window has %d values, want 10", a.Count)
+	}
+	rest, _, err := d.ReadChunk()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rest) != 2 || rest[0] != decoded[20] {
+		t.Errorf("ReadChunk after Aggregate returned %v, want the 2 values left in the chunk", rest)
+	}
+	if _, err := d.Aggregate(0, 5); err == nil {
+		t.Error("range before the decoder position accepted")
+	}
+	if _, err := d.Aggregate(30, 25); err == nil {
+		t.Error("range with end < start accepted")
+	}
+}
+
+func TestAggregateNaN(t *testing.T) {
+	values := []float64{2, math.NaN(), -1, 5}
+	whole := aggregateOf(values)
+	for split := 0; split <= len(values); split++ {
+		a, b := aggregateOf(values[:split]), aggregateOf(values[split:])
+		a.merge(b)
+		if !sameFloat(a.Min, whole.Min) || !sameFloat(a.Max, whole.Max) || a.Count != whole.Count {
+			t.Errorf("split at %d: merged %+v, whole %+v", split, a, whole)
+		}
+	}
+	if !math.IsNaN(whole.Min) || !math.IsNaN(whole.Max) || !math.IsNaN(whole.Sum) {
+		t.Errorf("NaN not propagated: %+v", whole)
+	}
+	if m := (FloatAggregate{}).Mean(); !math.IsNaN(m) {
+		t.Errorf("Mean of an empty aggregate = %g, want NaN", m)
+	}
+}
+
+func TestReadChunkStatsLength(t *testing.T) {
+	want := FloatAggregate{Count: 3, Min: -1, Max: 4, Sum: 6}
+	block := appendChunkStats(nil, want)
+	// A count of 3 written with a redundant continuation byte.
+	padded := append([]byte{0x83, 0x00}, block[1:]...)
+	for _, b := range [][]byte{block, padded} {
+		got, n, err := readChunkStats(bufio.NewReader(bytes.NewReader(b)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want || n != len(b) {
+			t.Errorf("readChunkStats = %+v, %d; want %+v, %d", got, n, want, len(b))
+		}
+	}
+}
+
+func TestAggregateCorrupt(t *testing.T) {
+	stream, _, ends := writeTestStream(t, true)
+	for i := 1; i < len(stream); i++ {
+		d := NewFloatStreamDecoder(bytes.NewReader(stream[:i]))
+		if _, err := d.Aggregate(0, 1000); err == nil && !slices.Contains(ends, i) {
+			t.Errorf("aggregating %d of %d bytes succeeded", i, len(stream))
+		}
+	}
+
+	// A chunk claiming an enormous payload must fail without allocating it.
+	huge := []byte{10 | chunkExtended, byte(chunkFlagStats), 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}
+	huge = appendChunkStats(huge, FloatAggregate{Count: 1 << 20})
+	for _, end := range []int{10, 1 << 21} {
+		d := NewFloatStreamDecoder(bytes.NewReader(huge))
+		if _, err := d.Aggregate(0, end); err == nil {
+			t.Errorf("oversized payload accepted aggregating [0, %d)", end)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package varfloat
+
+import (
+	"errors"
+	"math"
+)
+
+// FloatCodec identifies one of the float slice formats.
+type FloatCodec uint8
+
+const (
+	// CodecPlain is EncodeFloats.
+	CodecPlain FloatCodec = iota
+	// CodecRange is EncodeFloatsRange.
+	CodecRange
+	// CodecDelta is EncodeFloatsDelta.
+	CodecDelta
+	// CodecRLE is EncodeFloatsRLE.
+	CodecRLE
+	// CodecDict is EncodeFloatsDict.
+	CodecDict
+	// CodecEntropy is EncodeFloatsEntropy.
+	CodecEntropy
+)
+
+// floatCodecs lists every FloatCodec in the order Analyze tries them.
+var floatCodecs = []FloatCodec{CodecPlain, CodecRange, CodecDelta, CodecRLE, CodecDict, CodecEntropy}
+
+// String returns the codec's short name.
+func (c FloatCodec) String() string {
+	switch c {
+	case CodecPlain:
+		return "plain"
+	case CodecRange:
+		return "range"
+	case CodecDelta:
+		return "delta"
+	case CodecRLE:
+		return "rle"
+	case CodecDict:
+		return "dict"
+	case CodecEntropy:
+		return "entropy"
+	}
+	return "unknown"
+}
+
+// EncodeFloatsCodec encodes values with the given codec and bits.
+func EncodeFloatsCodec(codec FloatCodec, values []float64, bits int) ([]byte, error) {
+	switch codec {
+	case CodecPlain:
+		return EncodeFloats(values, bits)
+	case CodecRange:
+		return EncodeFloatsRange(values, bits)
+	case CodecDelta:
+		return EncodeFloatsDelta(values, bits)
+	case CodecRLE:
+		return EncodeFloatsRLE(values, bits)
+	case CodecDict:
+		return EncodeFloatsDict(values, bits)
+	case CodecEntropy:
+		return EncodeFloatsEntropy(values, bits)
+	}
+	return nil, errors.New("varfloat: unknown codec")
+}
+
+// DecodeFloatsCodec decodes a slice written by EncodeFloatsCodec with the same
+// codec and bits.
+func DecodeFloatsCodec(codec FloatCodec, b []byte, bits int) ([]float64, int, error) {
+	switch codec {
+	case CodecPlain:
+		return DecodeFloats(b, bits)
+	case CodecRange:
+		return DecodeFloatsRange(b, bits)
+	case CodecDelta:
+		return DecodeFloatsDelta(b, bits)
+	case CodecRLE:
+		return DecodeFloatsRLE(b, bits)
+	case CodecDict:
+		return DecodeFloatsDict(b, bits)
+	case CodecEntropy:
+		return DecodeFloatsEntropy(b, bits)
+	}
+	return nil, 0, errors.New("varfloat: unknown codec")
+}
+
+// Goal is an error budget for Analyze. Zero fields are unconstrained, but at
+// least one must be set.
+type Goal struct {
+	// MaxAbsError is the largest acceptable |decoded - original|.
+	MaxAbsError float64
+	// MaxRelError is the largest acceptable |decoded - original| / |original|
+	// for non-zero originals.
+	MaxRelError float64
+}
+
+// validate checks that the goal is usable.
+func (g Goal) validate() error {
+	if g.MaxAbsError < 0 || g.MaxRelError < 0 {
+		return errors.New("varfloat: goal errors must be >= 0")
+	}
+	if g.MaxAbsError == 0 && g.MaxRelError == 0 {
+		return errors.New("varfloat: goal must set MaxAbsError or MaxRelError")
+	}
+	return nil
+}
+
+// met reports whether a decoded value q of v is within the goal.
+func (g Goal) met(v, q float64) bool {
+	abs := math.Abs(q - v)
+	if g.MaxAbsError > 0 && !(abs <= g.MaxAbsError) {
+		return false
+	}
+	if g.MaxRelError > 0 && v != 0 && !(abs <= g.MaxRelError*math.Abs(v)) {
+		return false
+	}
+	return true
+}
+
+// AnalysisResult reports how one codec and bit count did on the sample data.
+type AnalysisResult struct {
+	Codec FloatCodec
+	Bits  int
+
+	Bytes         int
+	BytesPerValue float64
+
+	MaxAbsError float64
+	RMSAbsError float64
+	MaxRelError float64
+	RMSRelError float64
+
+	// MeetsGoal reports whether the errors are within the goal.
+	MeetsGoal bool
+}
+
+// Analysis is the result of Analyze.
+type Analysis struct {
+	// Results lists every configuration that was tried, grouped by codec in
+	// increasing bits.
+	Results []AnalysisResult
+	// Recommended is the smallest configuration that meets the goal, and
+	// Found reports whether there was one.
+	Recommended AnalysisResult
+	Found       bool
+}
+
+// Analyze encodes sample data with each codec (plain, range-relative, delta,
+// RLE, dictionary and entropy-coded) at increasing bit counts, decodes it
+// again and measures the size and error. For each codec it stops at the
+// first bit count that meets goal, and it recommends the smallest
+// configuration that does (fewer bits break ties).
+//
+// A codec that cannot encode the data at all, such as delta coding a series
+// whose steps overflow float64, is left out of the results.
+//
+// This replaces guessing an error budget up front with BitsForMaxRelError and
+// friends: run it on representative data and use EncodeFloatsCodec with the
+// recommended codec and bits.
+func Analyze(values []float64, goal Goal) (Analysis, error) {
+	if err := goal.validate(); err != nil {
+		return Analysis{}, err
+	}
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return Analysis{}, errors.New("varfloat: Analyze requires finite values")
+		}
+	}
+
+	var a Analysis
+	for _, codec := range floatCodecs {
+		minBits := 0
+		if codec == CodecRange {
+			minBits = 1
+		}
+		for bits := minBits; bits <= 52; bits++ {
+			r, ok, err := analyzeOne(values, codec, bits, goal)
+			if err != nil {
+				return Analysis{}, err
+			}
+			if !ok {
+				break
+			}
+			a.Results = append(a.Results, r)
+			if !r.MeetsGoal {
+				continue
+			}
+			if !a.Found || r.Bytes < a.Recommended.Bytes ||
+				r.Bytes == a.Recommended.Bytes && r.Bits < a.Recommended.Bits {
+				a.Recommended, a.Found = r, true
+			}
+			break
+		}
+	}
+	return a, nil
+}
+
+// analyzeOne measures one codec and bit count. It returns false if the codec
+// cannot encode values.
+func analyzeOne(values []float64, codec FloatCodec, bits int, goal Goal) (AnalysisResult, bool, error) {
+	buf, err := EncodeFloatsCodec(codec, values, bits)
+	if err != nil {
+		return AnalysisResult{}, false, nil
+	}
+	decoded, _, err := DecodeFloatsCodec(codec, buf, bits)
+	if err != nil {
+		return AnalysisResult{}, false, err
+	}
+
+	r := AnalysisResult{Codec: codec, Bits: bits, Bytes: len(buf)}
+	if len(values) > 0 {
+		r.BytesPerValue = float64(len(buf)) / float64(len(values))
+	}
+	r.MaxAbsError, r.MaxRelError, r.RMSAbsError = errorSummary(values, decoded)
+
+	sumSq, n := 0.0, 0
+	for i, v := range values {
+		if v != 0 {
+			rel := (decoded[i] - v) / v
+			sumSq += rel * rel
+			n++
+		}
+	}
+	if n > 0 {
+		r.RMSRelError = math.Sqrt(sumSq / float64(n))
+	}
+
+	r.MeetsGoal = (goal.MaxAbsError == 0 || r.MaxAbsError <= goal.MaxAbsError) &&
+		(goal.MaxRelError == 0 || r.MaxRelError <= goal.MaxRelError)
+	return r, true, nil
+}
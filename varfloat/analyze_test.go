@@ -0,0 +1,108 @@
+package varfloat
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFloatsCodecRoundTrip(t *testing.T) {
+	values := sampleFloats(64, 21)
+	for _, codec := range floatCodecs {
+		b, err := EncodeFloatsCodec(codec, values, 20)
+		if err != nil {
+			t.Fatalf("%v: %v", codec, err)
+		}
+		got, n, err := DecodeFloatsCodec(codec, b, 20)
+		if err != nil {
+			t.Fatalf("%v: %v", codec, err)
+		}
+		if n != len(b) || len(got) != len(values) {
+			t.Errorf("%v: decoded %d values from %d of %d bytes", codec, len(got), n, len(b))
+		}
+		if codec.String() == "unknown" {
+			t.Errorf("codec %d has no name", codec)
+		}
+	}
+	unknown := FloatCodec(len(floatCodecs))
+	if _, err := EncodeFloatsCodec(unknown, values, 20); err == nil {
+		t.Error("unknown codec encoded")
+	}
+	if _, _, err := DecodeFloatsCodec(unknown, nil, 20); err == nil {
+		t.Error("unknown codec decoded")
+	}
+	if unknown.String() != "unknown" {
+		t.Errorf("unknown codec named %q", unknown.String())
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	// A slowly drifting reading far from zero.
+	values := make([]float64, 500)
+	for i := range values {
+		values[i] = 1013 + math.Sin(float64(i)/40)
+	}
+	goals := []Goal{{MaxAbsError: 0.01}, {MaxRelError: 1e-6}, {MaxAbsError: 0.5, MaxRelError: 1e-4}}
+	for _, goal := range goals {
+		a, err := Analyze(values, goal)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !a.Found {
+			t.Fatalf("%+v: nothing meets the goal", goal)
+		}
+		for _, r := range a.Results {
+			if r.MeetsGoal && r.Bytes < a.Recommended.Bytes {
+				t.Errorf("%+v: %v at %d bits is smaller than the recommendation", goal, r.Codec, r.Bits)
+			}
+		}
+
+		// The recommendation must hold up when used.
+		rec := a.Recommended
+		b, err := EncodeFloatsCodec(rec.Codec, values, rec.Bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b) != rec.Bytes {
+			t.Errorf("%+v: %v encodes to %d bytes, analysis said %d", goal, rec.Codec, len(b), rec.Bytes)
+		}
+		got, _, err := DecodeFloatsCodec(rec.Codec, b, rec.Bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, v := range values {
+			if !goal.met(v, got[i]) {
+				t.Fatalf("%+v: %v at %d bits decodes %g as %g", goal, rec.Codec, rec.Bits, v, got[i])
+			}
+		}
+	}
+}
+
+func TestAnalyzeSkipsUnusableCodecs(t *testing.T) {
+	a, err := Analyze([]float64{1e308, -1e308, 5}, Goal{MaxRelError: 0.01})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range a.Results {
+		if r.Codec == CodecDelta || r.Codec == CodecRange {
+			t.Errorf("%v was analyzed for data it cannot encode", r.Codec)
+		}
+	}
+	if !a.Found || a.Recommended.MaxRelError > 0.01 {
+		t.Errorf("recommended %+v", a.Recommended)
+	}
+}
+
+func TestAnalyzeInvalid(t *testing.T) {
+	for _, goal := range []Goal{{}, {MaxAbsError: -1}, {MaxRelError: -1, MaxAbsError: 1}} {
+		if _, err := Analyze([]float64{1}, goal); err == nil {
+			t.Errorf("goal %+v accepted", goal)
+		}
+	}
+	if _, err := Analyze([]float64{1, math.NaN()}, Goal{MaxRelError: 0.1}); err == nil {
+		t.Error("NaN accepted")
+	}
+	a, err := Analyze(nil, Goal{MaxAbsError: 1})
+	if err != nil || !a.Found {
+		t.Errorf("Analyze(empty) = %+v, %v", a, err)
+	}
+}
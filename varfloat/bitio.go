@@ -0,0 +1,79 @@
+package varfloat
+
+import "io"
+
+// bitWriter packs values MSB-first into a byte slice. It is used by the
+// bit-packed formats (dictionary indexes, entropy codes, etc).
+type bitWriter struct {
+	buf  []byte
+	used uint // bits used in the last byte of buf (0 means byte-aligned)
+}
+
+// writeBits appends the low n bits of v (n <= 64), most significant first.
+func (w *bitWriter) writeBits(v uint64, n int) {
+	for n > 0 {
+		if w.used == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		free := int(8 - w.used)
+		take := free
+		if n < take {
+			take = n
+		}
+		chunk := byte((v >> uint(n-take)) & ((1 << uint(take)) - 1))
+		w.buf[len(w.buf)-1] |= chunk << uint(free-take)
+		w.used = (w.used + uint(take)) % 8
+		n -= take
+	}
+}
+
+// bytes returns the packed bytes. A partially filled final byte is padded
+// with zero bits.
+func (w *bitWriter) bytes() []byte {
+	return w.buf
+}
+
+// bitReader reads values written by bitWriter.
+type bitReader struct {
+	b   []byte
+	pos int // bit position
+}
+
+// readBits reads n bits (n <= 64) and returns them in the low bits of the
+// result. It returns io.ErrUnexpectedEOF if b runs out.
+func (r *bitReader) readBits(n int) (uint64, error) {
+	if r.pos+n > len(r.b)*8 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	var v uint64
+	for n > 0 {
+		byteIdx := r.pos / 8
+		off := r.pos % 8
+		avail := 8 - off
+		take := avail
+		if n < take {
+			take = n
+		}
+		chunk := (r.b[byteIdx] >> uint(avail-take)) & ((1 << uint(take)) - 1)
+		v = v<<uint(take) | uint64(chunk)
+		r.pos += take
+		n -= take
+	}
+	return v, nil
+}
+
+// packedLen returns the number of bytes needed to hold count values of width
+// bits each.
+func packedLen(count, width int) int {
+	return (count*width + 7) / 8
+}
+
+// bitsForCount returns the number of bits needed to index n distinct values,
+// i.e. ceil(log2(n)), or 0 when n <= 1.
+func bitsForCount(n int) int {
+	bits := 0
+	for (1 << uint(bits)) < n {
+		bits++
+	}
+	return bits
+}
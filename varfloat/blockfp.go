@@ -0,0 +1,212 @@
+package varfloat
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// EncodeFloatsBlockFP encodes a slice of float64 values using block floating
+// point: values are grouped into blocks of blockSize, each block stores a
+// single shared exponent, and every element stores only a sign bit plus a
+// bits-wide mantissa scaled to that exponent.
+//
+// For audio-like signals and ML activations the exponent barely changes within
+// a block, so this avoids paying for a header on every value:
+//
+//	[uvarint count][uvarint blockSize]
+//	per block: [uvarint zigzag exponent][packed (1+bits)-bit sign/magnitude...]
+//
+// The error is absolute per block rather than relative per value: an element
+// in a block whose largest magnitude is below 2^E is off by at most
+// 2^(E-bits). Small values sharing a block with large ones therefore lose
+// relative precision; use AnalyzeBlockFP to compare against per-value
+// varfloats on real data. bits must be in [1, 52] and values must be finite.
+func EncodeFloatsBlockFP(values []float64, bits, blockSize int) ([]byte, error) {
+	if bits < 1 || bits > 52 {
+		return nil, errors.New("varfloat: block floating point bits must be between 1 and 52")
+	}
+	if blockSize <= 0 {
+		return nil, errors.New("varfloat: block size must be > 0")
+	}
+
+	var buf [10]byte
+	var out []byte
+	n := binary.PutUvarint(buf[:], uint64(len(values)))
+	out = append(out, buf[:n]...)
+	n = binary.PutUvarint(buf[:], uint64(blockSize))
+	out = append(out, buf[:n]...)
+
+	maxMag := uint64(1)<<uint(bits) - 1
+	for start := 0; start < len(values); start += blockSize {
+		end := start + blockSize
+		if end > len(values) {
+			end = len(values)
+		}
+		block := values[start:end]
+
+		maxAbs := 0.0
+		for _, v := range block {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return nil, errors.New("varfloat: block floating point requires finite values")
+			}
+			if a := math.Abs(v); a > maxAbs {
+				maxAbs = a
+			}
+		}
+		// maxAbs < 2^exp, so every scaled magnitude fits in bits bits.
+		_, exp := math.Frexp(maxAbs)
+
+		n = binary.PutUvarint(buf[:], zigZagEncode(int64(exp)))
+		out = append(out, buf[:n]...)
+
+		var w bitWriter
+		for _, v := range block {
+			sign := uint64(0)
+			if v < 0 {
+				sign = 1
+			}
+			mag := uint64(math.Round(math.Ldexp(math.Abs(v), bits-exp)))
+			if mag > maxMag {
+				mag = maxMag
+			}
+			w.writeBits(sign, 1)
+			w.writeBits(mag, bits)
+		}
+		out = append(out, w.bytes()...)
+	}
+	return out, nil
+}
+
+// DecodeFloatsBlockFP decodes a slice of float64 values encoded by
+// EncodeFloatsBlockFP using the same mantissa precision (bits). The block size
+// is read from the payload. It returns the decoded values and the number of
+// bytes consumed.
+func DecodeFloatsBlockFP(b []byte, bits int) ([]float64, int, error) {
+	if bits < 1 || bits > 52 {
+		return nil, 0, errors.New("varfloat: block floating point bits must be between 1 and 52")
+	}
+
+	count, n := binary.Uvarint(b)
+	if n <= 0 {
+		return nil, 0, errors.New("varfloat: invalid slice length")
+	}
+	offset := n
+
+	blockSize, n := binary.Uvarint(b[offset:])
+	if n <= 0 || blockSize == 0 {
+		return nil, 0, errors.New("varfloat: invalid block size")
+	}
+	offset += n
+	if count > uint64(len(b)-offset)*8/uint64(1+bits) {
+		return nil, 0, errors.New("varfloat: slice length exceeds buffer")
+	}
+
+	values := make([]float64, 0, count)
+	for remaining := count; remaining > 0; {
+		size := blockSize
+		if remaining < size {
+			size = remaining
+		}
+
+		ez, n := binary.Uvarint(b[offset:])
+		if n <= 0 {
+			return nil, 0, errors.New("varfloat: invalid block exponent")
+		}
+		offset += n
+		exp := int(zigZagDecode(ez))
+
+		r := bitReader{b: b[offset:]}
+		for i := uint64(0); i < size; i++ {
+			sign, err := r.readBits(1)
+			if err != nil {
+				return nil, 0, err
+			}
+			mag, err := r.readBits(bits)
+			if err != nil {
+				return nil, 0, err
+			}
+			v := math.Ldexp(float64(mag), exp-bits)
+			if sign == 1 {
+				v = -v
+			}
+			values = append(values, v)
+		}
+		offset += packedLen(int(size), 1+bits)
+		remaining -= size
+	}
+	return values, offset, nil
+}
+
+// BlockFPAnalysis compares block floating point against per-value varfloats
+// for the same data and mantissa precision. Relative errors ignore values that
+// are exactly zero.
+type BlockFPAnalysis struct {
+	BlockFPBytes  int
+	VarfloatBytes int
+
+	BlockFPMaxAbsErr  float64
+	VarfloatMaxAbsErr float64
+
+	BlockFPMaxRelErr  float64
+	VarfloatMaxRelErr float64
+
+	BlockFPRMSErr  float64
+	VarfloatRMSErr float64
+}
+
+// AnalyzeBlockFP encodes values both with EncodeFloatsBlockFP and with
+// EncodeFloats, decodes them again, and reports the size and error of each.
+//
+// Block floating point usually wins on size when magnitudes within a block are
+// similar, and loses on relative error for small values that share a block
+// with much larger ones.
+func AnalyzeBlockFP(values []float64, bits, blockSize int) (BlockFPAnalysis, error) {
+	var a BlockFPAnalysis
+
+	blockBuf, err := EncodeFloatsBlockFP(values, bits, blockSize)
+	if err != nil {
+		return a, err
+	}
+	blockVals, _, err := DecodeFloatsBlockFP(blockBuf, bits)
+	if err != nil {
+		return a, err
+	}
+
+	vfBuf, err := EncodeFloats(values, bits)
+	if err != nil {
+		return a, err
+	}
+	vfVals, _, err := DecodeFloats(vfBuf, bits)
+	if err != nil {
+		return a, err
+	}
+
+	a.BlockFPBytes = len(blockBuf)
+	a.VarfloatBytes = len(vfBuf)
+	a.BlockFPMaxAbsErr, a.BlockFPMaxRelErr, a.BlockFPRMSErr = errorSummary(values, blockVals)
+	a.VarfloatMaxAbsErr, a.VarfloatMaxRelErr, a.VarfloatRMSErr = errorSummary(values, vfVals)
+	return a, nil
+}
+
+// errorSummary returns the max absolute error, max relative error and RMS
+// absolute error of decoded against orig.
+func errorSummary(orig, decoded []float64) (maxAbs, maxRel, rms float64) {
+	sumSq := 0.0
+	for i, v := range orig {
+		d := math.Abs(decoded[i] - v)
+		if d > maxAbs {
+			maxAbs = d
+		}
+		if v != 0 {
+			if r := d / math.Abs(v); r > maxRel {
+				maxRel = r
+			}
+		}
+		sumSq += d * d
+	}
+	if len(orig) > 0 {
+		rms = math.Sqrt(sumSq / float64(len(orig)))
+	}
+	return maxAbs, maxRel, rms
+}
@@ -0,0 +1,100 @@
+package varfloat
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFloatsBlockFPErrorBound(t *testing.T) {
+	sine := make([]float64, 300)
+	for i := range sine {
+		sine[i] = math.Sin(float64(i)/10) * 0.8
+	}
+	tests := []struct {
+		name   string
+		values []float64
+	}{
+		{"empty", nil},
+		{"zeros", []float64{0, 0, 0, 0}},
+		{"sine", sine},
+		{"mixed magnitudes", []float64{1e6, 1e-6, -3, 0, 250, -1e6}},
+		{"random", sampleFloats(257, 5)},
+	}
+	for _, tt := range tests {
+		for _, bits := range []int{1, 8, 16, 52} {
+			for _, blockSize := range []int{1, 16, 64} {
+				b, err := EncodeFloatsBlockFP(tt.values, bits, blockSize)
+				if err != nil {
+					t.Fatalf("%s/%d/%d: %v", tt.name, bits, blockSize, err)
+				}
+				got, n, err := DecodeFloatsBlockFP(b, bits)
+				if err != nil {
+					t.Fatalf("%s/%d/%d: %v", tt.name, bits, blockSize, err)
+				}
+				if n != len(b) || len(got) != len(tt.values) {
+					t.Fatalf("%s/%d/%d: %d values in %d of %d bytes", tt.name, bits, blockSize, len(got), n, len(b))
+				}
+				for start := 0; start < len(tt.values); start += blockSize {
+					end := min(start+blockSize, len(tt.values))
+					maxAbs := 0.0
+					for _, v := range tt.values[start:end] {
+						maxAbs = max(maxAbs, math.Abs(v))
+					}
+					_, e := math.Frexp(maxAbs)
+					bound := math.Ldexp(1, e-bits)
+					for i := start; i < end; i++ {
+						if d := math.Abs(got[i] - tt.values[i]); d > bound {
+							t.Errorf("%s/%d/%d: %g decoded as %g, error %g > %g", tt.name, bits, blockSize, tt.values[i], got[i], d, bound)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestFloatsBlockFPInvalid(t *testing.T) {
+	for _, v := range []float64{math.Inf(1), math.NaN()} {
+		if _, err := EncodeFloatsBlockFP([]float64{1, v}, 8, 4); err == nil {
+			t.Errorf("%g accepted", v)
+		}
+	}
+	if _, err := EncodeFloatsBlockFP([]float64{1}, 0, 4); err == nil {
+		t.Error("0 bits accepted")
+	}
+	if _, err := EncodeFloatsBlockFP([]float64{1}, 8, 0); err == nil {
+		t.Error("block size 0 accepted")
+	}
+
+	b, err := EncodeFloatsBlockFP(sampleFloats(40, 6), 10, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTruncated(t, b, func(b []byte) error {
+		_, _, err := DecodeFloatsBlockFP(b, 10)
+		return err
+	})
+	if _, _, err := DecodeFloatsBlockFP([]byte{0xff, 0xff, 0xff, 0xff, 0x0f, 1, 0}, 10); err == nil {
+		t.Error("oversized count accepted")
+	}
+}
+
+func TestAnalyzeBlockFP(t *testing.T) {
+	values := make([]float64, 1024)
+	for i := range values {
+		values[i] = math.Sin(float64(i)/7) * (1 + float64(i%50)/100)
+	}
+	a, err := AnalyzeBlockFP(values, 10, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.BlockFPBytes >= a.VarfloatBytes {
+		t.Errorf("block FP %d bytes, varfloat %d bytes", a.BlockFPBytes, a.VarfloatBytes)
+	}
+	if a.VarfloatMaxRelErr > MaxRelErrorForBits(10) {
+		t.Errorf("varfloat relative error %g", a.VarfloatMaxRelErr)
+	}
+	if a.BlockFPMaxAbsErr > math.Ldexp(1, 1-10) || a.BlockFPRMSErr > a.BlockFPMaxAbsErr {
+		t.Errorf("block FP errors %+v", a)
+	}
+}
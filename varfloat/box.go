@@ -0,0 +1,201 @@
+package varfloat
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// AABB is an axis-aligned bounding box.
+type AABB struct {
+	Min, Max Vec3
+}
+
+// BoxVec3Codec encodes positions that are known to lie inside an AABB, such
+// as positions within a game level.
+//
+// EncodeVec3 spends exponent bits on every component even though the range of
+// each axis is known up front. BoxVec3Codec instead quantizes each component
+// to a grid of Step units anchored at Box.Min and writes the grid offset as a
+// range-relative bounded int (AppendIntAuto over [0, steps]). Axes where the
+// box has zero extent are not written at all, so a 2D position can use a flat
+// box.
+//
+// Components outside the box are clamped to it. Inside the box each component
+// is off by at most Step/2 on that axis (see MaxAbsError).
+//
+// If ErrorStats is set, every encoded component is recorded in it, and
+// components that had to be clamped are counted.
+type BoxVec3Codec struct {
+	Box  AABB
+	Step Vec3
+
+	ErrorStats *ErrorStats
+}
+
+// NewBoxVec3Codec creates a BoxVec3Codec with an explicit per-axis step.
+// Every axis with a non-zero extent needs a positive step.
+func NewBoxVec3Codec(box AABB, step Vec3) (*BoxVec3Codec, error) {
+	c := &BoxVec3Codec{Box: box, Step: step}
+	mins, maxs, steps := c.axes()
+	for i := range mins {
+		if math.IsNaN(mins[i]) || math.IsInf(mins[i], 0) || math.IsNaN(maxs[i]) || math.IsInf(maxs[i], 0) {
+			return nil, errors.New("varfloat: box bounds must be finite")
+		}
+		if mins[i] > maxs[i] {
+			return nil, errors.New("varfloat: box min must be <= max")
+		}
+		if mins[i] == maxs[i] {
+			continue
+		}
+		if !(steps[i] > 0) || math.IsInf(steps[i], 0) {
+			return nil, errors.New("varfloat: box step must be > 0")
+		}
+		if math.Ceil((maxs[i]-mins[i])/steps[i]) > 1<<52 {
+			return nil, errors.New("varfloat: box step is too small for its extent")
+		}
+	}
+	return c, nil
+}
+
+// NewBoxVec3CodecMaxError creates a BoxVec3Codec whose components are off by
+// at most maxAbsErr world units inside the box, on every axis.
+func NewBoxVec3CodecMaxError(box AABB, maxAbsErr float64) (*BoxVec3Codec, error) {
+	if !(maxAbsErr > 0) {
+		return nil, errors.New("varfloat: maxAbsErr must be > 0")
+	}
+	step := 2 * maxAbsErr
+	return NewBoxVec3Codec(box, Vec3{X: step, Y: step, Z: step})
+}
+
+// MaxAbsError returns the largest per-axis error for positions inside the box.
+func (c *BoxVec3Codec) MaxAbsError() Vec3 {
+	mins, maxs, steps := c.axes()
+	var errs [3]float64
+	for i := range errs {
+		if mins[i] != maxs[i] {
+			errs[i] = steps[i] / 2
+		}
+	}
+	return Vec3{X: errs[0], Y: errs[1], Z: errs[2]}
+}
+
+// Append encodes v, clamped to the box, and appends it to dst.
+func (c *BoxVec3Codec) Append(dst []byte, v Vec3) ([]byte, error) {
+	mins, maxs, steps := c.axes()
+	comps := [3]float64{v.X, v.Y, v.Z}
+	var quant [3]float64
+	for i, x := range comps {
+		if mins[i] == maxs[i] {
+			quant[i] = mins[i]
+			continue
+		}
+		if math.IsNaN(x) {
+			return nil, errors.New("varfloat: cannot encode NaN component")
+		}
+		n := boxSteps(mins[i], maxs[i], steps[i])
+		q := int64(math.Round((x - mins[i]) / steps[i]))
+		if x <= mins[i] {
+			q = 0
+		} else if x >= maxs[i] || q > n {
+			q = n
+		}
+		var err error
+		dst, err = AppendIntAuto(dst, q, 0, n)
+		if err != nil {
+			return nil, err
+		}
+		quant[i] = math.Min(mins[i]+float64(q)*steps[i], maxs[i])
+	}
+	for i, x := range comps {
+		c.ErrorStats.observe(x, quant[i], x < mins[i] || x > maxs[i])
+	}
+	return dst, nil
+}
+
+// Consume decodes a position written by Append from the beginning of b. It
+// returns the decoded position and the number of bytes consumed.
+func (c *BoxVec3Codec) Consume(b []byte) (Vec3, int, error) {
+	mins, maxs, steps := c.axes()
+	var comps [3]float64
+	offset := 0
+	for i := range comps {
+		if mins[i] == maxs[i] {
+			comps[i] = mins[i]
+			continue
+		}
+		n := boxSteps(mins[i], maxs[i], steps[i])
+		q, used, err := ConsumeIntAuto(b[offset:], 0, n)
+		if err != nil {
+			return Vec3{}, 0, err
+		}
+		offset += used
+		comps[i] = math.Min(mins[i]+float64(q)*steps[i], maxs[i])
+	}
+	return Vec3{X: comps[0], Y: comps[1], Z: comps[2]}, offset, nil
+}
+
+// EncodeSlice encodes a slice of positions with a uvarint length prefix.
+//
+// If every axis of the box is flat, positions take no bytes and nothing in
+// the input bounds the count; such slices are limited to 1<<24 positions.
+func (c *BoxVec3Codec) EncodeSlice(vs []Vec3) ([]byte, error) {
+	if mins, maxs, _ := c.axes(); mins == maxs && len(vs) > maxImpliedValues {
+		return nil, errors.New("varfloat: too many positions for a flat box")
+	}
+	var buf [10]byte
+	n := binary.PutUvarint(buf[:], uint64(len(vs)))
+	out := append([]byte(nil), buf[:n]...)
+	for _, v := range vs {
+		var err error
+		out, err = c.Append(out, v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// DecodeSlice decodes a slice of positions written by EncodeSlice.
+func (c *BoxVec3Codec) DecodeSlice(b []byte) ([]Vec3, int, error) {
+	count, n := binary.Uvarint(b)
+	if n <= 0 {
+		return nil, 0, errors.New("varfloat: invalid slice length")
+	}
+	offset := n
+	mins, maxs, _ := c.axes()
+	if mins != maxs && count > uint64(len(b)-offset) {
+		// Every position with a non-flat axis takes at least one byte.
+		return nil, 0, errors.New("varfloat: slice length exceeds buffer")
+	}
+	if mins == maxs && count > maxImpliedValues {
+		// Positions in a flat box take no bytes, so the input does not
+		// bound the count.
+		return nil, 0, errors.New("varfloat: slice length is too large")
+	}
+
+	var out []Vec3
+	for i := uint64(0); i < count; i++ {
+		v, used, err := c.Consume(b[offset:])
+		if err != nil {
+			return nil, 0, err
+		}
+		out = append(out, v)
+		offset += used
+	}
+	return out, offset, nil
+}
+
+// axes returns the box bounds and steps as per-axis arrays.
+func (c *BoxVec3Codec) axes() (mins, maxs, steps [3]float64) {
+	mins = [3]float64{c.Box.Min.X, c.Box.Min.Y, c.Box.Min.Z}
+	maxs = [3]float64{c.Box.Max.X, c.Box.Max.Y, c.Box.Max.Z}
+	steps = [3]float64{c.Step.X, c.Step.Y, c.Step.Z}
+	return mins, maxs, steps
+}
+
+// boxSteps returns the largest grid offset for an axis, i.e. the number of
+// steps needed to cover [min, max].
+func boxSteps(min, max, step float64) int64 {
+	return int64(math.Ceil((max - min) / step))
+}
@@ -0,0 +1,140 @@
+package varfloat
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestBoxVec3MaxAbsError(t *testing.T) {
+	box := AABB{Min: Vec3{X: -2000, Y: -2000, Z: -10}, Max: Vec3{X: 2000, Y: 2000, Z: 500}}
+	tests := []struct {
+		name  string
+		codec func() (*BoxVec3Codec, error)
+	}{
+		{"max error 0.01", func() (*BoxVec3Codec, error) { return NewBoxVec3CodecMaxError(box, 0.01) }},
+		{"max error 1", func() (*BoxVec3Codec, error) { return NewBoxVec3CodecMaxError(box, 1) }},
+		{"per-axis step", func() (*BoxVec3Codec, error) {
+			return NewBoxVec3Codec(box, Vec3{X: 0.5, Y: 3, Z: 0.001})
+		}},
+		{"uneven step", func() (*BoxVec3Codec, error) {
+			return NewBoxVec3Codec(box, Vec3{X: 3.7, Y: 3.7, Z: 3.7})
+		}},
+	}
+	vs := []Vec3{box.Min, box.Max, {}, {X: 1999.99, Y: -1999.99, Z: 499.99}}
+	r := rand.New(rand.NewPCG(12, 12))
+	for range 300 {
+		vs = append(vs, Vec3{X: r.Float64()*4000 - 2000, Y: r.Float64()*4000 - 2000, Z: r.Float64()*510 - 10})
+	}
+	for _, tt := range tests {
+		c, err := tt.codec()
+		if err != nil {
+			t.Fatal(err)
+		}
+		bound := c.MaxAbsError()
+		b, err := c.EncodeSlice(vs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, n, err := c.DecodeSlice(b)
+		if err != nil || n != len(b) || len(got) != len(vs) {
+			t.Fatalf("%s: %d positions in %d of %d bytes, %v", tt.name, len(got), n, len(b), err)
+		}
+		for i, v := range vs {
+			if math.Abs(got[i].X-v.X) > bound.X || math.Abs(got[i].Y-v.Y) > bound.Y || math.Abs(got[i].Z-v.Z) > bound.Z {
+				t.Errorf("%s: %v decoded as %v, bound %v", tt.name, v, got[i], bound)
+			}
+		}
+	}
+}
+
+func TestBoxVec3ClampAndFlat(t *testing.T) {
+	stats := &ErrorStats{}
+	c, err := NewBoxVec3Codec(AABB{Min: Vec3{X: 0, Y: 5}, Max: Vec3{X: 10, Y: 5}}, Vec3{X: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.ErrorStats = stats
+
+	tests := []struct {
+		v, want Vec3
+	}{
+		{Vec3{X: 3.2, Y: 5}, Vec3{X: 3, Y: 5}},
+		{Vec3{X: -4, Y: 7, Z: 1}, Vec3{X: 0, Y: 5}},
+		{Vec3{X: 99, Y: 5}, Vec3{X: 10, Y: 5}},
+	}
+	for _, tt := range tests {
+		b, err := c.Append(nil, tt.v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _, err := c.Consume(b)
+		if err != nil || got != tt.want {
+			t.Errorf("%v decoded as %v, want %v (%v)", tt.v, got, tt.want, err)
+		}
+	}
+	// The second vector is outside the box on all three axes.
+	if r := stats.Report(); r.Count != 9 || r.Clamped != 4 {
+		t.Errorf("stats: %d values, %d clamped; want 9 and 4", r.Count, r.Clamped)
+	}
+	if _, err := c.Append(nil, Vec3{X: math.NaN()}); err == nil {
+		t.Error("NaN accepted")
+	}
+
+	// With every axis flat, positions take no bytes and only the fixed cap
+	// bounds the count.
+	point, err := NewBoxVec3Codec(AABB{Min: Vec3{X: 1, Y: 2, Z: 3}, Max: Vec3{X: 1, Y: 2, Z: 3}}, Vec3{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := point.EncodeSlice(make([]Vec3, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, n, err := point.DecodeSlice(b)
+	if err != nil || n != 1 || len(got) != 3 || got[0] != (Vec3{X: 1, Y: 2, Z: 3}) {
+		t.Errorf("flat box: %v, %d, %v", got, n, err)
+	}
+	if _, _, err := point.DecodeSlice([]byte{0x80, 0x80, 0x80, 0x80, 0x10}); err == nil {
+		t.Error("flat box: 2^32 positions accepted")
+	}
+}
+
+func TestBoxVec3Invalid(t *testing.T) {
+	box := AABB{Max: Vec3{X: 1, Y: 1, Z: 1}}
+	bad := []struct {
+		name string
+		box  AABB
+		step Vec3
+	}{
+		{"inverted", AABB{Min: Vec3{X: 2}, Max: Vec3{X: 1}}, Vec3{X: 1}},
+		{"infinite", AABB{Max: Vec3{X: math.Inf(1)}}, Vec3{X: 1}},
+		{"zero step", box, Vec3{X: 1, Y: 0, Z: 1}},
+		{"tiny step", box, Vec3{X: 1e-300, Y: 1, Z: 1}},
+	}
+	for _, tt := range bad {
+		if _, err := NewBoxVec3Codec(tt.box, tt.step); err == nil {
+			t.Errorf("%s accepted", tt.name)
+		}
+	}
+
+	c, err := NewBoxVec3CodecMaxError(box, 0.001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := c.EncodeSlice([]Vec3{{X: 0.5, Y: 0.25, Z: 1}, {X: 0.1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTruncated(t, b, func(b []byte) error {
+		_, _, err := c.DecodeSlice(b)
+		return err
+	})
+	if _, _, err := c.DecodeSlice([]byte{0xff, 0xff, 0xff, 0xff, 0x0f}); err == nil {
+		t.Error("oversized count accepted")
+	}
+	empty, _ := c.EncodeSlice(nil)
+	if got, n, err := c.DecodeSlice(empty); err != nil || len(got) != 0 || n != len(empty) {
+		t.Errorf("empty slice: %v, %d, %v", got, n, err)
+	}
+}
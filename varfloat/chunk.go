@@ -0,0 +1,123 @@
+package varfloat
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// Stream chunks share a common header:
+//
+//	[1-byte header][uvarint flags][uvarint byteLen][payload...]
+//
+// The low 7 bits of the header byte hold the mantissa bits. The flags uvarint
+// is only present when the high bit (chunkExtended) is set, so chunks without
+// flags are byte-for-byte identical to the original
+// [1-byte mantissa bits][uvarint byteLen][payload] layout.
+const chunkExtended = 0x80
+
+// Chunk flags describing how a chunk payload was encoded.
+const (
+	// chunkFlagEntropy marks a payload written by EncodeFloatsEntropy instead
+	// of EncodeFloats.
+	chunkFlagEntropy uint64 = 1 << iota
+
+	// chunkFlagSharedExponent marks a Vec3 payload written by
+	// EncodeVec3SliceShared.
+	chunkFlagSharedExponent
+
+	// chunkFlagStats marks a payload that starts with a chunk statistics
+	// block (see appendChunkStats).
+	chunkFlagStats
+)
+
+// writeChunk writes a single chunk with the given mantissa bits and flags.
+func writeChunk(w io.Writer, bits int, flags uint64, payload []byte) error {
+	if bits < 0 || bits > 52 {
+		return errors.New("varfloat: mantissa bits must be between 0 and 52")
+	}
+
+	var buf [10]byte
+	header := make([]byte, 0, 21)
+	if flags != 0 {
+		header = append(header, byte(bits)|chunkExtended)
+		n := binary.PutUvarint(buf[:], flags)
+		header = append(header, buf[:n]...)
+	} else {
+		header = append(header, byte(bits))
+	}
+	n := binary.PutUvarint(buf[:], uint64(len(payload)))
+	header = append(header, buf[:n]...)
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(payload); err != nil {
+		return err
+	}
+	return nil
+}
+
+// readChunk reads the next chunk header and payload from r. Flags outside of
+// supported are rejected. On EOF without any bytes read, it returns io.EOF.
+func readChunk(r *bufio.Reader, supported uint64) (int, uint64, []byte, error) {
+	bits, flags, byteLen, err := readChunkHeader(r, supported)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	if byteLen == 0 {
+		return bits, flags, nil, nil
+	}
+
+	buf, err := readPayload(r, byteLen)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	return bits, flags, buf, nil
+}
+
+// readPayload reads exactly n bytes from r. The buffer grows as data arrives,
+// so a corrupt length cannot force a huge allocation up front. A short read
+// returns io.ErrUnexpectedEOF.
+func readPayload(r io.Reader, n uint64) ([]byte, error) {
+	buf, err := io.ReadAll(io.LimitReader(r, int64(min(n, math.MaxInt64))))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(buf)) < n {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return buf, nil
+}
+
+// readChunkHeader reads the next chunk header from r, leaving r positioned at
+// the start of a payload of byteLen bytes. Flags outside of supported are
+// rejected. On EOF without any bytes read, it returns io.EOF.
+func readChunkHeader(r *bufio.Reader, supported uint64) (bits int, flags uint64, byteLen uint64, err error) {
+	headerByte, err := r.ReadByte()
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	bits = int(headerByte &^ chunkExtended)
+	if bits > 52 {
+		return 0, 0, 0, errors.New("varfloat: invalid mantissa bits in stream header")
+	}
+
+	if headerByte&chunkExtended != 0 {
+		flags, err = binary.ReadUvarint(r)
+		if err != nil {
+			return 0, 0, 0, noEOF(err)
+		}
+		if flags&^supported != 0 {
+			return 0, 0, 0, errors.New("varfloat: unsupported chunk flags in stream header")
+		}
+	}
+
+	byteLen, err = binary.ReadUvarint(r)
+	if err != nil {
+		return 0, 0, 0, noEOF(err)
+	}
+	return bits, flags, byteLen, nil
+}
@@ -0,0 +1,62 @@
+package varfloat
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestReadChunk(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeChunk(&buf, 12, 0, []byte{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeChunk(&buf, 7, chunkFlagEntropy, nil); err != nil {
+		t.Fatal(err)
+	}
+	stream := buf.Bytes()
+
+	r := bufio.NewReader(bytes.NewReader(stream))
+	bits, flags, payload, err := readChunk(r, chunkFlagEntropy)
+	if err != nil || bits != 12 || flags != 0 || !bytes.Equal(payload, []byte{1, 2, 3}) {
+		t.Errorf("first chunk: %d, %d, %v, %v", bits, flags, payload, err)
+	}
+	bits, flags, payload, err = readChunk(r, chunkFlagEntropy)
+	if err != nil || bits != 7 || flags != chunkFlagEntropy || len(payload) != 0 {
+		t.Errorf("second chunk: %d, %d, %v, %v", bits, flags, payload, err)
+	}
+	if _, _, _, err := readChunk(r, chunkFlagEntropy); err != io.EOF {
+		t.Errorf("end of stream: %v, want io.EOF", err)
+	}
+
+	// Cutting the stream anywhere inside a chunk is an unexpected EOF, not
+	// a clean end.
+	for _, cut := range []int{1, 2, 4, 6, 7} {
+		r := bufio.NewReader(bytes.NewReader(stream[:cut]))
+		_, _, _, err := readChunk(r, chunkFlagEntropy)
+		if cut > 5 {
+			_, _, _, err = readChunk(r, chunkFlagEntropy)
+		}
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("cut at %d: %v, want io.ErrUnexpectedEOF", cut, err)
+		}
+	}
+
+	if _, _, _, err := readChunk(bufio.NewReader(bytes.NewReader(stream[5:])), 0); err == nil {
+		t.Error("unsupported flags accepted")
+	}
+	if _, _, _, err := readChunk(bufio.NewReader(bytes.NewReader([]byte{53, 0})), 0); err == nil {
+		t.Error("53 mantissa bits accepted")
+	}
+	if err := writeChunk(&buf, 53, 0, nil); err == nil {
+		t.Error("writeChunk accepted 53 mantissa bits")
+	}
+
+	// A huge length with no payload behind it fails without allocating it.
+	huge := []byte{12, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}
+	if _, _, _, err := readChunk(bufio.NewReader(bytes.NewReader(huge)), 0); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("huge length: %v, want io.ErrUnexpectedEOF", err)
+	}
+}
@@ -0,0 +1,246 @@
+package varfloat
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// EncodeFloatsRange encodes values relative to their own range: the slice
+// min and max are stored once, and every value is written as a bits-bit
+// fraction of that range. This gives a uniform absolute error of at most
+// (max-min)/(2*(2^bits-1)), which suits bounded readings such as sensor
+// values far from zero, where varfloats would spend bits on the exponent.
+//
+// Layout: [uvarint count][8-byte min][8-byte max][packed bits-bit offsets]
+//
+// Values must be finite, with max-min within the float64 range; bits must be
+// in [1, 52].
+func EncodeFloatsRange(values []float64, bits int) ([]byte, error) {
+	if bits < 1 || bits > 52 {
+		return nil, errors.New("varfloat: range bits must be between 1 and 52")
+	}
+	out := binary.AppendUvarint(nil, uint64(len(values)))
+	if len(values) == 0 {
+		return out, nil
+	}
+
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, errors.New("varfloat: range encoding requires finite values")
+		}
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	if math.IsInf(hi-lo, 0) {
+		return nil, errors.New("varfloat: range of values overflows float64")
+	}
+	out = append(out, EncodeFloat64Fixed(lo)...)
+	out = append(out, EncodeFloat64Fixed(hi)...)
+
+	levels := math.Ldexp(1, bits) - 1
+	var w bitWriter
+	for _, v := range values {
+		q := 0.0
+		if hi > lo {
+			q = math.Min(math.Round((v-lo)/(hi-lo)*levels), levels)
+		}
+		w.writeBits(uint64(q), bits)
+	}
+	return append(out, w.bytes()...), nil
+}
+
+// DecodeFloatsRange decodes a slice encoded by EncodeFloatsRange using the
+// same bits.
+func DecodeFloatsRange(b []byte, bits int) ([]float64, int, error) {
+	if bits < 1 || bits > 52 {
+		return nil, 0, errors.New("varfloat: range bits must be between 1 and 52")
+	}
+	count, n := binary.Uvarint(b)
+	if n <= 0 {
+		return nil, 0, errors.New("varfloat: invalid slice length")
+	}
+	if count == 0 {
+		return []float64{}, n, nil
+	}
+	if len(b)-n < 16 {
+		return nil, 0, errors.New("varfloat: range header truncated")
+	}
+	if count > uint64(len(b)-n-16)*8 {
+		return nil, 0, errors.New("varfloat: slice length exceeds buffer")
+	}
+	lo, _, _ := DecodeFloat64Fixed(b[n:])
+	hi, _, _ := DecodeFloat64Fixed(b[n+8:])
+	offset := n + 16
+
+	levels := math.Ldexp(1, bits) - 1
+	r := bitReader{b: b[offset:]}
+	out := make([]float64, 0, count)
+	for i := uint64(0); i < count; i++ {
+		q, err := r.readBits(bits)
+		if err != nil {
+			return nil, 0, err
+		}
+		out = append(out, math.Min(lo+float64(q)/levels*(hi-lo), hi))
+	}
+	return out, offset + packedLen(int(count), bits), nil
+}
+
+// EncodeFloatsDelta encodes values as the first value followed by the
+// differences between consecutive values, each written as a varfloat with
+// the given mantissa bits. It suits slowly changing series whose steps are
+// much smaller than the values themselves.
+//
+// Each difference is taken against the previous decoded value rather than the
+// original, so quantization errors do not accumulate along the series.
+//
+// Values must be finite, and so must every difference and every decoded
+// value; a series that jumps across most of the float64 range (say from 1e308
+// to -1e308) is rejected rather than decoded as Inf or NaN.
+//
+// Layout: [uvarint count][varfloat first][varfloat delta]...
+func EncodeFloatsDelta(values []float64, bits int) ([]byte, error) {
+	cfg, err := NewConfig(bits)
+	if err != nil {
+		return nil, err
+	}
+	out := binary.AppendUvarint(nil, uint64(len(values)))
+	prev := 0.0
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, errors.New("varfloat: delta encoding requires finite values")
+		}
+		d := v - prev
+		q, err := cfg.join(cfg.split(d))
+		if err != nil {
+			return nil, err
+		}
+		if math.IsInf(d, 0) || math.IsInf(prev+q, 0) {
+			return nil, errors.New("varfloat: delta between values overflows float64")
+		}
+		out = cfg.Append(out, d)
+		prev += q
+	}
+	return out, nil
+}
+
+// DecodeFloatsDelta decodes a slice encoded by EncodeFloatsDelta using the
+// same bits.
+func DecodeFloatsDelta(b []byte, bits int) ([]float64, int, error) {
+	cfg, err := NewConfig(bits)
+	if err != nil {
+		return nil, 0, err
+	}
+	count, n := binary.Uvarint(b)
+	if n <= 0 {
+		return nil, 0, errors.New("varfloat: invalid slice length")
+	}
+	if count > uint64(len(b)-n) {
+		return nil, 0, errors.New("varfloat: slice length exceeds buffer")
+	}
+	offset := n
+
+	out := make([]float64, 0, count)
+	prev := 0.0
+	for i := uint64(0); i < count; i++ {
+		d, used, err := cfg.Consume(b[offset:])
+		if err != nil {
+			return nil, 0, err
+		}
+		offset += used
+		prev += d
+		out = append(out, prev)
+	}
+	return out, offset, nil
+}
+
+// EncodeFloatsRLE encodes values as runs of equal quantized values, which
+// suits series that hold steady for long stretches (status values, setpoints,
+// sparse signals). Values are compared after quantizing to bits, so noise
+// below the chosen precision does not break a run.
+//
+// Long runs take only a few bytes, so the input cannot bound the decoded
+// length; slices are limited to 1<<24 values.
+//
+// Layout: [uvarint runCount][uvarint runLength][varfloat value]...
+func EncodeFloatsRLE(values []float64, bits int) ([]byte, error) {
+	cfg, err := NewConfig(bits)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) > maxImpliedValues {
+		return nil, errors.New("varfloat: too many values for run-length encoding")
+	}
+
+	var (
+		runs  []byte
+		count int
+		cur   []byte
+		run   int
+	)
+	flush := func() {
+		if run > 0 {
+			runs = binary.AppendUvarint(runs, uint64(run))
+			runs = append(runs, cur...)
+			count++
+		}
+	}
+	for _, v := range values {
+		enc := cfg.Append(nil, v)
+		if run > 0 && string(enc) == string(cur) {
+			run++
+			continue
+		}
+		flush()
+		cur, run = enc, 1
+	}
+	flush()
+
+	out := binary.AppendUvarint(nil, uint64(count))
+	return append(out, runs...), nil
+}
+
+// DecodeFloatsRLE decodes a slice encoded by EncodeFloatsRLE using the same
+// bits.
+func DecodeFloatsRLE(b []byte, bits int) ([]float64, int, error) {
+	cfg, err := NewConfig(bits)
+	if err != nil {
+		return nil, 0, err
+	}
+	count, n := binary.Uvarint(b)
+	if n <= 0 {
+		return nil, 0, errors.New("varfloat: invalid run count")
+	}
+	if count > uint64(len(b)-n) {
+		return nil, 0, errors.New("varfloat: run count exceeds buffer")
+	}
+	offset := n
+
+	var (
+		out   []float64
+		total uint64
+	)
+	for i := uint64(0); i < count; i++ {
+		run, used := binary.Uvarint(b[offset:])
+		if used <= 0 || run == 0 || run > maxImpliedValues {
+			return nil, 0, errors.New("varfloat: invalid run length")
+		}
+		offset += used
+		total += run
+		if total > maxImpliedValues {
+			return nil, 0, errors.New("varfloat: decoded length is too large")
+		}
+		v, used, err := cfg.Consume(b[offset:])
+		if err != nil {
+			return nil, 0, err
+		}
+		offset += used
+		for j := uint64(0); j < run; j++ {
+			out = append(out, v)
+		}
+	}
+	if out == nil {
+		out = []float64{}
+	}
+	return out, offset, nil
+}
@@ -0,0 +1,187 @@
+package varfloat
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func TestFloatsRangeRoundTrip(t *testing.T) {
+	values := []float64{20.5, 21.25, 19.875, 20, 22.125, 21.5}
+	for _, bits := range []int{1, 4, 12, 30, 52} {
+		b, err := EncodeFloatsRange(values, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, n, err := DecodeFloatsRange(b, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(b) || len(got) != len(values) {
+			t.Fatalf("bits=%d: decoded %d values from %d of %d bytes", bits, len(got), n, len(b))
+		}
+		budget := (22.125 - 19.875) / (2 * (math.Ldexp(1, bits) - 1))
+		for i, v := range values {
+			if d := math.Abs(got[i] - v); d > budget*(1+1e-12) {
+				t.Errorf("bits=%d: %g decoded as %g (error %g > %g)", bits, v, got[i], d, budget)
+			}
+		}
+		if got[2] != 19.875 || got[4] != 22.125 {
+			t.Errorf("bits=%d: min and max decoded as %g and %g", bits, got[2], got[4])
+		}
+	}
+
+	for _, values := range [][]float64{nil, {7, 7, 7}, {-1e308, 0, 7e307}} {
+		b, err := EncodeFloatsRange(values, 8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _, err := DecodeFloatsRange(b, 8)
+		if err != nil || len(got) != len(values) {
+			t.Fatalf("range of %v decoded as %v, %v", values, got, err)
+		}
+		for i, v := range got {
+			if math.IsNaN(v) || math.IsInf(v, 0) || values[0] == 7 && v != 7 {
+				t.Errorf("range of %v decoded %d as %g", values, i, v)
+			}
+		}
+	}
+}
+
+func TestFloatsRangeInvalid(t *testing.T) {
+	for _, values := range [][]float64{{1, math.NaN()}, {math.Inf(-1)}, {1e308, -1e308}} {
+		if _, err := EncodeFloatsRange(values, 8); err == nil {
+			t.Errorf("EncodeFloatsRange(%v) succeeded", values)
+		}
+	}
+	for _, bits := range []int{0, 53} {
+		if _, err := EncodeFloatsRange([]float64{1}, bits); err == nil {
+			t.Errorf("bits=%d accepted", bits)
+		}
+	}
+	b, err := EncodeFloatsRange([]float64{1, 2, 3}, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTruncated(t, b, func(b []byte) error {
+		_, _, err := DecodeFloatsRange(b, 12)
+		return err
+	})
+}
+
+func TestFloatsDeltaRoundTrip(t *testing.T) {
+	values := make([]float64, 200)
+	for i := range values {
+		values[i] = 1000 + 5*math.Sin(float64(i)/10)
+	}
+	values = append(values, 0, -3, 1e-300, 1e300, -1e300)
+	for _, bits := range []int{0, 4, 10, 23, 52} {
+		b, err := EncodeFloatsDelta(values, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, n, err := DecodeFloatsDelta(b, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(b) || len(got) != len(values) {
+			t.Fatalf("bits=%d: decoded %d values from %d of %d bytes", bits, len(got), n, len(b))
+		}
+		// Each value is off by the error of its own step, taken from the
+		// previous decoded value, so errors do not build up.
+		prev := 0.0
+		budget := MaxRelErrorForBits(bits)
+		for i, v := range values {
+			if d := math.Abs(got[i] - v); d > budget*math.Abs(v-prev)*(1+1e-9) {
+				t.Errorf("bits=%d: value %d %g decoded as %g", bits, i, v, got[i])
+			}
+			prev = got[i]
+		}
+	}
+}
+
+func TestFloatsDeltaInvalid(t *testing.T) {
+	for _, values := range [][]float64{
+		{1e308, -1e308, 5},
+		{-1e308, 1e308},
+		{1, math.NaN()},
+		{math.Inf(1)},
+		{math.MaxFloat64, -math.MaxFloat64},
+	} {
+		if b, err := EncodeFloatsDelta(values, 10); err == nil {
+			got, _, _ := DecodeFloatsDelta(b, 10)
+			t.Errorf("EncodeFloatsDelta(%v) succeeded, decoding as %v", values, got)
+		}
+	}
+	b, err := EncodeFloatsDelta([]float64{5, 6, 7.5}, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTruncated(t, b, func(b []byte) error {
+		_, _, err := DecodeFloatsDelta(b, 12)
+		return err
+	})
+	got, _, err := DecodeFloatsDelta([]byte{0}, 12)
+	if err != nil || len(got) != 0 {
+		t.Errorf("DecodeFloatsDelta(empty) = %v, %v", got, err)
+	}
+}
+
+func TestFloatsRLERoundTrip(t *testing.T) {
+	values := []float64{3, 3 + 1e-9, 3, 3, -1, -1, 0, 7.25, 7.25, 7.25, 7.25}
+	b, err := EncodeFloatsRLE(values, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Noise below 10 bits does not break the first run: 4 runs in all.
+	if b[0] != 4 {
+		t.Errorf("encoded %d runs, want 4", b[0])
+	}
+	got, n, err := DecodeFloatsRLE(b, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(b) || len(got) != len(values) {
+		t.Fatalf("decoded %d values from %d of %d bytes", len(got), n, len(b))
+	}
+	checkVecRelError(t, values, got, 10)
+
+	b, err = EncodeFloatsRLE(nil, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _, err := DecodeFloatsRLE(b, 10); err != nil || got == nil || len(got) != 0 {
+		t.Errorf("DecodeFloatsRLE(empty) = %v, %v", got, err)
+	}
+}
+
+func TestFloatsRLEInvalid(t *testing.T) {
+	b, err := EncodeFloatsRLE([]float64{1, 1, 2}, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTruncated(t, b, func(b []byte) error {
+		_, _, err := DecodeFloatsRLE(b, 8)
+		return err
+	})
+	if _, _, err := DecodeFloatsRLE([]byte{1, 0, 0}, 8); err == nil {
+		t.Error("zero run length accepted")
+	}
+	if _, _, err := DecodeFloatsRLE([]byte{0xff, 0xff, 0x03, 1, 0}, 8); err == nil {
+		t.Error("oversized run count accepted")
+	}
+	// One run of 2^32 values, and many runs that only add up to too many.
+	if _, _, err := DecodeFloatsRLE([]byte{0x01, 0x80, 0x80, 0x80, 0x80, 0x10, 0x00}, 8); err == nil {
+		t.Error("2^32-value run accepted")
+	}
+	many := binary.AppendUvarint(nil, 300)
+	for range 300 {
+		many = append(binary.AppendUvarint(many, maxImpliedValues/256), 0)
+	}
+	if _, _, err := DecodeFloatsRLE(many, 8); err == nil {
+		t.Error("runs adding up past the cap accepted")
+	}
+	if _, err := EncodeFloatsRLE([]float64{1}, 53); err == nil {
+		t.Error("53 bits accepted")
+	}
+}
@@ -0,0 +1,419 @@
+package varfloat
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ColumnKind selects how a column's values are stored.
+type ColumnKind uint8
+
+const (
+	// ColumnFloat stores float64 values with EncodeFloatsCompact.
+	ColumnFloat ColumnKind = iota
+	// ColumnInt stores int64 values in [Min, Max] with EncodeIntsBoundedSlice.
+	ColumnInt
+	// ColumnVec3 stores Vec3 values with EncodeVec3Slice.
+	ColumnVec3
+)
+
+// ColumnSpec describes one column of a columnar table.
+//
+// Bits is the mantissa precision for ColumnFloat and ColumnVec3 columns. For
+// ColumnInt columns Min and Max bound the values; Bits is the mantissa
+// precision passed to AppendIntBounded, or 0 to pick one as AppendIntAuto
+// does. That stores every int in the range exactly as long as Max-Min is
+// below 2^52; wider ranges are capped at 52 bits and round to a coarser grid.
+type ColumnSpec struct {
+	Name     string
+	Kind     ColumnKind
+	Bits     int
+	Min, Max int64
+}
+
+// ColumnData holds the values of one column in a row group. Only the field
+// matching the column's kind is used.
+type ColumnData struct {
+	Floats []float64
+	Ints   []int64
+	Vec3s  []Vec3
+}
+
+// ColumnStats holds the exact (pre-quantization) minimum and maximum of one
+// column in a row group. Only the fields matching the column's kind are set;
+// NaN values are ignored for float columns, and a float column of only NaNs
+// has NaN bounds. Vec3 bounds are per component.
+type ColumnStats struct {
+	Min, Max       float64
+	MinInt, MaxInt int64
+	MinVec3        Vec3
+	MaxVec3        Vec3
+}
+
+// RowGroup is one decoded row group. Stats is set for every column; Columns
+// is only filled for the columns that were requested from ReadRowGroup. Both
+// are indexed like the reader's Columns.
+type RowGroup struct {
+	Rows    int
+	Stats   []ColumnStats
+	Columns []ColumnData
+}
+
+// ColumnarWriter writes a table of mixed float, int and Vec3 columns to an
+// io.Writer, one column after another within each row group.
+//
+// Storing columns separately keeps similar values together, so each column
+// can use its own precision and compact format, and a reader can skip the
+// columns it does not need without decoding them.
+//
+// Layout:
+//
+//	[uvarint columnCount]
+//	  per column: [uvarint nameLen][name][kind][bits][uvarint zigzag(min)][uvarint zigzag(max)]
+//	row groups:
+//	  [uvarint rows]
+//	  per column: [stats][uvarint byteLen][payload]
+//
+// Float stats are two 8-byte float64s, int stats two zigzag uvarints and Vec3
+// stats six 8-byte float64s (min X,Y,Z then max X,Y,Z).
+type ColumnarWriter struct {
+	w     io.Writer
+	specs []ColumnSpec
+}
+
+// NewColumnarWriter validates specs and writes the table header to w.
+func NewColumnarWriter(w io.Writer, specs []ColumnSpec) (*ColumnarWriter, error) {
+	if err := checkColumnSpecs(specs); err != nil {
+		return nil, err
+	}
+	header := binary.AppendUvarint(nil, uint64(len(specs)))
+	for _, s := range specs {
+		header = binary.AppendUvarint(header, uint64(len(s.Name)))
+		header = append(header, s.Name...)
+		header = append(header, byte(s.Kind), byte(s.Bits))
+		header = binary.AppendUvarint(header, zigZagEncode(s.Min))
+		header = binary.AppendUvarint(header, zigZagEncode(s.Max))
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &ColumnarWriter{w: w, specs: append([]ColumnSpec(nil), specs...)}, nil
+}
+
+// WriteRowGroup writes one row group. cols must have one entry per column,
+// in spec order, and every column must hold the same number of rows. Empty
+// row groups are not written.
+func (cw *ColumnarWriter) WriteRowGroup(cols []ColumnData) error {
+	if len(cols) != len(cw.specs) {
+		return errors.New("varfloat: row group column count does not match specs")
+	}
+	rows := -1
+	for i, s := range cw.specs {
+		n := columnLen(s.Kind, cols[i])
+		if rows >= 0 && n != rows {
+			return fmt.Errorf("varfloat: column %q has %d rows, want %d", s.Name, n, rows)
+		}
+		rows = n
+	}
+	if rows <= 0 {
+		return nil
+	}
+
+	out := binary.AppendUvarint(nil, uint64(rows))
+	for i, s := range cw.specs {
+		var (
+			payload []byte
+			err     error
+		)
+		switch s.Kind {
+		case ColumnFloat:
+			out = appendFloatStats(out, cols[i].Floats)
+			payload, err = EncodeFloatsCompact(cols[i].Floats, s.Bits)
+		case ColumnInt:
+			lo, hi := cols[i].Ints[0], cols[i].Ints[0]
+			for _, v := range cols[i].Ints {
+				lo, hi = min(lo, v), max(hi, v)
+			}
+			out = binary.AppendUvarint(out, zigZagEncode(lo))
+			out = binary.AppendUvarint(out, zigZagEncode(hi))
+			payload, err = EncodeIntsBoundedSlice(cols[i].Ints, s.Min, s.Max, columnIntBits(s))
+		case ColumnVec3:
+			var xs, ys, zs []float64
+			for _, v := range cols[i].Vec3s {
+				xs, ys, zs = append(xs, v.X), append(ys, v.Y), append(zs, v.Z)
+			}
+			lx, hx := floatBounds(xs)
+			ly, hy := floatBounds(ys)
+			lz, hz := floatBounds(zs)
+			for _, v := range [6]float64{lx, ly, lz, hx, hy, hz} {
+				out = append(out, EncodeFloat64Fixed(v)...)
+			}
+			payload, err = EncodeVec3Slice(cols[i].Vec3s, s.Bits)
+		}
+		if err != nil {
+			return fmt.Errorf("varfloat: column %q: %w", s.Name, err)
+		}
+		out = binary.AppendUvarint(out, uint64(len(payload)))
+		out = append(out, payload...)
+	}
+	_, err := cw.w.Write(out)
+	return err
+}
+
+// ColumnarReader reads tables written by ColumnarWriter.
+type ColumnarReader struct {
+	r     *bufio.Reader
+	specs []ColumnSpec
+}
+
+// NewColumnarReader reads the table header from r.
+func NewColumnarReader(r io.Reader) (*ColumnarReader, error) {
+	br := bufio.NewReader(r)
+	count, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	if count > 1<<16 {
+		return nil, errors.New("varfloat: invalid column count")
+	}
+	specs := make([]ColumnSpec, 0, count)
+	for i := uint64(0); i < count; i++ {
+		nameLen, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, noEOF(err)
+		}
+		if nameLen > 1<<16 {
+			return nil, errors.New("varfloat: invalid column name length")
+		}
+		buf := make([]byte, nameLen+2)
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return nil, noEOF(err)
+		}
+		s := ColumnSpec{
+			Name: string(buf[:nameLen]),
+			Kind: ColumnKind(buf[nameLen]),
+			Bits: int(buf[nameLen+1]),
+		}
+		lo, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, noEOF(err)
+		}
+		hi, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, noEOF(err)
+		}
+		s.Min, s.Max = zigZagDecode(lo), zigZagDecode(hi)
+		specs = append(specs, s)
+	}
+	if err := checkColumnSpecs(specs); err != nil {
+		return nil, err
+	}
+	return &ColumnarReader{r: br, specs: specs}, nil
+}
+
+// Columns returns the table's column specs.
+func (cr *ColumnarReader) Columns() []ColumnSpec {
+	return append([]ColumnSpec(nil), cr.specs...)
+}
+
+// ColumnIndex returns the index of the named column, or -1.
+func (cr *ColumnarReader) ColumnIndex(name string) int {
+	for i, s := range cr.specs {
+		if s.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// ReadRowGroup reads the next row group, decoding only the named columns (or
+// every column if none are named). The payloads of other columns are skipped
+// without being decoded. At the end of the table it returns io.EOF.
+func (cr *ColumnarReader) ReadRowGroup(columns ...string) (*RowGroup, error) {
+	want := make([]bool, len(cr.specs))
+	for _, name := range columns {
+		i := cr.ColumnIndex(name)
+		if i < 0 {
+			return nil, fmt.Errorf("varfloat: unknown column %q", name)
+		}
+		want[i] = true
+	}
+	if len(columns) == 0 {
+		for i := range want {
+			want[i] = true
+		}
+	}
+
+	rows, err := binary.ReadUvarint(cr.r)
+	if err != nil {
+		return nil, err
+	}
+	if rows == 0 || rows > math.MaxInt32 {
+		return nil, errors.New("varfloat: invalid row group size")
+	}
+	g := &RowGroup{
+		Rows:    int(rows),
+		Stats:   make([]ColumnStats, len(cr.specs)),
+		Columns: make([]ColumnData, len(cr.specs)),
+	}
+	for i, s := range cr.specs {
+		if err := cr.readStats(s.Kind, &g.Stats[i]); err != nil {
+			return nil, noEOF(err)
+		}
+		byteLen, err := binary.ReadUvarint(cr.r)
+		if err != nil {
+			return nil, noEOF(err)
+		}
+		if !want[i] {
+			if _, err := io.CopyN(io.Discard, cr.r, int64(min(byteLen, math.MaxInt64))); err != nil {
+				return nil, noEOF(err)
+			}
+			continue
+		}
+		buf, err := readPayload(cr.r, byteLen)
+		if err != nil {
+			return nil, noEOF(err)
+		}
+
+		var n int
+		switch s.Kind {
+		case ColumnFloat:
+			g.Columns[i].Floats, _, err = DecodeFloatsCompact(buf, s.Bits)
+			n = len(g.Columns[i].Floats)
+		case ColumnInt:
+			g.Columns[i].Ints, _, _, err = DecodeIntsBoundedSlice(buf, s.Min, s.Max)
+			n = len(g.Columns[i].Ints)
+		case ColumnVec3:
+			g.Columns[i].Vec3s, _, err = DecodeVec3Slice(buf, s.Bits)
+			n = len(g.Columns[i].Vec3s)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("varfloat: column %q: %w", s.Name, err)
+		}
+		if n != g.Rows {
+			return nil, fmt.Errorf("varfloat: column %q has %d rows, want %d", s.Name, n, g.Rows)
+		}
+	}
+	return g, nil
+}
+
+// readStats reads the statistics of one column.
+func (cr *ColumnarReader) readStats(kind ColumnKind, st *ColumnStats) error {
+	if kind == ColumnInt {
+		lo, err := binary.ReadUvarint(cr.r)
+		if err != nil {
+			return err
+		}
+		hi, err := binary.ReadUvarint(cr.r)
+		if err != nil {
+			return err
+		}
+		st.MinInt, st.MaxInt = zigZagDecode(lo), zigZagDecode(hi)
+		return nil
+	}
+
+	n := 2
+	if kind == ColumnVec3 {
+		n = 6
+	}
+	var vals [6]float64
+	var buf [8]byte
+	for i := 0; i < n; i++ {
+		if _, err := io.ReadFull(cr.r, buf[:]); err != nil {
+			return err
+		}
+		vals[i], _, _ = DecodeFloat64Fixed(buf[:])
+	}
+	if kind == ColumnVec3 {
+		st.MinVec3 = Vec3{X: vals[0], Y: vals[1], Z: vals[2]}
+		st.MaxVec3 = Vec3{X: vals[3], Y: vals[4], Z: vals[5]}
+	} else {
+		st.Min, st.Max = vals[0], vals[1]
+	}
+	return nil
+}
+
+// checkColumnSpecs validates a table's column specs.
+func checkColumnSpecs(specs []ColumnSpec) error {
+	seen := make(map[string]bool, len(specs))
+	for _, s := range specs {
+		if s.Name == "" {
+			return errors.New("varfloat: column name must not be empty")
+		}
+		if seen[s.Name] {
+			return fmt.Errorf("varfloat: duplicate column %q", s.Name)
+		}
+		seen[s.Name] = true
+		if s.Bits < 0 || s.Bits > 52 {
+			return errors.New("varfloat: mantissa bits must be between 0 and 52")
+		}
+		switch s.Kind {
+		case ColumnFloat, ColumnVec3:
+		case ColumnInt:
+			if s.Min > s.Max {
+				return errors.New("varfloat: min must be <= max")
+			}
+		default:
+			return fmt.Errorf("varfloat: column %q has unknown kind %d", s.Name, s.Kind)
+		}
+	}
+	return nil
+}
+
+// columnLen returns the number of rows in d for a column of the given kind.
+func columnLen(kind ColumnKind, d ColumnData) int {
+	switch kind {
+	case ColumnInt:
+		return len(d.Ints)
+	case ColumnVec3:
+		return len(d.Vec3s)
+	default:
+		return len(d.Floats)
+	}
+}
+
+// columnIntBits returns the mantissa bits used for an int column.
+func columnIntBits(s ColumnSpec) int {
+	if s.Bits == 0 {
+		return autoBitsForWidth(uint64(s.Max - s.Min))
+	}
+	return s.Bits
+}
+
+// appendFloatStats appends the min and max of values as fixed float64s.
+func appendFloatStats(dst []byte, values []float64) []byte {
+	lo, hi := floatBounds(values)
+	dst = append(dst, EncodeFloat64Fixed(lo)...)
+	return append(dst, EncodeFloat64Fixed(hi)...)
+}
+
+// floatBounds returns the min and max of values, ignoring NaNs. It returns
+// NaN bounds if there are no other values.
+func floatBounds(values []float64) (float64, float64) {
+	lo, hi := math.NaN(), math.NaN()
+	for _, v := range values {
+		if math.IsNaN(v) {
+			continue
+		}
+		if math.IsNaN(lo) || v < lo {
+			lo = v
+		}
+		if math.IsNaN(hi) || v > hi {
+			hi = v
+		}
+	}
+	return lo, hi
+}
+
+// noEOF turns io.EOF into io.ErrUnexpectedEOF for reads in the middle of a
+// structure.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,216 @@
+package varfloat
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"slices"
+	"testing"
+)
+
+var testColumnSpecs = []ColumnSpec{
+	{Name: "temp", Kind: ColumnFloat, Bits: 12},
+	{Name: "count", Kind: ColumnInt, Min: -100, Max: 1000},
+	{Name: "pos", Kind: ColumnVec3, Bits: 16},
+}
+
+// writeTestTable writes two row groups with testColumnSpecs.
+func writeTestTable(t *testing.T) ([]byte, [][]ColumnData) {
+	t.Helper()
+	groups := [][]ColumnData{
+		{
+			{Floats: []float64{20.5, 21, math.NaN(), 19.75}},
+			{Ints: []int64{-100, 0, 999, 1000}},
+			{Vec3s: []Vec3{{X: 1}, {Y: -2}, {Z: 3}, {X: 4, Y: 5, Z: -6}}},
+		},
+		{
+			{Floats: []float64{-3}},
+			{Ints: []int64{7}},
+			{Vec3s: []Vec3{{X: 0.5, Y: 0.5, Z: 0.5}}},
+		},
+	}
+	var buf bytes.Buffer
+	w, err := NewColumnarWriter(&buf, testColumnSpecs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, g := range groups {
+		if err := w.WriteRowGroup(g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Empty row groups are skipped.
+	if err := w.WriteRowGroup(make([]ColumnData, 3)); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), groups
+}
+
+func TestColumnarRoundTrip(t *testing.T) {
+	table, groups := writeTestTable(t)
+	r, err := NewColumnarReader(bytes.NewReader(table))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(r.Columns(), testColumnSpecs) {
+		t.Errorf("Columns() = %v", r.Columns())
+	}
+	for gi, want := range groups {
+		g, err := r.ReadRowGroup()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g.Rows != len(want[0].Floats) {
+			t.Errorf("group %d: %d rows", gi, g.Rows)
+		}
+		plain, _ := EncodeFloats(want[0].Floats, 12)
+		floats, _, _ := DecodeFloats(plain, 12)
+		if !slices.EqualFunc(g.Columns[0].Floats, floats, sameFloat) {
+			t.Errorf("group %d: floats %v, want %v", gi, g.Columns[0].Floats, floats)
+		}
+		if !slices.Equal(g.Columns[1].Ints, want[1].Ints) {
+			t.Errorf("group %d: ints %v, want %v", gi, g.Columns[1].Ints, want[1].Ints)
+		}
+		checkVecRelError(t, vecFlatten(want[2].Vec3s), vecFlatten(g.Columns[2].Vec3s), 16)
+	}
+	if _, err := r.ReadRowGroup(); err != io.EOF {
+		t.Errorf("after last group: %v, want io.EOF", err)
+	}
+}
+
+func TestColumnarProjectionAndStats(t *testing.T) {
+	table, _ := writeTestTable(t)
+	r, err := NewColumnarReader(bytes.NewReader(table))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := r.ReadRowGroup("count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Columns[0].Floats != nil || g.Columns[2].Vec3s != nil || len(g.Columns[1].Ints) != 4 {
+		t.Errorf("projection decoded %+v", g.Columns)
+	}
+	want := []ColumnStats{
+		{Min: 19.75, Max: 21},
+		{MinInt: -100, MaxInt: 1000},
+		{MinVec3: Vec3{Y: -2, Z: -6}, MaxVec3: Vec3{X: 4, Y: 5, Z: 3}},
+	}
+	if !slices.Equal(g.Stats, want) {
+		t.Errorf("stats %+v, want %+v", g.Stats, want)
+	}
+	if _, err := r.ReadRowGroup("missing"); err == nil {
+		t.Error("unknown column accepted")
+	}
+}
+
+func TestColumnarIntAutoBits(t *testing.T) {
+	// With Bits 0, ranges up to 2^52-1 wide are exact.
+	spec := ColumnSpec{Name: "id", Kind: ColumnInt, Min: -3, Max: -3 + (1<<52 - 1)}
+	ints := []int64{spec.Min, spec.Min + 1, 1<<51 + 7, spec.Max - 1, spec.Max}
+	var buf bytes.Buffer
+	w, err := NewColumnarWriter(&buf, []ColumnSpec{spec})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRowGroup([]ColumnData{{Ints: ints}}); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewColumnarReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := r.ReadRowGroup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(g.Columns[0].Ints, ints) {
+		t.Errorf("ints decoded as %v, want %v", g.Columns[0].Ints, ints)
+	}
+}
+
+func TestColumnarInvalid(t *testing.T) {
+	bad := [][]ColumnSpec{
+		{{Name: ""}},
+		{{Name: "a"}, {Name: "a"}},
+		{{Name: "a", Bits: 53}},
+		{{Name: "a", Kind: ColumnInt, Min: 2, Max: 1}},
+		{{Name: "a", Kind: 9}},
+	}
+	for _, specs := range bad {
+		if _, err := NewColumnarWriter(io.Discard, specs); err == nil {
+			t.Errorf("specs %+v accepted", specs)
+		}
+	}
+
+	w, err := NewColumnarWriter(io.Discard, testColumnSpecs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRowGroup([]ColumnData{{Floats: []float64{1}}, {Ints: []int64{1, 2}}, {Vec3s: []Vec3{{}}}}); err == nil {
+		t.Error("mismatched row counts accepted")
+	}
+	if err := w.WriteRowGroup([]ColumnData{{Floats: []float64{1}}, {Ints: []int64{5000}}, {Vec3s: []Vec3{{}}}}); err == nil {
+		t.Error("int outside [Min, Max] accepted")
+	}
+
+	table, _ := writeTestTable(t)
+	for cut := 1; cut < len(table)-1; cut++ {
+		r, err := NewColumnarReader(bytes.NewReader(table[:cut]))
+		if err != nil {
+			continue
+		}
+		for {
+			_, err = r.ReadRowGroup()
+			if err != nil {
+				break
+			}
+		}
+		if err == io.EOF && !isRowGroupBoundary(table, cut) {
+			t.Errorf("cut at %d read as a clean end of table", cut)
+		}
+	}
+
+	// A column payload length far beyond the data.
+	var buf bytes.Buffer
+	w, _ = NewColumnarWriter(&buf, []ColumnSpec{{Name: "a", Bits: 8}})
+	buf.Write([]byte{1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0x0f})
+	r, err := NewColumnarReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ReadRowGroup(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("oversized payload: %v, want io.ErrUnexpectedEOF", err)
+	}
+
+	// A one-entry dictionary claiming 2^32 values.
+	buf.Reset()
+	w, _ = NewColumnarWriter(&buf, []ColumnSpec{{Name: "a", Bits: 8}})
+	buf.Write([]byte{1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 8, formatDict, 0x80, 0x80, 0x80, 0x80, 0x10, 0x01, 0x00})
+	r, err = NewColumnarReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ReadRowGroup(); err == nil {
+		t.Error("oversized one-entry dictionary accepted")
+	}
+}
+
+// isRowGroupBoundary reports whether table[:cut] ends exactly between row
+// groups of the table written by writeTestTable.
+func isRowGroupBoundary(table []byte, cut int) bool {
+	r, err := NewColumnarReader(bytes.NewReader(table))
+	if err != nil {
+		return false
+	}
+	for {
+		rest := r.r.Buffered()
+		if len(table)-rest == cut {
+			return true
+		}
+		if _, err := r.ReadRowGroup(); err != nil {
+			return false
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package varfloat
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/cmplx"
+)
+
+// AppendComplex encodes z as two varfloats, real part then imaginary part,
+// with the given mantissa bits and appends them to dst.
+func AppendComplex(dst []byte, z complex128, bits int) ([]byte, error) {
+	cfg, err := NewConfig(bits)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Append(cfg.Append(dst, real(z)), imag(z)), nil
+}
+
+// ConsumeComplex decodes a value written by AppendComplex from the beginning
+// of b using the same bits. It returns the decoded value and the number of
+// bytes consumed.
+func ConsumeComplex(b []byte, bits int) (complex128, int, error) {
+	cfg, err := NewConfig(bits)
+	if err != nil {
+		return 0, 0, err
+	}
+	re, n, err := cfg.Consume(b)
+	if err != nil {
+		return 0, 0, err
+	}
+	im, m, err := cfg.Consume(b[n:])
+	if err != nil {
+		return 0, 0, err
+	}
+	return complex(re, im), n + m, nil
+}
+
+// EncodeComplexSlice encodes a slice of complex values with AppendComplex,
+// prefixed with the slice length as a uvarint.
+func EncodeComplexSlice(zs []complex128, bits int) ([]byte, error) {
+	cfg, err := NewConfig(bits)
+	if err != nil {
+		return nil, err
+	}
+	out := binary.AppendUvarint(nil, uint64(len(zs)))
+	for _, z := range zs {
+		out = cfg.Append(cfg.Append(out, real(z)), imag(z))
+	}
+	return out, nil
+}
+
+// DecodeComplexSlice decodes a slice written by EncodeComplexSlice using the
+// same bits.
+func DecodeComplexSlice(b []byte, bits int) ([]complex128, int, error) {
+	if _, err := NewConfig(bits); err != nil {
+		return nil, 0, err
+	}
+	count, n := binary.Uvarint(b)
+	if n <= 0 {
+		return nil, 0, errors.New("varfloat: invalid slice length")
+	}
+	if count > uint64(len(b)-n)/2 {
+		return nil, 0, errors.New("varfloat: slice length exceeds buffer")
+	}
+	offset := n
+
+	out := make([]complex128, 0, count)
+	for i := uint64(0); i < count; i++ {
+		z, used, err := ConsumeComplex(b[offset:], bits)
+		if err != nil {
+			return nil, 0, err
+		}
+		out = append(out, z)
+		offset += used
+	}
+	return out, offset, nil
+}
+
+// PolarComplexCodec encodes complex values in polar form: the magnitude as a
+// varfloat with MagnitudeBits mantissa bits (a relative error, like any
+// varfloat) and the phase as a PhaseBits-bit fraction of a full turn (an
+// absolute error of at most MaxPhaseError radians).
+//
+// Spectra such as FFT bins span many orders of magnitude, so rectangular form
+// spends exponent bits on both parts of every bin; polar form spends them
+// once. Zero values are a single byte with no phase.
+type PolarComplexCodec struct {
+	MagnitudeBits int
+	PhaseBits     int
+}
+
+// NewPolarComplexCodec creates a PolarComplexCodec with the given magnitude
+// mantissa bits and the fewest phase bits that keep the phase within
+// maxPhaseErr radians.
+func NewPolarComplexCodec(magBits int, maxPhaseErr float64) (*PolarComplexCodec, error) {
+	if !(maxPhaseErr > 0) {
+		return nil, errors.New("varfloat: maxPhaseErr must be > 0")
+	}
+	phaseBits := 0
+	for math.Pi/float64(uint64(1)<<phaseBits) > maxPhaseErr {
+		phaseBits++
+		if phaseBits > 32 {
+			return nil, errors.New("varfloat: maxPhaseErr is too small")
+		}
+	}
+	c := &PolarComplexCodec{MagnitudeBits: magBits, PhaseBits: phaseBits}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate checks that the codec's parameters are usable.
+func (c *PolarComplexCodec) Validate() error {
+	if c.MagnitudeBits < 0 || c.MagnitudeBits > 52 {
+		return errors.New("varfloat: mantissa bits must be between 0 and 52")
+	}
+	if c.PhaseBits < 0 || c.PhaseBits > 32 {
+		return errors.New("varfloat: phase bits must be between 0 and 32")
+	}
+	return nil
+}
+
+// MaxPhaseError returns the largest phase error in radians, π/2^PhaseBits.
+func (c *PolarComplexCodec) MaxPhaseError() float64 {
+	return math.Pi / float64(uint64(1)<<c.PhaseBits)
+}
+
+// Append encodes z and appends it to dst as
+//
+//	[varfloat magnitude][uvarint phase step, if the magnitude is non-zero]
+//
+// z must be finite, and so must its magnitude: 1.5e308+1.5e308i is rejected.
+func (c *PolarComplexCodec) Append(dst []byte, z complex128) ([]byte, error) {
+	mag, step, err := c.split(z)
+	if err != nil {
+		return nil, err
+	}
+	dst = Config{MantissaBits: c.MagnitudeBits}.Append(dst, mag)
+	if mag == 0 {
+		return dst, nil
+	}
+	return binary.AppendUvarint(dst, step), nil
+}
+
+// Consume decodes a value written by Append from the beginning of b. It
+// returns the decoded value and the number of bytes consumed.
+func (c *PolarComplexCodec) Consume(b []byte) (complex128, int, error) {
+	if err := c.Validate(); err != nil {
+		return 0, 0, err
+	}
+	mag, n, err := Config{MantissaBits: c.MagnitudeBits}.Consume(b)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !validMagnitude(mag) {
+		return 0, 0, errors.New("varfloat: invalid magnitude")
+	}
+	if mag == 0 {
+		return 0, n, nil
+	}
+	step, m := binary.Uvarint(b[n:])
+	if m <= 0 || step >= uint64(1)<<c.PhaseBits {
+		return 0, 0, errors.New("varfloat: invalid phase")
+	}
+	return c.join(mag, step), n + m, nil
+}
+
+// EncodeSlice encodes a slice of complex values as
+//
+//	[uvarint count][varfloat magnitude]...[packed PhaseBits-bit phases]
+//
+// with phases only for non-zero magnitudes, packed after all magnitudes.
+func (c *PolarComplexCodec) EncodeSlice(zs []complex128) ([]byte, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	cfg := Config{MantissaBits: c.MagnitudeBits}
+	out := binary.AppendUvarint(nil, uint64(len(zs)))
+	var w bitWriter
+	for _, z := range zs {
+		mag, step, err := c.split(z)
+		if err != nil {
+			return nil, err
+		}
+		out = cfg.Append(out, mag)
+		if mag != 0 {
+			w.writeBits(step, c.PhaseBits)
+		}
+	}
+	return append(out, w.bytes()...), nil
+}
+
+// DecodeSlice decodes a slice written by EncodeSlice.
+func (c *PolarComplexCodec) DecodeSlice(b []byte) ([]complex128, int, error) {
+	if err := c.Validate(); err != nil {
+		return nil, 0, err
+	}
+	count, n := binary.Uvarint(b)
+	if n <= 0 {
+		return nil, 0, errors.New("varfloat: invalid slice length")
+	}
+	if count > uint64(len(b)-n) {
+		return nil, 0, errors.New("varfloat: slice length exceeds buffer")
+	}
+	offset := n
+
+	cfg := Config{MantissaBits: c.MagnitudeBits}
+	mags := make([]float64, count)
+	nonZero := 0
+	for i := range mags {
+		mag, used, err := cfg.Consume(b[offset:])
+		if err != nil {
+			return nil, 0, err
+		}
+		if !validMagnitude(mag) {
+			return nil, 0, errors.New("varfloat: invalid magnitude")
+		}
+		mags[i] = mag
+		offset += used
+		if mag != 0 {
+			nonZero++
+		}
+	}
+
+	r := bitReader{b: b[offset:]}
+	out := make([]complex128, count)
+	for i, mag := range mags {
+		if mag == 0 {
+			continue
+		}
+		step, err := r.readBits(c.PhaseBits)
+		if err != nil {
+			return nil, 0, err
+		}
+		out[i] = c.join(mag, step)
+	}
+	return out, offset + packedLen(nonZero, c.PhaseBits), nil
+}
+
+// split returns the magnitude and quantized phase step of z.
+func (c *PolarComplexCodec) split(z complex128) (float64, uint64, error) {
+	if err := c.Validate(); err != nil {
+		return 0, 0, err
+	}
+	if cmplx.IsNaN(z) || cmplx.IsInf(z) {
+		return 0, 0, errors.New("varfloat: polar encoding requires finite values")
+	}
+	mag := cmplx.Abs(z)
+	if math.IsInf(mag, 0) {
+		return 0, 0, errors.New("varfloat: complex magnitude overflows float64")
+	}
+	if mag == 0 {
+		return 0, 0, nil
+	}
+	steps := float64(uint64(1) << c.PhaseBits)
+	step := uint64(math.Round((cmplx.Phase(z)+math.Pi)/(2*math.Pi)*steps)) % uint64(steps)
+	return mag, step, nil
+}
+
+// validMagnitude reports whether a decoded magnitude could have been written
+// by split: finite and not negative.
+func validMagnitude(mag float64) bool {
+	return mag >= 0 && !math.IsInf(mag, 0)
+}
+
+// join rebuilds a value from its magnitude and phase step.
+func (c *PolarComplexCodec) join(mag float64, step uint64) complex128 {
+	steps := float64(uint64(1) << c.PhaseBits)
+	return cmplx.Rect(mag, float64(step)/steps*2*math.Pi-math.Pi)
+}
@@ -0,0 +1,220 @@
+package varfloat
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand/v2"
+	"testing"
+)
+
+// sampleComplex returns n deterministic values spanning several orders of
+// magnitude in every direction.
+func sampleComplex(n int, seed uint64) []complex128 {
+	r := rand.New(rand.NewPCG(seed, seed))
+	out := make([]complex128, n)
+	for i := range out {
+		out[i] = cmplx.Rect(math.Pow(10, float64(r.IntN(12)-6))*(0.5+r.Float64()), (r.Float64()*2-1)*math.Pi)
+	}
+	return out
+}
+
+// phaseDiff returns the absolute difference of two angles, wrapped into
+// [0, π].
+func phaseDiff(a, b float64) float64 {
+	d := math.Mod(math.Abs(a-b), 2*math.Pi)
+	return math.Min(d, 2*math.Pi-d)
+}
+
+func TestComplexRoundTrip(t *testing.T) {
+	zs := append(sampleComplex(50, 81), 0, complex(math.Inf(1), -2), complex(math.Copysign(0, -1), 1))
+	for _, bits := range []int{0, 8, 23, 52} {
+		b, err := EncodeComplexSlice(zs, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, n, err := DecodeComplexSlice(b, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(b) || len(got) != len(zs) {
+			t.Fatalf("bits=%d: decoded %d values from %d of %d bytes", bits, len(got), n, len(b))
+		}
+		for i, z := range zs {
+			want, have := []float64{real(z), imag(z)}, []float64{real(got[i]), imag(got[i])}
+			for k := range want {
+				if !sameFloat(want[k], have[k]) && !withinRelError(math.Abs(have[k]-want[k]), math.Abs(want[k]), MaxRelErrorForBits(bits)) {
+					t.Errorf("bits=%d: %v decoded as %v", bits, z, got[i])
+				}
+			}
+		}
+
+		one, err := AppendComplex(nil, zs[0], bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		z, m, err := ConsumeComplex(one, bits)
+		if err != nil || m != len(one) || z != got[0] {
+			t.Errorf("bits=%d: ConsumeComplex = %v, %d, %v; want %v", bits, z, m, err, got[0])
+		}
+	}
+}
+
+func TestComplexInvalid(t *testing.T) {
+	if _, err := AppendComplex(nil, 1, 53); err == nil {
+		t.Error("53 bits accepted")
+	}
+	if _, err := EncodeComplexSlice(nil, -1); err == nil {
+		t.Error("-1 bits accepted")
+	}
+	b, err := EncodeComplexSlice([]complex128{1 + 2i, -3i}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTruncated(t, b, func(b []byte) error {
+		_, _, err := DecodeComplexSlice(b, 10)
+		return err
+	})
+	if _, _, err := DecodeComplexSlice([]byte{0xff, 0xff, 0x03, 0, 0}, 10); err == nil {
+		t.Error("oversized count accepted")
+	}
+	b, err = EncodeComplexSlice(nil, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _, err := DecodeComplexSlice(b, 10); err != nil || len(got) != 0 {
+		t.Errorf("DecodeComplexSlice(empty) = %v, %v", got, err)
+	}
+}
+
+func TestNewPolarComplexCodec(t *testing.T) {
+	for _, maxErr := range []float64{4, math.Pi, 1, 0.1, 1e-3, 1e-9} {
+		c, err := NewPolarComplexCodec(20, maxErr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.MaxPhaseError() > maxErr {
+			t.Errorf("%g: %d phase bits give %g", maxErr, c.PhaseBits, c.MaxPhaseError())
+		}
+		if c.PhaseBits > 0 {
+			fewer := PolarComplexCodec{PhaseBits: c.PhaseBits - 1}
+			if fewer.MaxPhaseError() <= maxErr {
+				t.Errorf("%g: %d phase bits is not the fewest", maxErr, c.PhaseBits)
+			}
+		}
+	}
+	for _, maxErr := range []float64{0, -1, math.NaN(), 1e-12} {
+		if _, err := NewPolarComplexCodec(20, maxErr); err == nil {
+			t.Errorf("maxPhaseErr %g accepted", maxErr)
+		}
+	}
+	for _, c := range []PolarComplexCodec{{MagnitudeBits: -1}, {MagnitudeBits: 53}, {PhaseBits: -1}, {PhaseBits: 33}} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("%+v accepted", c)
+		}
+	}
+}
+
+func TestPolarComplexRoundTrip(t *testing.T) {
+	zs := append(sampleComplex(200, 82), 0, -1, 1i, complex(-1, -1e-300), 1e-300i, complex(1e307, -1e307), 1e308+1e308i)
+	for _, c := range []PolarComplexCodec{{MagnitudeBits: 10, PhaseBits: 0}, {MagnitudeBits: 10, PhaseBits: 3}, {MagnitudeBits: 23, PhaseBits: 16}, {MagnitudeBits: 52, PhaseBits: 32}} {
+		b, err := c.EncodeSlice(zs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, n, err := c.DecodeSlice(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(b) || len(got) != len(zs) {
+			t.Fatalf("%+v: decoded %d values from %d of %d bytes", c, len(got), n, len(b))
+		}
+		for i, z := range zs {
+			var one []byte
+			one, err = c.Append(nil, z)
+			if err != nil {
+				t.Fatal(err)
+			}
+			single, m, err := c.Consume(one)
+			if err != nil || m != len(one) || single != got[i] {
+				t.Errorf("%+v: Consume = %v, %d, %v; DecodeSlice gave %v", c, single, m, err, got[i])
+			}
+
+			mag, gotMag := cmplx.Abs(z), cmplx.Abs(got[i])
+			if mag == 0 {
+				if got[i] != 0 || len(one) != 1 {
+					t.Errorf("%+v: zero decoded as %v from %d bytes", c, got[i], len(one))
+				}
+				continue
+			}
+			// Rect and Abs each round, adding a few ulps on top of the
+			// varfloat error.
+			if math.Abs(gotMag-mag) > mag*(MaxRelErrorForBits(c.MagnitudeBits)+0x1p-50) {
+				t.Errorf("%+v: |%v| = %g decoded as %g", c, z, mag, gotMag)
+			}
+			if d := phaseDiff(cmplx.Phase(got[i]), cmplx.Phase(z)); d > c.MaxPhaseError()*(1+1e-9)+1e-12 {
+				t.Errorf("%+v: phase of %v off by %g > MaxPhaseError %g", c, z, d, c.MaxPhaseError())
+			}
+		}
+	}
+}
+
+func TestPolarComplexInvalid(t *testing.T) {
+	c := PolarComplexCodec{MagnitudeBits: 12, PhaseBits: 8}
+	for _, z := range []complex128{
+		complex(math.NaN(), 0),
+		complex(1, math.Inf(-1)),
+		1.5e308 + 1.5e308i, // finite, but |z| overflows
+		complex(-math.MaxFloat64, math.MaxFloat64),
+	} {
+		if _, err := c.Append(nil, z); err == nil {
+			t.Errorf("Append(%v) succeeded", z)
+		}
+		if _, err := c.EncodeSlice([]complex128{1, z}); err == nil {
+			t.Errorf("EncodeSlice with %v succeeded", z)
+		}
+	}
+
+	b, err := c.EncodeSlice([]complex128{1 + 1i, 0, -2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTruncated(t, b, func(b []byte) error {
+		_, _, err := c.DecodeSlice(b)
+		return err
+	})
+	one, err := c.Append(nil, 3-4i)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTruncated(t, one, func(b []byte) error {
+		_, _, err := c.Consume(b)
+		return err
+	})
+
+	// Corrupt input: magnitudes split never writes, and a phase step past
+	// the last one.
+	cfg := Config{MantissaBits: c.MagnitudeBits}
+	for _, mag := range []float64{math.Inf(1), math.NaN(), -2} {
+		bad := append(cfg.Append(nil, mag), 0)
+		if _, _, err := c.Consume(bad); err == nil {
+			t.Errorf("magnitude %g accepted by Consume", mag)
+		}
+		if _, _, err := c.DecodeSlice(append([]byte{1}, bad...)); err == nil {
+			t.Errorf("magnitude %g accepted by DecodeSlice", mag)
+		}
+	}
+	if _, _, err := c.Consume(append(cfg.Append(nil, 1), 0x80, 0x02)); err == nil {
+		t.Error("phase step 256 accepted with 8 phase bits")
+	}
+	if _, _, err := c.DecodeSlice([]byte{0xff, 0xff, 0x03, 0}); err == nil {
+		t.Error("oversized count accepted")
+	}
+
+	b, err = c.EncodeSlice(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _, err := c.DecodeSlice(b); err != nil || len(got) != 0 {
+		t.Errorf("DecodeSlice(empty) = %v, %v", got, err)
+	}
+}
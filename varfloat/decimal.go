@@ -0,0 +1,182 @@
+package varfloat
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MaxDecimalPlaces is the largest DecimalPlaces the decimal encoding accepts.
+const MaxDecimalPlaces = 18
+
+// AppendDecimal encodes v as a decimal number with the given number of
+// decimal places and appends it to dst.
+//
+// Prices and readings such as 21.37 are decimal fractions that no binary
+// mantissa holds exactly, so after a varfloat round trip they print as
+// 21.369999. Here v is first rounded to places decimals exactly as
+// strconv.FormatFloat(v, 'f', places, 64) does, and that decimal is written as
+// an integer mantissa and a base-10 exponent, with trailing zeros moved into
+// the exponent:
+//
+//	[varint mantissa][varint exponent]
+//
+// so 21.37 is (2137, -2) and 1200 is (12, 2), three bytes each. Decoding gives
+// the float64 nearest to that decimal, and AppendDecimal checks that
+// FormatFloat(decoded, 'f', places, 64) matches the original string, failing
+// otherwise (values with more than about 15 significant digits cannot
+// round-trip). Negative zero, including negative values that round to zero,
+// is kept as a zero mantissa with exponent 1.
+//
+// places must be in [0, MaxDecimalPlaces] and v must be finite.
+func AppendDecimal(dst []byte, v float64, places int) ([]byte, error) {
+	if places < 0 || places > MaxDecimalPlaces {
+		return nil, errors.New("varfloat: decimal places must be between 0 and 18")
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil, errors.New("varfloat: decimal encoding requires finite values")
+	}
+
+	s := strconv.FormatFloat(v, 'f', places, 64)
+	neg := strings.HasPrefix(s, "-")
+	digits := strings.Replace(strings.TrimPrefix(s, "-"), ".", "", 1)
+	exp := -places
+	digits = strings.TrimLeft(digits, "0")
+	for strings.HasSuffix(digits, "0") {
+		digits = digits[:len(digits)-1]
+		exp++
+	}
+
+	var mant int64
+	switch {
+	case digits == "":
+		// Zero: the exponent only records the sign.
+		exp = 0
+		if neg {
+			exp = 1
+		}
+	case len(digits) > 18:
+		return nil, errors.New("varfloat: value has too many significant digits for decimal encoding")
+	default:
+		m, err := strconv.ParseInt(digits, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		mant = m
+		if neg {
+			mant = -m
+		}
+	}
+
+	if strconv.FormatFloat(decimalValue(mant, exp), 'f', places, 64) != s {
+		return nil, errors.New("varfloat: value cannot round-trip at this many decimal places")
+	}
+	dst = binary.AppendVarint(dst, mant)
+	return binary.AppendVarint(dst, int64(exp)), nil
+}
+
+// ConsumeDecimal decodes a value written by AppendDecimal from the beginning
+// of b. It returns the decoded value and the number of bytes consumed. The
+// decimal places are not needed to decode.
+func ConsumeDecimal(b []byte) (float64, int, error) {
+	mant, n := binary.Varint(b)
+	if n <= 0 {
+		return 0, 0, errors.New("varfloat: invalid decimal mantissa")
+	}
+	exp, m := binary.Varint(b[n:])
+	if m <= 0 || exp < -400 || exp > 400 {
+		return 0, 0, errors.New("varfloat: invalid decimal exponent")
+	}
+	v := decimalValue(mant, int(exp))
+	if math.IsInf(v, 0) {
+		return 0, 0, errors.New("varfloat: decimal value overflows float64")
+	}
+	return v, n + m, nil
+}
+
+// decimalValue returns the float64 nearest to mant * 10^exp.
+func decimalValue(mant int64, exp int) float64 {
+	if mant == 0 {
+		if exp == 1 {
+			return math.Copysign(0, -1)
+		}
+		return 0
+	}
+	// With both operands exact, one multiplication or division is correctly
+	// rounded; otherwise let strconv do the rounding.
+	if mant > -1<<53 && mant < 1<<53 && exp >= -22 && exp <= 22 {
+		if exp < 0 {
+			return float64(mant) / math.Pow10(-exp)
+		}
+		return float64(mant) * math.Pow10(exp)
+	}
+	v, _ := strconv.ParseFloat(strconv.FormatInt(mant, 10)+"e"+strconv.Itoa(exp), 64)
+	return v
+}
+
+// EncodeDecimals encodes a slice of values with AppendDecimal, prefixed with
+// the slice length as a uvarint.
+func EncodeDecimals(values []float64, places int) ([]byte, error) {
+	out := binary.AppendUvarint(nil, uint64(len(values)))
+	for _, v := range values {
+		var err error
+		out, err = AppendDecimal(out, v, places)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// DecodeDecimals decodes a slice written by EncodeDecimals, returning the
+// values and the number of bytes consumed.
+func DecodeDecimals(b []byte) ([]float64, int, error) {
+	count, n := binary.Uvarint(b)
+	if n <= 0 {
+		return nil, 0, errors.New("varfloat: invalid slice length")
+	}
+	if count > uint64(len(b)-n)/2 {
+		return nil, 0, errors.New("varfloat: slice length exceeds buffer")
+	}
+	offset := n
+
+	out := make([]float64, 0, count)
+	for i := uint64(0); i < count; i++ {
+		v, used, err := ConsumeDecimal(b[offset:])
+		if err != nil {
+			return nil, 0, err
+		}
+		out = append(out, v)
+		offset += used
+	}
+	return out, offset, nil
+}
+
+// DecimalEncoder is a convenience wrapper that holds a number of decimal
+// places and exposes helpers for encoding single values or slices with
+// AppendDecimal. Decode with ConsumeDecimal or DecodeDecimals.
+type DecimalEncoder struct {
+	DecimalPlaces int
+}
+
+// NewDecimalEncoder constructs a DecimalEncoder for values with the given
+// number of decimal places, e.g. 2 for prices in cents.
+func NewDecimalEncoder(places int) (*DecimalEncoder, error) {
+	if places < 0 || places > MaxDecimalPlaces {
+		return nil, errors.New("varfloat: decimal places must be between 0 and 18")
+	}
+	return &DecimalEncoder{DecimalPlaces: places}, nil
+}
+
+// Encode encodes a single value rounded to the encoder's decimal places.
+func (e *DecimalEncoder) Encode(v float64) ([]byte, error) {
+	return AppendDecimal(nil, v, e.DecimalPlaces)
+}
+
+// EncodeSlice encodes a slice of values rounded to the encoder's decimal
+// places.
+func (e *DecimalEncoder) EncodeSlice(values []float64) ([]byte, error) {
+	return EncodeDecimals(values, e.DecimalPlaces)
+}
@@ -0,0 +1,129 @@
+package varfloat
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestDecimalRoundTrip(t *testing.T) {
+	tests := []struct {
+		v      float64
+		places int
+		size   int
+	}{
+		{21.37, 2, 3},
+		{1200, 2, 2},
+		{0.1, 1, 2},
+		{-19.99, 2, 3},
+		{123456789.123456, 6, 0},
+		{0, 3, 2},
+		{1e22, 0, 2},
+		{5e-18, 18, 2},
+		{0.30000000000000004, 2, 2},
+	}
+	for _, tt := range tests {
+		b, err := AppendDecimal(nil, tt.v, tt.places)
+		if err != nil {
+			t.Fatalf("AppendDecimal(%g, %d): %v", tt.v, tt.places, err)
+		}
+		if tt.size > 0 && len(b) != tt.size {
+			t.Errorf("%g at %d places took %d bytes, want %d", tt.v, tt.places, len(b), tt.size)
+		}
+		got, n, err := ConsumeDecimal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(b) {
+			t.Errorf("consumed %d of %d bytes", n, len(b))
+		}
+		want := strconv.FormatFloat(tt.v, 'f', tt.places, 64)
+		if s := strconv.FormatFloat(got, 'f', tt.places, 64); s != want {
+			t.Errorf("%g at %d places decoded as %s, want %s", tt.v, tt.places, s, want)
+		}
+	}
+}
+
+func TestDecimalNegativeZero(t *testing.T) {
+	for _, v := range []float64{math.Copysign(0, -1), -0.001} {
+		b, err := AppendDecimal(nil, v, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _, err := ConsumeDecimal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != 0 || !math.Signbit(got) {
+			t.Errorf("%g decoded as %g, want -0", v, got)
+		}
+	}
+}
+
+func TestDecimalsSlice(t *testing.T) {
+	e, err := NewDecimalEncoder(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := []float64{9.99, 10, 0.05, -3.5, 1e6}
+	b, err := e.EncodeSlice(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, n, err := DecodeDecimals(b)
+	if err != nil || n != len(b) {
+		t.Fatalf("DecodeDecimals = %v, %d, %v", got, n, err)
+	}
+	for i, v := range values {
+		if got[i] != v {
+			t.Errorf("%g decoded as %g", v, got[i])
+		}
+	}
+	checkTruncated(t, b, func(b []byte) error {
+		_, _, err := DecodeDecimals(b)
+		return err
+	})
+
+	b, err = e.EncodeSlice(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _, err := DecodeDecimals(b); err != nil || len(got) != 0 {
+		t.Errorf("DecodeDecimals(empty) = %v, %v", got, err)
+	}
+}
+
+func TestDecimalInvalid(t *testing.T) {
+	for _, places := range []int{-1, MaxDecimalPlaces + 1} {
+		if _, err := NewDecimalEncoder(places); err == nil {
+			t.Errorf("%d places accepted", places)
+		}
+		if _, err := AppendDecimal(nil, 1, places); err == nil {
+			t.Errorf("AppendDecimal with %d places succeeded", places)
+		}
+	}
+	for _, v := range []float64{math.NaN(), math.Inf(1), 1.2345678901234567e20} {
+		if _, err := AppendDecimal(nil, v, 2); err == nil {
+			t.Errorf("%g accepted", v)
+		}
+	}
+	// 1e300 prints with far more than 18 significant digits.
+	if _, err := AppendDecimal(nil, 1e300, 0); err == nil {
+		t.Error("1e300 at 0 places accepted")
+	}
+
+	for _, b := range [][]byte{
+		nil,
+		{0x80},
+		{2},
+		{2, 0xa0, 0x06},                         // exponent 400 on a mantissa of 1 overflows
+		{2, 0xa2, 0x06},                         // exponent 401
+		{0xff, 0xff, 0xff, 0xff, 0x0f, 2, 0, 0}, // oversized count
+	} {
+		if _, _, err := ConsumeDecimal(b); err == nil {
+			if _, _, err := DecodeDecimals(b); err == nil {
+				t.Errorf("% x decoded", b)
+			}
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package varfloat
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Slice formats written as the first byte of EncodeFloatsCompact output.
+const (
+	formatPlain   byte = iota // EncodeFloats payload
+	formatDict                // EncodeFloatsDict payload
+	formatEntropy             // EncodeFloatsEntropy payload
+)
+
+// EncodeFloatsDict encodes a slice of float64 values with the given mantissa
+// precision (bits) using dictionary encoding. After quantization, many
+// real-world columns (telemetry, sensor readings) only contain a few hundred
+// distinct values, so instead of writing every varfloat the encoder stores a
+// table of the distinct quantized values followed by bit-packed indexes:
+//
+//	[uvarint count][uvarint dictSize][dictSize varfloats][packed indexes...]
+//
+// Each index uses ceil(log2(dictSize)) bits. Decoding yields exactly the same
+// values as DecodeFloats would for the plain encoding.
+//
+// A one-entry dictionary writes no indexes, so nothing in the input bounds
+// its count; such slices are limited to 1<<24 values.
+func EncodeFloatsDict(values []float64, bits int) ([]byte, error) {
+	cfg, err := NewConfig(bits)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build the dictionary keyed by the encoded (quantized) bytes so values
+	// that quantize identically share an entry.
+	index := make(map[string]int)
+	var dict []byte
+	dictSize := 0
+	indexes := make([]int, 0, len(values))
+	var tmp []byte
+	for _, v := range values {
+		tmp = cfg.Append(tmp[:0], v)
+		i, ok := index[string(tmp)]
+		if !ok {
+			i = dictSize
+			index[string(tmp)] = i
+			dict = append(dict, tmp...)
+			dictSize++
+		}
+		indexes = append(indexes, i)
+	}
+	if dictSize == 1 && len(values) > maxImpliedValues {
+		return nil, errors.New("varfloat: too many values for a one-entry dictionary")
+	}
+
+	var buf [10]byte
+	out := make([]byte, 0, 20+len(dict)+packedLen(len(values), bitsForCount(dictSize)))
+	n := binary.PutUvarint(buf[:], uint64(len(values)))
+	out = append(out, buf[:n]...)
+	n = binary.PutUvarint(buf[:], uint64(dictSize))
+	out = append(out, buf[:n]...)
+	out = append(out, dict...)
+
+	width := bitsForCount(dictSize)
+	if width > 0 {
+		var w bitWriter
+		for _, i := range indexes {
+			w.writeBits(uint64(i), width)
+		}
+		out = append(out, w.bytes()...)
+	}
+	return out, nil
+}
+
+// DecodeFloatsDict decodes a slice of float64 values encoded by
+// EncodeFloatsDict using the same mantissa precision (bits).
+func DecodeFloatsDict(b []byte, bits int) ([]float64, int, error) {
+	cfg, err := NewConfig(bits)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	count, n := binary.Uvarint(b)
+	if n <= 0 {
+		return nil, 0, errors.New("varfloat: invalid slice length")
+	}
+	consumed := n
+
+	dictSize, n := binary.Uvarint(b[consumed:])
+	if n <= 0 {
+		return nil, 0, errors.New("varfloat: invalid dictionary size")
+	}
+	consumed += n
+	if dictSize == 0 && count > 0 {
+		return nil, 0, errors.New("varfloat: empty dictionary for non-empty slice")
+	}
+	if dictSize > uint64(len(b)) {
+		return nil, 0, errors.New("varfloat: dictionary size exceeds buffer")
+	}
+
+	dict := make([]float64, 0, dictSize)
+	for i := uint64(0); i < dictSize; i++ {
+		v, used, err := cfg.Consume(b[consumed:])
+		if err != nil {
+			return nil, 0, err
+		}
+		dict = append(dict, v)
+		consumed += used
+	}
+
+	width := bitsForCount(int(dictSize))
+	if width == 0 && count > maxImpliedValues {
+		// A one-entry dictionary writes no indexes, so the input does not
+		// bound the count.
+		return nil, 0, errors.New("varfloat: slice length is too large")
+	}
+	if width > 0 && count > uint64(len(b)-consumed)*8/uint64(width) {
+		return nil, 0, errors.New("varfloat: slice length exceeds buffer")
+	}
+	// Either the input or maxImpliedValues now bounds count, so it is safe
+	// to allocate for.
+	values := make([]float64, 0, count)
+	if width == 0 {
+		for i := uint64(0); i < count; i++ {
+			values = append(values, dict[0])
+		}
+		return values, consumed, nil
+	}
+
+	r := bitReader{b: b[consumed:]}
+	for i := uint64(0); i < count; i++ {
+		idx, err := r.readBits(width)
+		if err != nil {
+			return nil, 0, err
+		}
+		if idx >= dictSize {
+			return nil, 0, errors.New("varfloat: dictionary index out of range")
+		}
+		values = append(values, dict[idx])
+	}
+	consumed += packedLen(int(count), width)
+	return values, consumed, nil
+}
+
+// EncodeFloatsCompact encodes a slice of float64 values with the given
+// mantissa precision (bits), automatically choosing whichever slice format is
+// smallest for the data. The output starts with a 1-byte format tag followed
+// by the chosen payload, so DecodeFloatsCompact can decode it without knowing
+// which format was picked.
+//
+// Currently the candidates are the plain EncodeFloats format, the dictionary
+// format from EncodeFloatsDict and the entropy-coded format from
+// EncodeFloatsEntropy.
+func EncodeFloatsCompact(values []float64, bits int) ([]byte, error) {
+	plain, err := EncodeFloats(values, bits)
+	if err != nil {
+		return nil, err
+	}
+	format, payload := formatPlain, plain
+
+	// The dictionary format is skipped for slices too long for it.
+	dict, err := EncodeFloatsDict(values, bits)
+	if err == nil && len(dict) < len(payload) {
+		format, payload = formatDict, dict
+	}
+
+	entropy, err := EncodeFloatsEntropy(values, bits)
+	if err != nil {
+		return nil, err
+	}
+	if len(entropy) < len(payload) {
+		format, payload = formatEntropy, entropy
+	}
+
+	out := make([]byte, 0, 1+len(payload))
+	out = append(out, format)
+	out = append(out, payload...)
+	return out, nil
+}
+
+// DecodeFloatsCompact decodes a slice of float64 values encoded by
+// EncodeFloatsCompact using the same mantissa precision (bits). It returns the
+// decoded values and the number of bytes consumed, including the format tag.
+func DecodeFloatsCompact(b []byte, bits int) ([]float64, int, error) {
+	if len(b) == 0 {
+		return nil, 0, errors.New("varfloat: empty buffer for DecodeFloatsCompact")
+	}
+
+	var (
+		values []float64
+		n      int
+		err    error
+	)
+	switch b[0] {
+	case formatPlain:
+		values, n, err = DecodeFloats(b[1:], bits)
+	case formatDict:
+		values, n, err = DecodeFloatsDict(b[1:], bits)
+	case formatEntropy:
+		values, n, err = DecodeFloatsEntropy(b[1:], bits)
+	default:
+		return nil, 0, errors.New("varfloat: unknown slice format")
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return values, n + 1, nil
+}
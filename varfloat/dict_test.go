@@ -0,0 +1,106 @@
+package varfloat
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFloatsDictRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+	}{
+		{"empty", nil},
+		{"single", []float64{4.5}},
+		{"constant", []float64{7, 7, 7, 7, 7}},
+		{"few distinct", []float64{1, 2, 3, 1, 2, 3, 0, -1, 0}},
+		{"random", sampleFloats(500, 1)},
+	}
+	for _, tt := range tests {
+		for _, bits := range []int{0, 6, 20, 52} {
+			b, err := EncodeFloatsDict(tt.values, bits)
+			if err != nil {
+				t.Fatalf("%s/%d: %v", tt.name, bits, err)
+			}
+			got, n, err := DecodeFloatsDict(b, bits)
+			if err != nil {
+				t.Fatalf("%s/%d: %v", tt.name, bits, err)
+			}
+			if n != len(b) {
+				t.Errorf("%s/%d: consumed %d of %d bytes", tt.name, bits, n, len(b))
+			}
+			plain, _ := EncodeFloats(tt.values, bits)
+			want, _, _ := DecodeFloats(plain, bits)
+			if !slices.Equal(got, want) {
+				t.Errorf("%s/%d: got %v, want %v", tt.name, bits, got, want)
+			}
+		}
+	}
+}
+
+func TestFloatsDictCorrupt(t *testing.T) {
+	b, err := EncodeFloatsDict([]float64{1, 2, 3, 1, 2}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTruncated(t, b, func(b []byte) error {
+		_, _, err := DecodeFloatsDict(b, 10)
+		return err
+	})
+
+	// Three entries use 2-bit indexes, so index 3 is out of range.
+	bad := slices.Clone(b)
+	bad[len(bad)-2] = 0xff
+	if _, _, err := DecodeFloatsDict(bad, 10); err == nil {
+		t.Error("out-of-range index accepted")
+	}
+
+	// A huge count must be rejected before allocating.
+	if _, _, err := DecodeFloatsDict([]byte{0xff, 0xff, 0xff, 0xff, 0x0f, 2, 0, 0}, 10); err == nil {
+		t.Error("oversized count accepted")
+	}
+
+	// A one-entry dictionary writes no indexes, so only the fixed cap bounds
+	// these counts.
+	for _, b := range [][]byte{
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01, 0x01, 0x00},
+		{0x80, 0x80, 0x80, 0x80, 0x20, 0x01, 0x00},
+	} {
+		if _, _, err := DecodeFloatsDict(b, 10); err == nil {
+			t.Errorf("DecodeFloatsDict(% x) succeeded", b)
+		}
+		if _, _, err := DecodeFloatsCompact(append([]byte{formatDict}, b...), 10); err == nil {
+			t.Errorf("DecodeFloatsCompact(% x) succeeded", b)
+		}
+	}
+}
+
+func TestFloatsCompactPicksSmallest(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		format byte
+	}{
+		{"empty", nil, formatPlain},
+		{"low cardinality", slices.Repeat([]float64{1, 2, 3, 4}, 100), formatDict},
+	}
+	for _, tt := range tests {
+		b, err := EncodeFloatsCompact(tt.values, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b[0] != tt.format {
+			t.Errorf("%s: format %d, want %d", tt.name, b[0], tt.format)
+		}
+		got, n, err := DecodeFloatsCompact(b, 10)
+		if err != nil || n != len(b) || len(got) != len(tt.values) {
+			t.Errorf("%s: DecodeFloatsCompact = %d values, %d bytes, %v", tt.name, len(got), n, err)
+		}
+	}
+	if _, _, err := DecodeFloatsCompact([]byte{99, 0}, 10); err == nil {
+		t.Error("unknown format accepted")
+	}
+	if _, _, err := DecodeFloatsCompact(nil, 10); err == nil {
+		t.Error("empty buffer accepted")
+	}
+}
@@ -0,0 +1,360 @@
+package varfloat
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"sort"
+)
+
+const (
+	// entropyMantHighBits is how many of the most significant mantissa bits
+	// are folded into each entropy-coded symbol together with the header.
+	entropyMantHighBits = 4
+
+	// entropyMaxCodeLen bounds the length of a single Huffman code.
+	entropyMaxCodeLen = 24
+)
+
+// EncodeFloatsEntropy encodes a slice of float64 values with the given
+// mantissa precision (bits), entropy-coding the parts of each varfloat that
+// tend to repeat.
+//
+// In the plain encoding every value spends at least one byte on its
+// sign/exponent header, even when the exponents of a slice cluster tightly
+// (which they almost always do). Here each value is split into a symbol made
+// of its header plus the top few mantissa bits, and the remaining low mantissa
+// bits. Symbols are Huffman coded with a static model built from the slice;
+// low bits are written raw. The layout is:
+//
+//	[uvarint count][uvarint numSymbols][(uvarint symbolDelta, 1-byte codeLen)...]
+//	[uvarint packedLen][packed codes and low bits...]
+//
+// The model is stored up front, so the payload is self-contained and can be
+// used for stream chunks (see FloatStreamEncoder.Entropy). Decoding yields
+// exactly the same values as the plain encoding.
+func EncodeFloatsEntropy(values []float64, bits int) ([]byte, error) {
+	cfg, err := NewConfig(bits)
+	if err != nil {
+		return nil, err
+	}
+	high := entropyHighBits(bits)
+	lowBits := bits - high
+
+	symbols := make([]uint64, len(values))
+	lows := make([]uint64, len(values))
+	freqs := make(map[uint64]int)
+	for i, v := range values {
+		header, mant := cfg.split(v)
+		sym := uint64(0)
+		if header != 0 {
+			sym = header<<uint(high) | mant>>uint(lowBits)
+			lows[i] = mant & (1<<uint(lowBits) - 1)
+		}
+		symbols[i] = sym
+		freqs[sym]++
+	}
+
+	lengths := huffmanLengths(freqs)
+	codes := canonicalCodes(lengths)
+
+	var buf [10]byte
+	var out []byte
+	n := binary.PutUvarint(buf[:], uint64(len(values)))
+	out = append(out, buf[:n]...)
+
+	// Model: symbols in ascending order, delta-coded, with their code lengths.
+	syms := make([]uint64, 0, len(lengths))
+	for sym := range lengths {
+		syms = append(syms, sym)
+	}
+	sort.Slice(syms, func(i, j int) bool { return syms[i] < syms[j] })
+	n = binary.PutUvarint(buf[:], uint64(len(syms)))
+	out = append(out, buf[:n]...)
+	prev := uint64(0)
+	for _, sym := range syms {
+		n = binary.PutUvarint(buf[:], sym-prev)
+		out = append(out, buf[:n]...)
+		out = append(out, byte(lengths[sym]))
+		prev = sym
+	}
+
+	var w bitWriter
+	for i, sym := range symbols {
+		c := codes[sym]
+		w.writeBits(c.code, c.length)
+		if sym != 0 {
+			w.writeBits(lows[i], lowBits)
+		}
+	}
+	packed := w.bytes()
+	n = binary.PutUvarint(buf[:], uint64(len(packed)))
+	out = append(out, buf[:n]...)
+	out = append(out, packed...)
+	return out, nil
+}
+
+// DecodeFloatsEntropy decodes a slice of float64 values encoded by
+// EncodeFloatsEntropy using the same mantissa precision (bits). It returns the
+// decoded values and the number of bytes consumed.
+func DecodeFloatsEntropy(b []byte, bits int) ([]float64, int, error) {
+	cfg, err := NewConfig(bits)
+	if err != nil {
+		return nil, 0, err
+	}
+	high := entropyHighBits(bits)
+	lowBits := bits - high
+
+	count, n := binary.Uvarint(b)
+	if n <= 0 {
+		return nil, 0, errors.New("varfloat: invalid slice length")
+	}
+	offset := n
+
+	numSyms, n := binary.Uvarint(b[offset:])
+	if n <= 0 {
+		return nil, 0, errors.New("varfloat: invalid entropy model")
+	}
+	offset += n
+	if numSyms > uint64(len(b)) {
+		return nil, 0, errors.New("varfloat: invalid entropy model")
+	}
+
+	lengths := make(map[uint64]int, numSyms)
+	sym := uint64(0)
+	for i := uint64(0); i < numSyms; i++ {
+		delta, n := binary.Uvarint(b[offset:])
+		if n <= 0 || offset+n >= len(b) {
+			return nil, 0, errors.New("varfloat: invalid entropy model")
+		}
+		offset += n
+		sym += delta
+		length := int(b[offset])
+		offset++
+		if length < 1 || length > entropyMaxCodeLen {
+			return nil, 0, errors.New("varfloat: invalid entropy code length")
+		}
+		lengths[sym] = length
+	}
+	dec := newHuffmanDecoder(lengths)
+
+	packedLen, n := binary.Uvarint(b[offset:])
+	if n <= 0 {
+		return nil, 0, errors.New("varfloat: invalid entropy payload length")
+	}
+	offset += n
+	if packedLen > uint64(len(b)-offset) {
+		return nil, 0, errors.New("varfloat: entropy payload exceeds buffer")
+	}
+
+	// Every code is at least one bit long.
+	if count > packedLen*8 {
+		return nil, 0, errors.New("varfloat: slice length exceeds buffer")
+	}
+
+	r := bitReader{b: b[offset : offset+int(packedLen)]}
+	values := make([]float64, 0, count)
+	for i := uint64(0); i < count; i++ {
+		sym, err := dec.decode(&r)
+		if err != nil {
+			return nil, 0, err
+		}
+		if sym == 0 {
+			values = append(values, 0)
+			continue
+		}
+		low, err := r.readBits(lowBits)
+		if err != nil {
+			return nil, 0, err
+		}
+		header := sym >> uint(high)
+		mant := (sym&(1<<uint(high)-1))<<uint(lowBits) | low
+		v, err := cfg.join(header, mant)
+		if err != nil {
+			return nil, 0, err
+		}
+		values = append(values, v)
+	}
+	return values, offset + int(packedLen), nil
+}
+
+// entropyHighBits returns how many mantissa bits are folded into symbols for a
+// given mantissa precision.
+func entropyHighBits(bits int) int {
+	if bits < entropyMantHighBits {
+		return bits
+	}
+	return entropyMantHighBits
+}
+
+// huffmanCode is a single canonical Huffman code.
+type huffmanCode struct {
+	code   uint64
+	length int
+}
+
+// huffmanNode is a node in the tree built by huffmanLengths. Leaves have
+// left == right == -1.
+type huffmanNode struct {
+	weight      int
+	sym         uint64
+	left, right int
+}
+
+// huffmanHeap is a min-heap of node indexes ordered by weight.
+type huffmanHeap struct {
+	nodes []huffmanNode
+	idx   []int
+}
+
+func (h *huffmanHeap) Len() int { return len(h.idx) }
+func (h *huffmanHeap) Less(i, j int) bool {
+	a, b := h.nodes[h.idx[i]], h.nodes[h.idx[j]]
+	if a.weight != b.weight {
+		return a.weight < b.weight
+	}
+	return h.idx[i] < h.idx[j]
+}
+func (h *huffmanHeap) Swap(i, j int) { h.idx[i], h.idx[j] = h.idx[j], h.idx[i] }
+func (h *huffmanHeap) Push(x any)    { h.idx = append(h.idx, x.(int)) }
+func (h *huffmanHeap) Pop() any {
+	x := h.idx[len(h.idx)-1]
+	h.idx = h.idx[:len(h.idx)-1]
+	return x
+}
+
+// huffmanLengths computes Huffman code lengths for the given symbol
+// frequencies, limited to entropyMaxCodeLen. A lone symbol gets length 1.
+func huffmanLengths(freqs map[uint64]int) map[uint64]int {
+	lengths := make(map[uint64]int, len(freqs))
+	if len(freqs) == 0 {
+		return lengths
+	}
+	if len(freqs) == 1 {
+		for sym := range freqs {
+			lengths[sym] = 1
+		}
+		return lengths
+	}
+
+	syms := make([]uint64, 0, len(freqs))
+	for sym := range freqs {
+		syms = append(syms, sym)
+	}
+	sort.Slice(syms, func(i, j int) bool { return syms[i] < syms[j] })
+	weights := make([]int, len(syms))
+	for i, sym := range syms {
+		weights[i] = freqs[sym]
+	}
+
+	for {
+		h := &huffmanHeap{}
+		for i, sym := range syms {
+			h.nodes = append(h.nodes, huffmanNode{weight: weights[i], sym: sym, left: -1, right: -1})
+			h.idx = append(h.idx, i)
+		}
+		heap.Init(h)
+		for h.Len() > 1 {
+			a := heap.Pop(h).(int)
+			b := heap.Pop(h).(int)
+			h.nodes = append(h.nodes, huffmanNode{
+				weight: h.nodes[a].weight + h.nodes[b].weight,
+				left:   a,
+				right:  b,
+			})
+			heap.Push(h, len(h.nodes)-1)
+		}
+
+		maxLen := 0
+		var walk func(node, depth int)
+		walk = func(node, depth int) {
+			nd := h.nodes[node]
+			if nd.left < 0 {
+				lengths[nd.sym] = depth
+				if depth > maxLen {
+					maxLen = depth
+				}
+				return
+			}
+			walk(nd.left, depth+1)
+			walk(nd.right, depth+1)
+		}
+		walk(h.idx[0], 0)
+		if maxLen <= entropyMaxCodeLen {
+			return lengths
+		}
+
+		// Flatten the distribution and try again until codes fit.
+		for i := range weights {
+			weights[i] = (weights[i] + 1) / 2
+		}
+	}
+}
+
+// canonicalCodes assigns canonical Huffman codes from code lengths: codes are
+// ordered by (length, symbol), so only the lengths need to be stored.
+func canonicalCodes(lengths map[uint64]int) map[uint64]huffmanCode {
+	syms := sortedByLength(lengths)
+	codes := make(map[uint64]huffmanCode, len(syms))
+	code := uint64(0)
+	prevLen := 0
+	for _, sym := range syms {
+		length := lengths[sym]
+		code <<= uint(length - prevLen)
+		codes[sym] = huffmanCode{code: code, length: length}
+		code++
+		prevLen = length
+	}
+	return codes
+}
+
+// sortedByLength returns the symbols of lengths ordered by (length, symbol).
+func sortedByLength(lengths map[uint64]int) []uint64 {
+	syms := make([]uint64, 0, len(lengths))
+	for sym := range lengths {
+		syms = append(syms, sym)
+	}
+	sort.Slice(syms, func(i, j int) bool {
+		li, lj := lengths[syms[i]], lengths[syms[j]]
+		if li != lj {
+			return li < lj
+		}
+		return syms[i] < syms[j]
+	})
+	return syms
+}
+
+// huffmanDecoder decodes canonical Huffman codes one bit at a time.
+type huffmanDecoder struct {
+	counts  [entropyMaxCodeLen + 1]int
+	symbols []uint64
+}
+
+func newHuffmanDecoder(lengths map[uint64]int) *huffmanDecoder {
+	d := &huffmanDecoder{symbols: sortedByLength(lengths)}
+	for _, length := range lengths {
+		d.counts[length]++
+	}
+	return d
+}
+
+// decode reads the next symbol from r.
+func (d *huffmanDecoder) decode(r *bitReader) (uint64, error) {
+	code, first, index := 0, 0, 0
+	for length := 1; length <= entropyMaxCodeLen; length++ {
+		bit, err := r.readBits(1)
+		if err != nil {
+			return 0, err
+		}
+		code |= int(bit)
+		count := d.counts[length]
+		if code-count < first {
+			return d.symbols[index+(code-first)], nil
+		}
+		index += count
+		first += count
+		first <<= 1
+		code <<= 1
+	}
+	return 0, errors.New("varfloat: invalid entropy code")
+}
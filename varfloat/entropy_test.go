@@ -0,0 +1,94 @@
+package varfloat
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestFloatsEntropyRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+	}{
+		{"empty", nil},
+		{"zeros", []float64{0, 0, 0}},
+		{"single", []float64{-3.5}},
+		{"signed zeros", []float64{0, math.Copysign(0, -1), 1}},
+		{"clustered", []float64{100, 101, 102, 103, 100.5, 99, 98}},
+		{"random", sampleFloats(1000, 2)},
+	}
+	for _, tt := range tests {
+		for _, bits := range []int{0, 3, 10, 30, 52} {
+			b, err := EncodeFloatsEntropy(tt.values, bits)
+			if err != nil {
+				t.Fatalf("%s/%d: %v", tt.name, bits, err)
+			}
+			got, n, err := DecodeFloatsEntropy(b, bits)
+			if err != nil {
+				t.Fatalf("%s/%d: %v", tt.name, bits, err)
+			}
+			if n != len(b) {
+				t.Errorf("%s/%d: consumed %d of %d bytes", tt.name, bits, n, len(b))
+			}
+			plain, _ := EncodeFloats(tt.values, bits)
+			want, _, _ := DecodeFloats(plain, bits)
+			if !slices.EqualFunc(got, want, sameFloat) {
+				t.Errorf("%s/%d: got %v, want %v", tt.name, bits, got, want)
+			}
+		}
+	}
+}
+
+func TestFloatsEntropyCorrupt(t *testing.T) {
+	b, err := EncodeFloatsEntropy(sampleFloats(50, 3), 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTruncated(t, b, func(b []byte) error {
+		_, _, err := DecodeFloatsEntropy(b, 12)
+		return err
+	})
+
+	// count=2^35, one symbol with a 1-bit code, one payload byte.
+	huge := []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x01, 1, 0, 1, 1, 0}
+	if _, _, err := DecodeFloatsEntropy(huge, 12); err == nil {
+		t.Error("oversized count accepted")
+	}
+
+	// A code length of zero is invalid.
+	if _, _, err := DecodeFloatsEntropy([]byte{1, 1, 0, 0, 1, 0}, 12); err == nil {
+		t.Error("zero code length accepted")
+	}
+}
+
+func TestFloatStreamEntropy(t *testing.T) {
+	chunks := [][]float64{sampleFloats(200, 4), nil, {1, 2, 3}}
+	var buf bytes.Buffer
+	enc := NewFloatStreamEncoder(&buf)
+	enc.Entropy = true
+	for i, c := range chunks {
+		if err := enc.WriteChunk(c, 8+i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dec := NewFloatStreamDecoder(&buf)
+	for i, c := range chunks {
+		got, bits, err := dec.ReadChunk()
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain, _ := EncodeFloats(c, 8+i)
+		want, _, _ := DecodeFloats(plain, 8+i)
+		if bits != 8+i || !slices.Equal(got, want) {
+			t.Errorf("chunk %d: got %v at %d bits, want %v", i, got, bits, want)
+		}
+	}
+	if _, _, err := dec.ReadChunk(); !errors.Is(err, io.EOF) {
+		t.Errorf("after last chunk: %v, want io.EOF", err)
+	}
+}
@@ -0,0 +1,174 @@
+package varfloat
+
+import (
+	"math"
+	"sync"
+)
+
+// ErrorHistogramBuckets is the number of buckets in ErrorReport.Histogram.
+const ErrorHistogramBuckets = 54
+
+// ErrorStats collects statistics about the precision lost while encoding, so
+// it can be monitored in production. Attach one to a Config, FloatEncoder,
+// Vec3Encoder, BoxVec3Codec or stream encoder through its ErrorStats field;
+// every value encoded from then on is recorded.
+//
+// The error of each value is worked out from its quantized form while it is
+// being encoded, so no decode pass is needed. Vectors are recorded one
+// component at a time. Non-finite values are not recorded.
+//
+// An ErrorStats is safe for concurrent use, so one collector can be shared by
+// several encoders. The zero value is ready to use.
+type ErrorStats struct {
+	mu sync.Mutex
+
+	count     int
+	clamped   int
+	maxAbs    float64
+	maxRel    float64
+	sumSqAbs  float64
+	sumSqRel  float64
+	relCount  int
+	histogram [ErrorHistogramBuckets]int
+}
+
+// ErrorReport is a snapshot of an ErrorStats.
+type ErrorReport struct {
+	// Count is the number of values recorded.
+	Count int
+	// Clamped is the number of values that were outside the encodable range
+	// and were clamped to it (only BoxVec3Codec clamps).
+	Clamped int
+
+	MaxAbsError float64
+	RMSAbsError float64
+	// MaxRelError and RMSRelError only cover non-zero originals.
+	MaxRelError float64
+	RMSRelError float64
+
+	// Histogram counts values by relative error. Histogram[0] counts values
+	// that were encoded exactly; Histogram[k] for k >= 1 counts values whose
+	// relative error is in [2^-k, 2^-(k-1)), with the last bucket also
+	// holding anything smaller and Histogram[1] anything of 1 or more
+	// (including non-zero decodings of zero).
+	Histogram [ErrorHistogramBuckets]int
+}
+
+// Report returns the statistics collected so far.
+func (s *ErrorStats) Report() ErrorReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := ErrorReport{
+		Count:       s.count,
+		Clamped:     s.clamped,
+		MaxAbsError: s.maxAbs,
+		MaxRelError: s.maxRel,
+		Histogram:   s.histogram,
+	}
+	if s.count > 0 {
+		r.RMSAbsError = math.Sqrt(s.sumSqAbs / float64(s.count))
+	}
+	if s.relCount > 0 {
+		r.RMSRelError = math.Sqrt(s.sumSqRel / float64(s.relCount))
+	}
+	return r
+}
+
+// Reset clears the statistics, e.g. at the start of a reporting interval.
+func (s *ErrorStats) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.count, s.clamped = 0, 0
+	s.maxAbs, s.maxRel = 0, 0
+	s.sumSqAbs, s.sumSqRel, s.relCount = 0, 0, 0
+	s.histogram = [ErrorHistogramBuckets]int{}
+}
+
+// observe records one value and its quantized form. It is a no-op on a nil
+// receiver so callers need not check whether stats are attached.
+func (s *ErrorStats) observe(orig, quant float64, clamped bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observeLocked(orig, quant, clamped)
+}
+
+// observeFloats records values quantized as varfloats with the given mantissa
+// bits.
+func (s *ErrorStats) observeFloats(values []float64, bits int) {
+	if s == nil {
+		return
+	}
+	c := Config{MantissaBits: bits}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range values {
+		s.observeLocked(v, c.quantize(v), false)
+	}
+}
+
+// observeVec3Shared records vectors quantized by AppendVec3Shared with the
+// given mantissa bits.
+func (s *ErrorStats) observeVec3Shared(vs []Vec3, bits int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range vs {
+		q := quantizeVec3Shared(v, bits)
+		s.observeLocked(v.X, q.X, false)
+		s.observeLocked(v.Y, q.Y, false)
+		s.observeLocked(v.Z, q.Z, false)
+	}
+}
+
+// observeLocked records one value; s.mu must be held.
+func (s *ErrorStats) observeLocked(orig, quant float64, clamped bool) {
+	if math.IsNaN(orig) || math.IsInf(orig, 0) {
+		return
+	}
+	s.count++
+	if clamped {
+		s.clamped++
+	}
+
+	abs := math.Abs(quant - orig)
+	s.maxAbs = math.Max(s.maxAbs, abs)
+	s.sumSqAbs += abs * abs
+
+	if abs == 0 {
+		s.histogram[0]++
+		if orig != 0 {
+			s.relCount++
+		}
+		return
+	}
+	if orig == 0 {
+		s.histogram[1]++
+		return
+	}
+	rel := abs / math.Abs(orig)
+	s.maxRel = math.Max(s.maxRel, rel)
+	s.sumSqRel += rel * rel
+	s.relCount++
+
+	// rel in [2^-k, 2^-(k-1)) has Frexp exponent 1-k.
+	_, e := math.Frexp(rel)
+	k := min(max(1-e, 1), ErrorHistogramBuckets-1)
+	s.histogram[k]++
+}
+
+// quantize returns the value v decodes to after encoding with c.
+func (c Config) quantize(v float64) float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return v
+	}
+	q, err := c.join(c.split(v))
+	if err != nil {
+		return v
+	}
+	return q
+}
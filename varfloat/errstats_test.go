@@ -0,0 +1,170 @@
+package varfloat
+
+import (
+	"bytes"
+	"math"
+	"sync"
+	"testing"
+)
+
+// expectedReport builds the report ErrorStats should give for values
+// quantized with bits.
+func expectedReport(values []float64, bits int) ErrorReport {
+	c := Config{MantissaBits: bits}
+	var r ErrorReport
+	sumSqAbs, sumSqRel, relCount := 0.0, 0.0, 0
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			continue
+		}
+		r.Count++
+		abs := math.Abs(c.quantize(v) - v)
+		r.MaxAbsError = math.Max(r.MaxAbsError, abs)
+		sumSqAbs += abs * abs
+		if v != 0 {
+			rel := abs / math.Abs(v)
+			r.MaxRelError = math.Max(r.MaxRelError, rel)
+			sumSqRel += rel * rel
+			relCount++
+		}
+	}
+	if r.Count > 0 {
+		r.RMSAbsError = math.Sqrt(sumSqAbs / float64(r.Count))
+	}
+	if relCount > 0 {
+		r.RMSRelError = math.Sqrt(sumSqRel / float64(relCount))
+	}
+	return r
+}
+
+func checkReport(t *testing.T, got, want ErrorReport) {
+	t.Helper()
+	if got.Count != want.Count || got.Clamped != want.Clamped ||
+		got.MaxAbsError != want.MaxAbsError || got.MaxRelError != want.MaxRelError ||
+		math.Abs(got.RMSAbsError-want.RMSAbsError) > 1e-12*want.RMSAbsError ||
+		math.Abs(got.RMSRelError-want.RMSRelError) > 1e-12*want.RMSRelError {
+		t.Errorf("report %+v, want %+v", got, want)
+	}
+	total := 0
+	for _, n := range got.Histogram {
+		total += n
+	}
+	if total != got.Count {
+		t.Errorf("histogram holds %d values, report counts %d", total, got.Count)
+	}
+}
+
+func TestErrorStatsEncoders(t *testing.T) {
+	values := append(sampleFloats(300, 31), 0, 1, -2, math.NaN(), math.Inf(1))
+	const bits = 9
+
+	var s ErrorStats
+	c := Config{MantissaBits: bits, ErrorStats: &s}
+	for _, v := range values {
+		c.Append(nil, v)
+	}
+	checkReport(t, s.Report(), expectedReport(values, bits))
+
+	s.Reset()
+	if r := s.Report(); r.Count != 0 || r.MaxAbsError != 0 || r.Histogram != ([ErrorHistogramBuckets]int{}) {
+		t.Errorf("report after Reset = %+v", r)
+	}
+
+	finite := values[:len(values)-2]
+	enc := FloatEncoder{Bits: bits, ErrorStats: &s}
+	if _, err := enc.EncodeSlice(finite); err != nil {
+		t.Fatal(err)
+	}
+	checkReport(t, s.Report(), expectedReport(finite, bits))
+
+	s.Reset()
+	var buf bytes.Buffer
+	stream := NewFloatStreamEncoder(&buf)
+	stream.ErrorStats = &s
+	if err := stream.WriteChunk(finite[:100], bits); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.WriteChunk(finite[100:], bits); err != nil {
+		t.Fatal(err)
+	}
+	checkReport(t, s.Report(), expectedReport(finite, bits))
+
+	s.Reset()
+	vs := []Vec3{{X: 1, Y: -2.5, Z: 1e-3}, {X: 300, Y: 0, Z: 7}}
+	venc := Vec3Encoder{Bits: bits, ErrorStats: &s}
+	if _, err := venc.EncodeSlice(vs); err != nil {
+		t.Fatal(err)
+	}
+	checkReport(t, s.Report(), expectedReport(vecFlatten(vs), bits))
+}
+
+func TestErrorStatsHistogram(t *testing.T) {
+	var s ErrorStats
+	s.observe(1, 1, false)                    // exact
+	s.observe(0, 1e-9, false)                 // non-zero decoding of zero
+	s.observe(1, 1.75, false)                 // 0.75 in [2^-1, 1)
+	s.observe(8, 8.25, false)                 // 2^-5 in [2^-5, 2^-4)
+	s.observe(math.Nextafter(2, 0), 2, false) // one ulp, in the last bucket
+	s.observe(10, 30, true)                   // 2 clamps into bucket 1
+	s.observe(math.NaN(), 1, false)           // not recorded
+	s.observe(math.Inf(-1), 1, false)         // not recorded
+	r := s.Report()
+
+	want := map[int]int{0: 1, 1: 3, 5: 1, ErrorHistogramBuckets - 1: 1}
+	for k, n := range r.Histogram {
+		if n != want[k] {
+			t.Errorf("Histogram[%d] = %d, want %d", k, n, want[k])
+		}
+	}
+	if r.Count != 6 || r.Clamped != 1 {
+		t.Errorf("Count %d, Clamped %d; want 6, 1", r.Count, r.Clamped)
+	}
+	if r.MaxRelError != 2 || r.MaxAbsError != 20 {
+		t.Errorf("MaxRelError %g, MaxAbsError %g; want 2, 20", r.MaxRelError, r.MaxAbsError)
+	}
+
+	// A nil collector is a no-op.
+	var nilStats *ErrorStats
+	nilStats.observe(1, 2, false)
+	nilStats.observeFloats([]float64{1}, 4)
+	nilStats.observeVec3Shared([]Vec3{{X: 1}}, 4)
+}
+
+func TestErrorStatsBoxClamped(t *testing.T) {
+	var s ErrorStats
+	box, err := NewBoxVec3CodecMaxError(AABB{Max: Vec3{X: 10, Y: 10, Z: 10}}, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	box.ErrorStats = &s
+	for _, v := range []Vec3{{X: 1, Y: 2, Z: 3}, {X: -1, Y: 5, Z: 11}} {
+		if _, err := box.Append(nil, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := s.Report()
+	if r.Count != 6 || r.Clamped != 2 {
+		t.Errorf("Count %d, Clamped %d; want 6, 2", r.Count, r.Clamped)
+	}
+}
+
+func TestErrorStatsConcurrent(t *testing.T) {
+	var s ErrorStats
+	values := sampleFloats(100, 32)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := Config{MantissaBits: 12, ErrorStats: &s}
+			for _, v := range values {
+				c.Append(nil, v)
+			}
+			s.Report()
+		}()
+	}
+	wg.Wait()
+	if r := s.Report(); r.Count != 800 {
+		t.Errorf("Count = %d, want 800", r.Count)
+	}
+}
@@ -0,0 +1,241 @@
+package varfloat
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// RoundingMode selects how FixedPoint rounds a scaled value to an integer.
+type RoundingMode uint8
+
+const (
+	// RoundNearest rounds to the nearest integer, halves away from zero.
+	RoundNearest RoundingMode = iota
+	// RoundHalfEven rounds to the nearest integer, halves to even.
+	RoundHalfEven
+	// RoundDown rounds toward negative infinity.
+	RoundDown
+	// RoundUp rounds toward positive infinity.
+	RoundUp
+	// RoundTowardZero truncates.
+	RoundTowardZero
+)
+
+// FixedPoint encodes floats that are really fixed-point numbers, such as
+// millimetres (Scale 1000 on values in metres) or centidegrees (Scale 100 on
+// values in degrees).
+//
+// Each value is multiplied by Scale, rounded to an integer with Rounding and
+// written as a range-relative bounded int (AppendIntAuto) over the integers
+// covering [Min, Max], so a range of N steps costs about log2(N) bits plus
+// varint overhead, whatever the magnitude of Min. Values are decoded as
+// n/Scale, i.e. they land exactly on the fixed-point grid.
+//
+// Scaled values within a few ulps of an integer are taken as that integer
+// before rounding, so 0.29 at Scale 100 is 29 under every rounding mode even
+// though 0.29*100 is 28.999999999999996 in float64.
+//
+// Min*Scale and Max*Scale must lie within ±2^49. Beyond that the few-ulp
+// snap would reach half a step and every rounding mode would round to
+// nearest.
+//
+// Values outside [Min, Max] are rejected.
+type FixedPoint struct {
+	Scale    float64
+	Min, Max float64
+	Rounding RoundingMode
+}
+
+// maxFixedPointScaled bounds |Min*Scale| and |Max*Scale|. Below it a float64
+// step is at most 2^-4, so the 4-ulp snap in round stays within a quarter of
+// an integer step.
+const maxFixedPointScaled = 1 << 49
+
+// NewFixedPoint creates a FixedPoint codec for values in [min, max] with the
+// given scale (steps per unit) and rounding mode.
+func NewFixedPoint(scale, min, max float64, rounding RoundingMode) (*FixedPoint, error) {
+	f := &FixedPoint{Scale: scale, Min: min, Max: max, Rounding: rounding}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Validate checks that the codec's parameters are usable.
+func (f *FixedPoint) Validate() error {
+	if !(f.Scale > 0) || math.IsInf(f.Scale, 0) {
+		return errors.New("varfloat: fixed-point scale must be > 0 and finite")
+	}
+	if math.IsNaN(f.Min) || math.IsInf(f.Min, 0) || math.IsNaN(f.Max) || math.IsInf(f.Max, 0) {
+		return errors.New("varfloat: fixed-point bounds must be finite")
+	}
+	if f.Min > f.Max {
+		return errors.New("varfloat: min must be <= max")
+	}
+	if f.Rounding > RoundTowardZero {
+		return errors.New("varfloat: unknown rounding mode")
+	}
+	if !(math.Abs(f.Min*f.Scale) <= maxFixedPointScaled && math.Abs(f.Max*f.Scale) <= maxFixedPointScaled) {
+		return errors.New("varfloat: fixed-point range is too large for its scale")
+	}
+	return nil
+}
+
+// Resolution returns the spacing of the fixed-point grid, 1/Scale.
+func (f *FixedPoint) Resolution() float64 {
+	return 1 / f.Scale
+}
+
+// MaxAbsError returns the largest |decoded - original| for values in
+// [Min, Max]: half the resolution when rounding to nearest, the full
+// resolution for the directed modes.
+func (f *FixedPoint) MaxAbsError() float64 {
+	if f.Rounding == RoundNearest || f.Rounding == RoundHalfEven {
+		return f.Resolution() / 2
+	}
+	return f.Resolution()
+}
+
+// Append encodes v and appends it to dst.
+func (f *FixedPoint) Append(dst []byte, v float64) ([]byte, error) {
+	if !(v >= f.Min && v <= f.Max) {
+		return nil, errors.New("varfloat: value outside the fixed-point range")
+	}
+	lo, hi := f.bounds()
+	n := min(max(f.round(v*f.Scale), lo), hi)
+	return AppendIntAuto(dst, n-lo, 0, hi-lo)
+}
+
+// Consume decodes a value written by Append from the beginning of b. It
+// returns the decoded value and the number of bytes consumed.
+func (f *FixedPoint) Consume(b []byte) (float64, int, error) {
+	lo, hi := f.bounds()
+	off, n, err := ConsumeIntAuto(b, 0, hi-lo)
+	if err != nil {
+		return 0, 0, err
+	}
+	return float64(lo+off) / f.Scale, n, nil
+}
+
+// EncodeSlice encodes a slice of values with a uvarint length prefix.
+func (f *FixedPoint) EncodeSlice(values []float64) ([]byte, error) {
+	out := binary.AppendUvarint(nil, uint64(len(values)))
+	for _, v := range values {
+		var err error
+		out, err = f.Append(out, v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// DecodeSlice decodes a slice of values written by EncodeSlice.
+func (f *FixedPoint) DecodeSlice(b []byte) ([]float64, int, error) {
+	count, n := binary.Uvarint(b)
+	if n <= 0 {
+		return nil, 0, errors.New("varfloat: invalid slice length")
+	}
+	if count > uint64(len(b)-n) {
+		return nil, 0, errors.New("varfloat: slice length exceeds buffer")
+	}
+	offset := n
+
+	out := make([]float64, 0, count)
+	for i := uint64(0); i < count; i++ {
+		v, used, err := f.Consume(b[offset:])
+		if err != nil {
+			return nil, 0, err
+		}
+		out = append(out, v)
+		offset += used
+	}
+	return out, offset, nil
+}
+
+// bounds returns the smallest and largest grid integers the codec writes.
+func (f *FixedPoint) bounds() (lo, hi int64) {
+	return f.round(f.Min * f.Scale), f.round(f.Max * f.Scale)
+}
+
+// round rounds a scaled value to an integer with the codec's rounding mode.
+func (f *FixedPoint) round(x float64) int64 {
+	if r := math.Round(x); math.Abs(x-r) <= 4*ulp(x) {
+		return int64(r)
+	}
+	switch f.Rounding {
+	case RoundHalfEven:
+		return int64(math.RoundToEven(x))
+	case RoundDown:
+		return int64(math.Floor(x))
+	case RoundUp:
+		return int64(math.Ceil(x))
+	case RoundTowardZero:
+		return int64(math.Trunc(x))
+	default:
+		return int64(math.Round(x))
+	}
+}
+
+// ulp returns the spacing of float64 values at x.
+func ulp(x float64) float64 {
+	a := math.Abs(x)
+	return math.Nextafter(a, math.Inf(1)) - a
+}
+
+// FixedPointStreamEncoder writes chunks of fixed-point values to an io.Writer
+// using the same chunk framing as FloatStreamEncoder. Each chunk holds one
+// FixedPoint.EncodeSlice payload.
+type FixedPointStreamEncoder struct {
+	w     io.Writer
+	codec *FixedPoint
+}
+
+// NewFixedPointStreamEncoder creates a FixedPointStreamEncoder that writes to
+// w using codec. The decoder must use a codec with the same parameters.
+func NewFixedPointStreamEncoder(w io.Writer, codec *FixedPoint) *FixedPointStreamEncoder {
+	return &FixedPointStreamEncoder{w: w, codec: codec}
+}
+
+// WriteChunk encodes values and writes them as a self-contained chunk to the
+// underlying writer.
+func (e *FixedPointStreamEncoder) WriteChunk(values []float64) error {
+	payload, err := e.codec.EncodeSlice(values)
+	if err != nil {
+		return err
+	}
+	return writeChunk(e.w, 0, 0, payload)
+}
+
+// FixedPointStreamDecoder reads chunks of fixed-point values from an
+// io.Reader that were written by FixedPointStreamEncoder.
+type FixedPointStreamDecoder struct {
+	r     *bufio.Reader
+	codec *FixedPoint
+}
+
+// NewFixedPointStreamDecoder creates a FixedPointStreamDecoder that reads
+// from r using codec.
+func NewFixedPointStreamDecoder(r io.Reader, codec *FixedPoint) *FixedPointStreamDecoder {
+	return &FixedPointStreamDecoder{r: bufio.NewReader(r), codec: codec}
+}
+
+// ReadChunk reads and decodes the next chunk of values from the stream. On
+// EOF without any bytes read, it returns (nil, io.EOF).
+func (d *FixedPointStreamDecoder) ReadChunk() ([]float64, error) {
+	_, _, buf, err := readChunk(d.r, 0)
+	if err != nil {
+		return nil, err
+	}
+	if buf == nil {
+		return nil, nil
+	}
+	values, _, err := d.codec.DecodeSlice(buf)
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
@@ -0,0 +1,191 @@
+package varfloat
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+var roundingModes = []RoundingMode{RoundNearest, RoundHalfEven, RoundDown, RoundUp, RoundTowardZero}
+
+func TestFixedPointRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewPCG(61, 61))
+	values := []float64{-50, 50, 0, 0.29, -0.29, 21.375, -17.125, 0.005, -0.005}
+	for i := 0; i < 200; i++ {
+		values = append(values, r.Float64()*100-50)
+	}
+	for _, mode := range roundingModes {
+		f, err := NewFixedPoint(100, -50, 50, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := f.EncodeSlice(values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, n, err := f.DecodeSlice(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(b) || len(got) != len(values) {
+			t.Fatalf("mode %d: decoded %d values from %d of %d bytes", mode, len(got), n, len(b))
+		}
+		for i, v := range values {
+			d := got[i] - v
+			if math.Abs(d) > f.MaxAbsError()*(1+1e-12) {
+				t.Errorf("mode %d: %g decoded as %g, beyond MaxAbsError %g", mode, v, got[i], f.MaxAbsError())
+			}
+			if mode == RoundDown && d > 1e-12 || mode == RoundUp && d < -1e-12 {
+				t.Errorf("mode %d: %g rounded the wrong way to %g", mode, v, got[i])
+			}
+			if got[i] != math.Round(got[i]*100)/100 {
+				t.Errorf("mode %d: %g decoded off the grid as %g", mode, v, got[i])
+			}
+		}
+		// 0.29*100 is 28.999999999999996 but still lands on 29.
+		if got[3] != 0.29 || got[4] != -0.29 {
+			t.Errorf("mode %d: ±0.29 decoded as %g, %g", mode, got[3], got[4])
+		}
+	}
+}
+
+func TestFixedPointRoundingModes(t *testing.T) {
+	tests := []struct {
+		v    float64
+		want [5]float64 // nearest, half even, down, up, toward zero
+	}{
+		{2.5, [5]float64{3, 2, 2, 3, 2}},
+		{-2.5, [5]float64{-3, -2, -3, -2, -2}},
+		{1.25, [5]float64{1, 1, 1, 2, 1}},
+		{-1.75, [5]float64{-2, -2, -2, -1, -1}},
+		// Far from zero the directed modes must still differ from nearest.
+		{1<<48 + 0.375, [5]float64{1 << 48, 1 << 48, 1 << 48, 1<<48 + 1, 1 << 48}},
+		{-(1<<48 + 0.375), [5]float64{-(1 << 48), -(1 << 48), -(1<<48 + 1), -(1 << 48), -(1 << 48)}},
+	}
+	for _, tt := range tests {
+		for i, mode := range roundingModes {
+			f, err := NewFixedPoint(1, -(1 << 49), 1<<49, mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := f.Append(nil, tt.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _, err := f.Consume(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want[i] {
+				t.Errorf("mode %d: %g decoded as %g, want %g", mode, tt.v, got, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestFixedPointBoundsRounding(t *testing.T) {
+	// Min and Max off the grid: the encoder clamps to the grid integers that
+	// bounds gives, and those must match the ones Validate accepted.
+	for _, mode := range roundingModes {
+		f, err := NewFixedPoint(10, -0.35, 0.35, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range []float64{-0.35, -0.34, 0, 0.34, 0.35} {
+			b, err := f.Append(nil, v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _, err := f.Consume(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(got-v) > f.MaxAbsError()*(1+1e-12) {
+				t.Errorf("mode %d: %g decoded as %g", mode, v, got)
+			}
+		}
+	}
+}
+
+func TestFixedPointInvalid(t *testing.T) {
+	for _, f := range []FixedPoint{
+		{Scale: 0, Min: 0, Max: 1},
+		{Scale: math.Inf(1), Min: 0, Max: 1},
+		{Scale: math.NaN(), Min: 0, Max: 1},
+		{Scale: 1, Min: math.NaN(), Max: 1},
+		{Scale: 1, Min: 0, Max: math.Inf(1)},
+		{Scale: 1, Min: 2, Max: 1},
+		{Scale: 1, Min: 0, Max: 1, Rounding: RoundTowardZero + 1},
+		{Scale: 1, Min: 0, Max: 1<<49 + 1},
+		{Scale: 1000, Min: -1e15, Max: 0},
+		{Scale: 1e300, Min: -1e300, Max: 1e300},
+	} {
+		if err := f.Validate(); err == nil {
+			t.Errorf("%+v accepted", f)
+		}
+	}
+
+	f, err := NewFixedPoint(100, -1, 1, RoundNearest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []float64{-1.01, 1.01, math.NaN(), math.Inf(1)} {
+		if _, err := f.Append(nil, v); err == nil {
+			t.Errorf("%g accepted", v)
+		}
+	}
+	b, err := f.EncodeSlice([]float64{-1, 0.5, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTruncated(t, b, func(b []byte) error {
+		_, _, err := f.DecodeSlice(b)
+		return err
+	})
+	if _, _, err := f.DecodeSlice([]byte{0xff, 0xff, 0x03, 0}); err == nil {
+		t.Error("oversized count accepted")
+	}
+
+	b, err = f.EncodeSlice(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _, err := f.DecodeSlice(b); err != nil || len(got) != 0 {
+		t.Errorf("DecodeSlice(empty) = %v, %v", got, err)
+	}
+}
+
+func TestFixedPointStream(t *testing.T) {
+	f, err := NewFixedPoint(1000, 0, 10, RoundNearest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := [][]float64{{1.234, 5, 9.999}, nil, {0, 10}}
+	var buf bytes.Buffer
+	enc := NewFixedPointStreamEncoder(&buf, f)
+	for _, values := range chunks {
+		if err := enc.WriteChunk(values); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dec := NewFixedPointStreamDecoder(&buf, f)
+	for i, values := range chunks {
+		got, err := dec.ReadChunk()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(values) {
+			t.Fatalf("chunk %d: %d values, want %d", i, len(got), len(values))
+		}
+		for j, v := range values {
+			if got[j] != v {
+				t.Errorf("chunk %d: %g decoded as %g", i, v, got[j])
+			}
+		}
+	}
+	if _, err := dec.ReadChunk(); err != io.EOF {
+		t.Errorf("expected io.EOF after the last chunk, got %v", err)
+	}
+}
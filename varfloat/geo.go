@@ -0,0 +1,473 @@
+package varfloat
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// geoMetresPerDegree is the length of one degree of latitude (and of
+// longitude at the equator) on a sphere with the mean Earth radius.
+const geoMetresPerDegree = 6371008.8 * math.Pi / 180
+
+// GeoPoint is a geographic position: latitude and longitude in degrees and
+// altitude in metres.
+type GeoPoint struct {
+	Lat, Lon, Alt float64
+}
+
+// GeoCodec encodes geographic positions with an error budget in metres.
+//
+// EncodeFloat spends its precision relative to the magnitude of each value, so
+// longitudes near 0° get sub-millimetre precision while longitudes near 180°
+// get far less than those near the meridian. GeoCodec instead quantizes
+// latitude to a uniform grid over [-90, 90] and longitude to a uniform grid
+// over [-180, 180) whose spacing widens by 1/cos(lat) away from the equator,
+// so every position gets the same horizontal error in metres. The grid
+// offsets are written as range-relative bounded ints (AppendIntAuto).
+//
+// Decoded positions are within MaxHorizontalError metres of the original
+// (measured on a spherical Earth). If MaxAltitudeError is > 0, altitude is
+// also stored, clamped to [MinAltitude, MaxAltitude] and within
+// MaxAltitudeError metres inside that range; otherwise it is dropped and
+// decodes as 0.
+type GeoCodec struct {
+	MaxHorizontalError float64
+	MaxAltitudeError   float64
+	MinAltitude        float64
+	MaxAltitude        float64
+}
+
+// Default altitude range used by NewGeoCodec, in metres. It covers the Dead
+// Sea shore up to high-altitude aircraft.
+const (
+	DefaultGeoMinAltitude = -1000
+	DefaultGeoMaxAltitude = 50000
+)
+
+// NewGeoCodec creates a GeoCodec with the given horizontal error budget in
+// metres. maxAltErr is the altitude error budget in metres, or 0 to drop
+// altitude. Altitude uses the default range [DefaultGeoMinAltitude,
+// DefaultGeoMaxAltitude]; set MinAltitude/MaxAltitude on the result and call
+// Validate to use a different one.
+func NewGeoCodec(maxHorizErr, maxAltErr float64) (*GeoCodec, error) {
+	c := &GeoCodec{
+		MaxHorizontalError: maxHorizErr,
+		MaxAltitudeError:   maxAltErr,
+		MinAltitude:        DefaultGeoMinAltitude,
+		MaxAltitude:        DefaultGeoMaxAltitude,
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate checks that the codec parameters are usable.
+func (c *GeoCodec) Validate() error {
+	if !(c.MaxHorizontalError > 0) || math.IsInf(c.MaxHorizontalError, 0) {
+		return errors.New("varfloat: MaxHorizontalError must be > 0")
+	}
+	// The longitude grid at the equator is the finest one; checking it as a
+	// float also keeps latSteps and lonSteps from overflowing int64.
+	if 360*geoMetresPerDegree/(2*c.axisError()) > 1<<52 {
+		return errors.New("varfloat: MaxHorizontalError is too small")
+	}
+	if !(c.MaxAltitudeError >= 0) || math.IsInf(c.MaxAltitudeError, 0) {
+		return errors.New("varfloat: MaxAltitudeError must be >= 0")
+	}
+	if c.MaxAltitudeError == 0 {
+		return nil
+	}
+	if math.IsNaN(c.MinAltitude) || math.IsInf(c.MinAltitude, 0) || math.IsNaN(c.MaxAltitude) || math.IsInf(c.MaxAltitude, 0) {
+		return errors.New("varfloat: altitude bounds must be finite")
+	}
+	if c.MinAltitude > c.MaxAltitude {
+		return errors.New("varfloat: MinAltitude must be <= MaxAltitude")
+	}
+	if math.Ceil((c.MaxAltitude-c.MinAltitude)/(2*c.MaxAltitudeError)) > 1<<52 {
+		return errors.New("varfloat: MaxAltitudeError is too small for the altitude range")
+	}
+	return nil
+}
+
+// Append encodes p and appends it to dst. Latitude is clamped to [-90, 90]
+// and longitude is wrapped into [-180, 180).
+func (c *GeoCodec) Append(dst []byte, p GeoPoint) ([]byte, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	q, err := c.quantize(p)
+	if err != nil {
+		return nil, err
+	}
+	return c.appendQuantized(dst, q)
+}
+
+// Consume decodes a position written by Append from the beginning of b. It
+// returns the decoded position and the number of bytes consumed.
+func (c *GeoCodec) Consume(b []byte) (GeoPoint, int, error) {
+	if err := c.Validate(); err != nil {
+		return GeoPoint{}, 0, err
+	}
+	q, n, err := c.consumeQuantized(b)
+	if err != nil {
+		return GeoPoint{}, 0, err
+	}
+	return c.dequantize(q), n, nil
+}
+
+// EncodeSlice encodes a slice of positions with a uvarint length prefix.
+// Each position is encoded independently; use EncodeTrack for ordered tracks.
+func (c *GeoCodec) EncodeSlice(ps []GeoPoint) ([]byte, error) {
+	var buf [10]byte
+	n := binary.PutUvarint(buf[:], uint64(len(ps)))
+	out := append([]byte(nil), buf[:n]...)
+	for _, p := range ps {
+		var err error
+		out, err = c.Append(out, p)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// DecodeSlice decodes a slice of positions written by EncodeSlice.
+func (c *GeoCodec) DecodeSlice(b []byte) ([]GeoPoint, int, error) {
+	count, n := binary.Uvarint(b)
+	if n <= 0 {
+		return nil, 0, errors.New("varfloat: invalid slice length")
+	}
+	offset := n
+	if count > uint64(len(b)-offset) {
+		return nil, 0, errors.New("varfloat: slice length exceeds buffer")
+	}
+
+	var out []GeoPoint
+	for i := uint64(0); i < count; i++ {
+		p, used, err := c.Consume(b[offset:])
+		if err != nil {
+			return nil, 0, err
+		}
+		out = append(out, p)
+		offset += used
+	}
+	return out, offset, nil
+}
+
+// EncodeTrack encodes an ordered track of positions, such as a vehicle's GPS
+// log, using delta encoding.
+//
+// The first position is written as with Append. Every later position is
+// written as zigzag uvarint deltas of its grid offsets from the previous
+// position. Because the longitude grid depends on latitude, the previous
+// longitude is re-quantized onto the current point's grid before taking the
+// delta, and the delta is wrapped so tracks crossing the antimeridian stay
+// small. Consecutive fixes a few metres apart cost a few bytes each.
+//
+// Layout: [uvarint count][first point][deltas...]
+func (c *GeoCodec) EncodeTrack(ps []GeoPoint) ([]byte, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	out := binary.AppendUvarint(nil, uint64(len(ps)))
+	if len(ps) == 0 {
+		return out, nil
+	}
+
+	prev, err := c.quantize(ps[0])
+	if err != nil {
+		return nil, err
+	}
+	out, err = c.appendQuantized(out, prev)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range ps[1:] {
+		q, err := c.quantize(p)
+		if err != nil {
+			return nil, err
+		}
+		lat := c.latFromQ(q.lat)
+		nLon := c.lonSteps(lat)
+		predLon := c.lonToQ(c.lonFromQ(prev.lon, c.lonSteps(c.latFromQ(prev.lat))), nLon)
+
+		out = binary.AppendUvarint(out, zigZagEncode(q.lat-prev.lat))
+		out = binary.AppendUvarint(out, zigZagEncode(wrapSteps(q.lon-predLon, nLon)))
+		if c.MaxAltitudeError > 0 {
+			out = binary.AppendUvarint(out, zigZagEncode(q.alt-prev.alt))
+		}
+		prev = q
+	}
+	return out, nil
+}
+
+// DecodeTrack decodes a track written by EncodeTrack. It returns the decoded
+// positions and the number of bytes consumed.
+func (c *GeoCodec) DecodeTrack(b []byte) ([]GeoPoint, int, error) {
+	if err := c.Validate(); err != nil {
+		return nil, 0, err
+	}
+	count, n := binary.Uvarint(b)
+	if n <= 0 {
+		return nil, 0, errors.New("varfloat: invalid track length")
+	}
+	if count > uint64(len(b)-n) {
+		return nil, 0, errors.New("varfloat: track length exceeds buffer")
+	}
+	offset := n
+	if count == 0 {
+		return []GeoPoint{}, offset, nil
+	}
+
+	out := make([]GeoPoint, 0, count)
+	prev, used, err := c.consumeQuantized(b[offset:])
+	if err != nil {
+		return nil, 0, err
+	}
+	offset += used
+	out = append(out, c.dequantize(prev))
+
+	nLat := c.latSteps()
+	nAlt := c.altSteps()
+	readDelta := func() (int64, error) {
+		u, used := binary.Uvarint(b[offset:])
+		if used <= 0 {
+			return 0, errors.New("varfloat: invalid track delta")
+		}
+		offset += used
+		return zigZagDecode(u), nil
+	}
+	for i := uint64(1); i < count; i++ {
+		var q geoQuantized
+		d, err := readDelta()
+		if err != nil {
+			return nil, 0, err
+		}
+		q.lat = prev.lat + d
+		if q.lat < 0 || q.lat > nLat {
+			return nil, 0, errors.New("varfloat: track latitude out of range")
+		}
+		nLon := c.lonSteps(c.latFromQ(q.lat))
+		predLon := c.lonToQ(c.lonFromQ(prev.lon, c.lonSteps(c.latFromQ(prev.lat))), nLon)
+		if d, err = readDelta(); err != nil {
+			return nil, 0, err
+		}
+		q.lon = ((predLon+d)%nLon + nLon) % nLon
+		if c.MaxAltitudeError > 0 {
+			if d, err = readDelta(); err != nil {
+				return nil, 0, err
+			}
+			q.alt = prev.alt + d
+			if q.alt < 0 || q.alt > nAlt {
+				return nil, 0, errors.New("varfloat: track altitude out of range")
+			}
+		}
+		out = append(out, c.dequantize(q))
+		prev = q
+	}
+	return out, offset, nil
+}
+
+// GeoStreamEncoder writes delta-encoded tracks to an io.Writer using the same
+// chunk framing as FloatStreamEncoder. Each chunk holds one EncodeTrack
+// payload, so a long track can be written in pieces as fixes arrive.
+type GeoStreamEncoder struct {
+	w     io.Writer
+	codec *GeoCodec
+}
+
+// NewGeoStreamEncoder creates a GeoStreamEncoder that writes to w using codec.
+// The decoder must use a codec with the same parameters.
+func NewGeoStreamEncoder(w io.Writer, codec *GeoCodec) *GeoStreamEncoder {
+	return &GeoStreamEncoder{w: w, codec: codec}
+}
+
+// WriteChunk encodes ps with EncodeTrack and writes it as a self-contained
+// chunk to the underlying writer.
+func (e *GeoStreamEncoder) WriteChunk(ps []GeoPoint) error {
+	payload, err := e.codec.EncodeTrack(ps)
+	if err != nil {
+		return err
+	}
+	return writeChunk(e.w, 0, 0, payload)
+}
+
+// GeoStreamDecoder reads chunks of positions from an io.Reader that were
+// written by GeoStreamEncoder.
+type GeoStreamDecoder struct {
+	r     *bufio.Reader
+	codec *GeoCodec
+}
+
+// NewGeoStreamDecoder creates a GeoStreamDecoder that reads from r using
+// codec.
+func NewGeoStreamDecoder(r io.Reader, codec *GeoCodec) *GeoStreamDecoder {
+	return &GeoStreamDecoder{r: bufio.NewReader(r), codec: codec}
+}
+
+// ReadChunk reads and decodes the next chunk of positions from the stream. On
+// EOF without any bytes read, it returns (nil, io.EOF).
+func (d *GeoStreamDecoder) ReadChunk() ([]GeoPoint, error) {
+	_, _, buf, err := readChunk(d.r, 0)
+	if err != nil {
+		return nil, err
+	}
+	if buf == nil {
+		return nil, nil
+	}
+
+	ps, _, err := d.codec.DecodeTrack(buf)
+	if err != nil {
+		return nil, err
+	}
+	return ps, nil
+}
+
+// geoQuantized holds the grid offsets of one position.
+type geoQuantized struct {
+	lat, lon, alt int64
+}
+
+// axisError is the per-axis error budget in metres. Splitting the horizontal
+// budget evenly keeps the combined error within MaxHorizontalError.
+func (c *GeoCodec) axisError() float64 {
+	return c.MaxHorizontalError / math.Sqrt2
+}
+
+// latSteps returns the number of latitude grid steps covering [-90, 90].
+func (c *GeoCodec) latSteps() int64 {
+	step := 2 * c.axisError() / geoMetresPerDegree
+	return int64(math.Max(1, math.Ceil(180/step)))
+}
+
+// latFromQ returns the latitude of a grid offset.
+func (c *GeoCodec) latFromQ(q int64) float64 {
+	return math.Min(-90+float64(q)*180/float64(c.latSteps()), 90)
+}
+
+// lonSteps returns the number of longitude grid steps covering [-180, 180)
+// at the decoded latitude lat. The spacing uses the largest cos(lat) within
+// half a latitude step, so the error bound also holds for the original
+// latitude.
+func (c *GeoCodec) lonSteps(lat float64) int64 {
+	halfLat := 90 / float64(c.latSteps())
+	edge := math.Max(0, math.Abs(lat)-halfLat)
+	metres := 360 * geoMetresPerDegree * math.Cos(edge*math.Pi/180)
+	return int64(math.Max(1, math.Ceil(metres/(2*c.axisError()))))
+}
+
+// lonToQ maps a longitude onto a grid with n steps.
+func (c *GeoCodec) lonToQ(lon float64, n int64) int64 {
+	q := int64(math.Round((lon + 180) / 360 * float64(n)))
+	return (q%n + n) % n
+}
+
+// lonFromQ returns the longitude of a grid offset on a grid with n steps.
+func (c *GeoCodec) lonFromQ(q, n int64) float64 {
+	return -180 + float64(q)*360/float64(n)
+}
+
+// altSteps returns the number of altitude grid steps.
+func (c *GeoCodec) altSteps() int64 {
+	if c.MaxAltitudeError == 0 {
+		return 0
+	}
+	return int64(math.Ceil((c.MaxAltitude - c.MinAltitude) / (2 * c.MaxAltitudeError)))
+}
+
+// quantize maps p onto the codec's grids.
+func (c *GeoCodec) quantize(p GeoPoint) (geoQuantized, error) {
+	if math.IsNaN(p.Lat) || math.IsNaN(p.Lon) || math.IsInf(p.Lon, 0) {
+		return geoQuantized{}, errors.New("varfloat: invalid geographic coordinate")
+	}
+	if c.MaxAltitudeError > 0 && math.IsNaN(p.Alt) {
+		return geoQuantized{}, errors.New("varfloat: cannot encode NaN altitude")
+	}
+
+	var q geoQuantized
+	nLat := c.latSteps()
+	lat := math.Max(-90, math.Min(90, p.Lat))
+	q.lat = int64(math.Round((lat + 90) / 180 * float64(nLat)))
+	q.lon = c.lonToQ(math.Mod(p.Lon, 360), c.lonSteps(c.latFromQ(q.lat)))
+	if c.MaxAltitudeError > 0 {
+		nAlt := c.altSteps()
+		step := 2 * c.MaxAltitudeError
+		switch {
+		case p.Alt <= c.MinAltitude:
+			q.alt = 0
+		case p.Alt >= c.MaxAltitude:
+			q.alt = nAlt
+		default:
+			q.alt = min(int64(math.Round((p.Alt-c.MinAltitude)/step)), nAlt)
+		}
+	}
+	return q, nil
+}
+
+// dequantize rebuilds a position from its grid offsets.
+func (c *GeoCodec) dequantize(q geoQuantized) GeoPoint {
+	lat := c.latFromQ(q.lat)
+	p := GeoPoint{Lat: lat, Lon: c.lonFromQ(q.lon, c.lonSteps(lat))}
+	if c.MaxAltitudeError > 0 {
+		p.Alt = math.Min(c.MinAltitude+float64(q.alt)*2*c.MaxAltitudeError, c.MaxAltitude)
+	}
+	return p
+}
+
+// appendQuantized writes the grid offsets of one position.
+func (c *GeoCodec) appendQuantized(dst []byte, q geoQuantized) ([]byte, error) {
+	dst, err := AppendIntAuto(dst, q.lat, 0, c.latSteps())
+	if err != nil {
+		return nil, err
+	}
+	dst, err = AppendIntAuto(dst, q.lon, 0, c.lonSteps(c.latFromQ(q.lat))-1)
+	if err != nil {
+		return nil, err
+	}
+	if c.MaxAltitudeError > 0 {
+		dst, err = AppendIntAuto(dst, q.alt, 0, c.altSteps())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return dst, nil
+}
+
+// consumeQuantized reads the grid offsets of one position written by
+// appendQuantized.
+func (c *GeoCodec) consumeQuantized(b []byte) (geoQuantized, int, error) {
+	var q geoQuantized
+	latQ, offset, err := ConsumeIntAuto(b, 0, c.latSteps())
+	if err != nil {
+		return geoQuantized{}, 0, err
+	}
+	q.lat = latQ
+	lonQ, used, err := ConsumeIntAuto(b[offset:], 0, c.lonSteps(c.latFromQ(latQ))-1)
+	if err != nil {
+		return geoQuantized{}, 0, err
+	}
+	offset += used
+	q.lon = lonQ
+	if c.MaxAltitudeError > 0 {
+		altQ, used, err := ConsumeIntAuto(b[offset:], 0, c.altSteps())
+		if err != nil {
+			return geoQuantized{}, 0, err
+		}
+		offset += used
+		q.alt = altQ
+	}
+	return q, offset, nil
+}
+
+// wrapSteps wraps a longitude grid delta into [-n/2, n/2).
+func wrapSteps(d, n int64) int64 {
+	d = ((d % n) + n) % n
+	if d >= (n+1)/2 {
+		d -= n
+	}
+	return d
+}
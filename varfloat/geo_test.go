@@ -0,0 +1,167 @@
+package varfloat
+
+import (
+	"bytes"
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// geoDistance returns the great-circle distance in metres between a and b on
+// the sphere GeoCodec measures against.
+func geoDistance(a, b GeoPoint) float64 {
+	rad := math.Pi / 180
+	dLat := (b.Lat - a.Lat) * rad
+	dLon := (b.Lon - a.Lon) * rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(a.Lat*rad)*math.Cos(b.Lat*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * geoMetresPerDegree / rad * math.Asin(math.Sqrt(math.Min(1, h)))
+}
+
+// samplePoints returns the poles, points on the antimeridian and equator, and
+// n random points.
+func samplePoints(n int, seed uint64) []GeoPoint {
+	ps := []GeoPoint{
+		{Lat: 90}, {Lat: -90, Lon: 45}, {Lon: -180}, {Lon: 179.9999999},
+		{Lat: 89.99999, Lon: -179.99999}, {Lat: 51.4779, Lon: -0.0015, Alt: 45},
+		{Lat: -33.8568, Lon: 151.2153, Alt: -999}, {Lat: 27.9881, Lon: 86.925, Alt: 8848},
+	}
+	r := rand.New(rand.NewPCG(seed, seed))
+	for range n {
+		ps = append(ps, GeoPoint{
+			Lat: math.Asin(r.Float64()*2-1) * 180 / math.Pi,
+			Lon: r.Float64()*360 - 180,
+			Alt: r.Float64()*20000 - 500,
+		})
+	}
+	return ps
+}
+
+func TestGeoCodecErrorBudget(t *testing.T) {
+	ps := samplePoints(2000, 13)
+	for _, tt := range []struct{ horiz, alt float64 }{
+		{1, 0}, {0.01, 0.5}, {10, 1}, {1000, 100}, {1e6, 0},
+	} {
+		c, err := NewGeoCodec(tt.horiz, tt.alt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := c.EncodeSlice(ps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, n, err := c.DecodeSlice(b)
+		if err != nil || n != len(b) || len(got) != len(ps) {
+			t.Fatalf("%v: %d points in %d of %d bytes, %v", tt, len(got), n, len(b), err)
+		}
+		for i, p := range ps {
+			if d := geoDistance(p, got[i]); d > tt.horiz {
+				t.Errorf("%v: %v decoded as %v, %g m away", tt, p, got[i], d)
+			}
+			wantAlt := 0.0
+			if tt.alt > 0 {
+				wantAlt = min(max(p.Alt, DefaultGeoMinAltitude), DefaultGeoMaxAltitude)
+			}
+			if math.Abs(got[i].Alt-wantAlt) > tt.alt {
+				t.Errorf("%v: altitude %g decoded as %g", tt, p.Alt, got[i].Alt)
+			}
+		}
+	}
+}
+
+func TestGeoTrack(t *testing.T) {
+	c, err := NewGeoCodec(0.5, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Eastwards across the antimeridian near the pole, then back south.
+	var track []GeoPoint
+	for i := range 500 {
+		lon := 179.9 + float64(i)*0.0005
+		if lon >= 180 {
+			lon -= 360
+		}
+		track = append(track, GeoPoint{Lat: 89.99 - float64(i%50)*1e-5, Lon: lon, Alt: 100 + float64(i)/10})
+	}
+	b, err := c.EncodeTrack(track)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, n, err := c.DecodeTrack(b)
+	if err != nil || n != len(b) || len(got) != len(track) {
+		t.Fatalf("%d points in %d of %d bytes, %v", len(got), n, len(b), err)
+	}
+	for i, p := range track {
+		if d := geoDistance(p, got[i]); d > 0.5 || math.Abs(got[i].Alt-p.Alt) > 1 {
+			t.Errorf("%v decoded as %v, %g m away", p, got[i], d)
+		}
+	}
+	if perPoint := float64(len(b)) / float64(len(track)); perPoint > 4 {
+		t.Errorf("%.1f bytes per track point", perPoint)
+	}
+
+	checkTruncated(t, b[:40], func(b []byte) error {
+		_, _, err := c.DecodeTrack(b)
+		return err
+	})
+	empty, _ := c.EncodeTrack(nil)
+	if got, n, err := c.DecodeTrack(empty); err != nil || len(got) != 0 || n != len(empty) {
+		t.Errorf("empty track: %v, %d, %v", got, n, err)
+	}
+}
+
+func TestGeoStream(t *testing.T) {
+	c, err := NewGeoCodec(5, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	enc := NewGeoStreamEncoder(&buf, c)
+	chunks := [][]GeoPoint{samplePoints(10, 14), nil, samplePoints(3, 15)}
+	for _, ps := range chunks {
+		if err := enc.WriteChunk(ps); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dec := NewGeoStreamDecoder(&buf, c)
+	for i, ps := range chunks {
+		got, err := dec.ReadChunk()
+		if err != nil || len(got) != len(ps) {
+			t.Fatalf("chunk %d: %d points, %v", i, len(got), err)
+		}
+		for j, p := range ps {
+			if d := geoDistance(p, got[j]); d > 5 {
+				t.Errorf("chunk %d: %v decoded as %v", i, p, got[j])
+			}
+		}
+	}
+}
+
+func TestGeoCodecInvalid(t *testing.T) {
+	for _, tt := range []struct{ horiz, alt float64 }{
+		{0, 0}, {-1, 0}, {math.Inf(1), 0}, {1e-12, 0}, {1, -1}, {1, 1e-15},
+	} {
+		if _, err := NewGeoCodec(tt.horiz, tt.alt); err == nil {
+			t.Errorf("NewGeoCodec(%g, %g) accepted", tt.horiz, tt.alt)
+		}
+	}
+	c, err := NewGeoCodec(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []GeoPoint{{Lat: math.NaN()}, {Lon: math.Inf(-1)}, {Alt: math.NaN()}} {
+		if _, err := c.Append(nil, p); err == nil {
+			t.Errorf("%v accepted", p)
+		}
+	}
+	b, err := c.EncodeSlice(samplePoints(2, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTruncated(t, b, func(b []byte) error {
+		_, _, err := c.DecodeSlice(b)
+		return err
+	})
+	if _, _, err := c.DecodeSlice([]byte{0xff, 0xff, 0xff, 0xff, 0x0f}); err == nil {
+		t.Error("oversized count accepted")
+	}
+}
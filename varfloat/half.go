@@ -0,0 +1,160 @@
+package varfloat
+
+import "math"
+
+// Float16 is an IEEE 754 binary16 (half precision) value, stored as its bit
+// pattern: 1 sign bit, 5 exponent bits, 10 mantissa bits.
+type Float16 uint16
+
+// BFloat16 is a bfloat16 value, stored as its bit pattern: 1 sign bit, 8
+// exponent bits, 7 mantissa bits (the top half of a float32).
+type BFloat16 uint16
+
+// Float16FromFloat64 converts v to the nearest Float16, rounding halfway cases
+// to even as IEEE 754 does. Values too large for binary16 become ±Inf, tiny
+// values become subnormals or ±0, and NaN stays NaN with the top bits of its
+// payload.
+func Float16FromFloat64(v float64) Float16 {
+	return Float16(toHalf(v, 5, 10))
+}
+
+// Float64 converts h to float64. Every Float16 is exactly representable.
+func (h Float16) Float64() float64 {
+	return fromHalf(uint16(h), 5, 10)
+}
+
+// BFloat16FromFloat64 converts v to the nearest BFloat16, rounding halfway
+// cases to even. It rounds once, straight from float64, so it can differ from
+// converting to float32 first and then truncating or rounding again.
+func BFloat16FromFloat64(v float64) BFloat16 {
+	return BFloat16(toHalf(v, 8, 7))
+}
+
+// Float64 converts b to float64. Every BFloat16 is exactly representable.
+func (b BFloat16) Float64() float64 {
+	return fromHalf(uint16(b), 8, 7)
+}
+
+// EncodeFloat16s encodes half-precision values as varfloats with the given
+// mantissa bits, in the EncodeFloats format. Inf and NaN are kept.
+//
+// With bits >= 11, DecodeFloat16s returns exactly the input; fewer bits
+// quantize further, which is useful to shrink tensors beyond half precision.
+// (10 bits is not quite enough: varfloat mantissas have 2^bits-1 steps, not
+// 2^bits, so the odd value lands on a neighbour.)
+func EncodeFloat16s(values []Float16, bits int) ([]byte, error) {
+	flat := make([]float64, len(values))
+	for i, h := range values {
+		flat[i] = h.Float64()
+	}
+	return EncodeFloats(flat, bits)
+}
+
+// DecodeFloat16s decodes a varfloat slice written with the given mantissa bits
+// (by EncodeFloat16s or any EncodeFloats caller) straight into Float16,
+// rounding each value to nearest-even.
+func DecodeFloat16s(b []byte, bits int) ([]Float16, int, error) {
+	flat, n, err := DecodeFloats(b, bits)
+	if err != nil {
+		return nil, 0, err
+	}
+	out := make([]Float16, len(flat))
+	for i, v := range flat {
+		out[i] = Float16FromFloat64(v)
+	}
+	return out, n, nil
+}
+
+// EncodeBFloat16s is EncodeFloat16s for bfloat16 values. With bits >= 8 the
+// values round-trip exactly.
+func EncodeBFloat16s(values []BFloat16, bits int) ([]byte, error) {
+	flat := make([]float64, len(values))
+	for i, b := range values {
+		flat[i] = b.Float64()
+	}
+	return EncodeFloats(flat, bits)
+}
+
+// DecodeBFloat16s decodes a varfloat slice written with the given mantissa
+// bits straight into BFloat16, rounding each value to nearest-even.
+func DecodeBFloat16s(b []byte, bits int) ([]BFloat16, int, error) {
+	flat, n, err := DecodeFloats(b, bits)
+	if err != nil {
+		return nil, 0, err
+	}
+	out := make([]BFloat16, len(flat))
+	for i, v := range flat {
+		out[i] = BFloat16FromFloat64(v)
+	}
+	return out, n, nil
+}
+
+// toHalf rounds v to a 16-bit IEEE-style format with expBits exponent bits
+// and mantBits mantissa bits (expBits+mantBits must be 15).
+func toHalf(v float64, expBits, mantBits uint) uint16 {
+	sign := uint16(0)
+	if math.Signbit(v) {
+		sign = 1 << 15
+	}
+	expMax := uint16(1)<<expBits - 1
+	switch {
+	case math.IsNaN(v):
+		payload := uint16(math.Float64bits(v)>>(52-mantBits)) & (1<<mantBits - 1)
+		return sign | expMax<<mantBits | payload | 1<<(mantBits-1)
+	case math.IsInf(v, 0):
+		return sign | expMax<<mantBits
+	case v == 0:
+		return sign
+	}
+
+	a := math.Abs(v)
+	bias := int(1)<<(expBits-1) - 1
+	_, e := math.Frexp(a)
+	e-- // a = 1.f * 2^e
+
+	if e < 1-bias {
+		// Subnormal: a multiple of 2^(1-bias-mantBits). Rounding up to
+		// 1<<mantBits carries into the exponent field, giving the smallest
+		// normal.
+		q := math.RoundToEven(math.Ldexp(a, bias-1+int(mantBits)))
+		return sign | uint16(q)
+	}
+
+	q := uint64(math.RoundToEven(math.Ldexp(a, int(mantBits)-e)))
+	if q == 1<<(mantBits+1) {
+		q >>= 1
+		e++
+	}
+	if e > bias {
+		return sign | expMax<<mantBits
+	}
+	return sign | uint16(e+bias)<<mantBits | uint16(q)&(1<<mantBits-1)
+}
+
+// fromHalf expands a value produced by toHalf to float64.
+func fromHalf(h uint16, expBits, mantBits uint) float64 {
+	sign := uint64(h>>15) << 63
+	expMax := uint16(1)<<expBits - 1
+	exp := h >> mantBits & expMax
+	mant := uint64(h & (1<<mantBits - 1))
+	bias := int(1)<<(expBits-1) - 1
+
+	switch exp {
+	case expMax:
+		if mant == 0 {
+			return math.Float64frombits(sign | 0x7ff<<52)
+		}
+		return math.Float64frombits(sign | 0x7ff<<52 | mant<<(52-mantBits))
+	case 0:
+		v := math.Ldexp(float64(mant), 1-bias-int(mantBits))
+		if sign != 0 {
+			v = -v
+		}
+		return v
+	}
+	v := math.Ldexp(float64(mant|1<<mantBits), int(exp)-bias-int(mantBits))
+	if sign != 0 {
+		v = -v
+	}
+	return v
+}
@@ -0,0 +1,153 @@
+package varfloat
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFloat16Conversions(t *testing.T) {
+	tests := []struct {
+		v    float64
+		want Float16
+	}{
+		{0, 0x0000},
+		{math.Copysign(0, -1), 0x8000},
+		{1, 0x3c00},
+		{-2, 0xc000},
+		{65504, 0x7bff},                    // largest finite
+		{65519.99, 0x7bff},                 // rounds down to it
+		{65520, 0x7c00},                    // halfway to 2^16 rounds to even: Inf
+		{math.Inf(-1), 0xfc00},             // -Inf
+		{0x1p-14, 0x0400},                  // smallest normal
+		{0x1p-24, 0x0001},                  // smallest subnormal
+		{0x1p-25, 0x0000},                  // halfway to it rounds to even: 0
+		{0x1.8p-25, 0x0001},                // above halfway
+		{0x1.ffcp-15, 0x0400},              // largest subnormal carries into the exponent
+		{1 + 0x1p-11, 0x3c00},              // halfway, even below
+		{1 + 0x3p-11, 0x3c02},              // halfway, even above
+		{-(1 + 0x1p-10 + 0x1p-12), 0xbc01}, // below halfway
+	}
+	for _, tt := range tests {
+		if got := Float16FromFloat64(tt.v); got != tt.want {
+			t.Errorf("Float16FromFloat64(%g) = %#04x, want %#04x", tt.v, uint16(got), uint16(tt.want))
+		}
+	}
+	if h := Float16FromFloat64(math.NaN()); !math.IsNaN(h.Float64()) {
+		t.Errorf("NaN converted to %#04x", uint16(h))
+	}
+}
+
+func TestBFloat16Conversions(t *testing.T) {
+	tests := []struct {
+		v    float64
+		want BFloat16
+	}{
+		{1, 0x3f80},
+		{-1.5, 0xbfc0},
+		{math.Copysign(0, -1), 0x8000},
+		{math.Inf(1), 0x7f80},
+		{1 + 0x1p-8, 0x3f80},      // halfway, even below
+		{1 + 0x3p-8, 0x3f82},      // halfway, even above
+		{math.MaxFloat32, 0x7f80}, // rounds up past the largest finite
+		{0x1.fep127, 0x7f7f},      // largest finite
+		{0x1p-133, 0x0001},        // smallest subnormal
+		{1e-300, 0x0000},          // underflows
+		{-1e300, 0xff80},          // overflows
+	}
+	for _, tt := range tests {
+		if got := BFloat16FromFloat64(tt.v); got != tt.want {
+			t.Errorf("BFloat16FromFloat64(%g) = %#04x, want %#04x", tt.v, uint16(got), uint16(tt.want))
+		}
+	}
+}
+
+func TestFloat16Exhaustive(t *testing.T) {
+	all := make([]Float16, 1<<16)
+	for i := range all {
+		all[i] = Float16(i)
+	}
+	for _, h := range all {
+		v := h.Float64()
+		if back := Float16FromFloat64(v); back != h && !(math.IsNaN(v) && math.IsNaN(back.Float64())) {
+			t.Errorf("%#04x -> %g -> %#04x", uint16(h), v, uint16(back))
+		}
+	}
+
+	// With 11 bits every pattern survives a varfloat round trip.
+	b, err := EncodeFloat16s(all, 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, n, err := DecodeFloat16s(b, 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(b) || len(got) != len(all) {
+		t.Fatalf("decoded %d values from %d of %d bytes", len(got), n, len(b))
+	}
+	for i, h := range all {
+		if !sameFloat(got[i].Float64(), h.Float64()) {
+			t.Errorf("%#04x decoded as %#04x", uint16(h), uint16(got[i]))
+		}
+	}
+}
+
+func TestBFloat16Exhaustive(t *testing.T) {
+	all := make([]BFloat16, 1<<16)
+	for i := range all {
+		all[i] = BFloat16(i)
+	}
+	for _, h := range all {
+		v := h.Float64()
+		if back := BFloat16FromFloat64(v); back != h && !(math.IsNaN(v) && math.IsNaN(back.Float64())) {
+			t.Errorf("%#04x -> %g -> %#04x", uint16(h), v, uint16(back))
+		}
+	}
+
+	b, err := EncodeBFloat16s(all, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := DecodeBFloat16s(b, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, h := range all {
+		if !sameFloat(got[i].Float64(), h.Float64()) {
+			t.Errorf("%#04x decoded as %#04x", uint16(h), uint16(got[i]))
+		}
+	}
+}
+
+func TestFloat16sFewerBits(t *testing.T) {
+	values := []Float16{0x3c00, 0x3555, 0xc8e7, 0x7c00, 0x0001, 0x8000}
+	const bits = 4
+	b, err := EncodeFloat16s(values, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := DecodeFloat16s(b, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, h := range values {
+		v, g := h.Float64(), got[i].Float64()
+		// Quantizing to 4 bits, then rounding to binary16, adds at most one
+		// binary16 ulp (2^-10 relative) to the varfloat error.
+		if math.Abs(g-v) > math.Abs(v)*(MaxRelErrorForBits(bits)+0x1p-10) || math.Signbit(g) != math.Signbit(v) {
+			t.Errorf("%#04x (%g) decoded as %#04x (%g)", uint16(h), v, uint16(got[i]), g)
+		}
+	}
+
+	checkTruncated(t, b, func(b []byte) error {
+		_, _, err := DecodeFloat16s(b, bits)
+		return err
+	})
+	b, err = EncodeBFloat16s(nil, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _, err := DecodeBFloat16s(b, bits); err != nil || len(got) != 0 {
+		t.Errorf("DecodeBFloat16s(empty) = %v, %v", got, err)
+	}
+}
@@ -0,0 +1,270 @@
+package varfloat
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// EncodeFloatsProgressive encodes values so they can be decoded coarse to
+// fine, e.g. to show a preview of a large point cloud before all of it has
+// arrived.
+//
+// passes lists the cumulative mantissa bits available after each pass and
+// must be strictly increasing, with the last entry (the full precision) at
+// most 52. Every value is quantized once at the full precision. The first
+// pass carries each value's exponent/sign header plus the top passes[0]
+// mantissa bits; each later pass adds the next passes[i]-passes[i-1] bits of
+// every non-zero value. After pass i a decoder reconstructs each value from
+// the middle of the interval its received bits describe, so the relative
+// error is within MaxRelErrorForBits(passes[i]).
+//
+// Layout:
+//
+//	[uvarint count][uvarint passCount][passCount bytes of cumulative bits]
+//	[uvarint byteLen][pass 0: uvarint headers..., packed top bits]
+//	[uvarint byteLen][pass 1: packed refinement bits]
+//	...
+//
+// Each pass is length-prefixed, so a receiver can decode as soon as a whole
+// pass has arrived. Use ProgressiveDecoder or DecodeFloatsProgressive.
+func EncodeFloatsProgressive(values []float64, passes []int) ([]byte, error) {
+	if err := checkProgressivePasses(passes); err != nil {
+		return nil, err
+	}
+	full := passes[len(passes)-1]
+	c := Config{MantissaBits: full}
+
+	headers := make([]uint64, len(values))
+	mants := make([]uint64, len(values))
+	for i, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, errors.New("varfloat: progressive encoding requires finite values")
+		}
+		headers[i], mants[i] = c.split(v)
+	}
+
+	out := binary.AppendUvarint(nil, uint64(len(values)))
+	out = binary.AppendUvarint(out, uint64(len(passes)))
+	for _, p := range passes {
+		out = append(out, byte(p))
+	}
+
+	prev := 0
+	for i, p := range passes {
+		var pass []byte
+		if i == 0 {
+			for _, h := range headers {
+				pass = binary.AppendUvarint(pass, h)
+			}
+		}
+		var w bitWriter
+		width := p - prev
+		for j, h := range headers {
+			if h == 0 {
+				continue
+			}
+			w.writeBits(mants[j]>>uint(full-p), width)
+		}
+		pass = append(pass, w.bytes()...)
+
+		out = binary.AppendUvarint(out, uint64(len(pass)))
+		out = append(out, pass...)
+		prev = p
+	}
+	return out, nil
+}
+
+// DecodeFloatsProgressive decodes a prefix of an EncodeFloatsProgressive
+// payload. It uses every complete pass in b and ignores a trailing partial
+// pass, returning the approximate values and the mantissa bits they were
+// reconstructed with. At least the first pass must be present.
+func DecodeFloatsProgressive(b []byte) ([]float64, int, error) {
+	d := NewProgressiveDecoder(bytes.NewReader(b))
+	values, bits, err := d.ReadPass()
+	if err != nil {
+		return nil, 0, noEOF(err)
+	}
+	for {
+		next, nextBits, err := d.ReadPass()
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return values, bits, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		values, bits = next, nextBits
+	}
+}
+
+// ProgressiveDecoder incrementally decodes an EncodeFloatsProgressive payload
+// from an io.Reader, such as a network connection, one pass at a time.
+type ProgressiveDecoder struct {
+	r *bufio.Reader
+
+	count   uint64
+	passes  []int
+	pass    int
+	headers []uint64
+	prefix  []uint64
+}
+
+// NewProgressiveDecoder creates a ProgressiveDecoder that reads from r.
+func NewProgressiveDecoder(r io.Reader) *ProgressiveDecoder {
+	return &ProgressiveDecoder{r: bufio.NewReader(r)}
+}
+
+// ReadPass reads the next pass and returns every value refined with the bits
+// received so far, along with that bit count. After the last pass it returns
+// io.EOF; a pass cut short returns io.ErrUnexpectedEOF, and the values from
+// the previous call remain valid.
+func (d *ProgressiveDecoder) ReadPass() ([]float64, int, error) {
+	if d.passes == nil {
+		if err := d.readHeader(); err != nil {
+			return nil, 0, err
+		}
+	}
+	if d.pass == len(d.passes) {
+		return nil, 0, io.EOF
+	}
+
+	byteLen, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return nil, 0, noEOF(err)
+	}
+	if byteLen > d.count*(10+8)+8 {
+		return nil, 0, errors.New("varfloat: progressive pass too long")
+	}
+	buf, err := readPayload(d.r, byteLen)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	prev := 0
+	if d.pass > 0 {
+		prev = d.passes[d.pass-1]
+	}
+	bits := d.passes[d.pass]
+	offset := 0
+	if d.pass == 0 {
+		// Every value has a header of at least one byte in the first pass.
+		if d.count > uint64(len(buf)) {
+			return nil, 0, errors.New("varfloat: slice length exceeds buffer")
+		}
+		d.prefix = make([]uint64, d.count)
+		d.headers = make([]uint64, d.count)
+		for i := range d.headers {
+			h, n := binary.Uvarint(buf[offset:])
+			if n <= 0 {
+				return nil, 0, errors.New("varfloat: invalid progressive header")
+			}
+			d.headers[i] = h
+			offset += n
+		}
+	}
+
+	r := bitReader{b: buf[offset:]}
+	width := bits - prev
+	for i, h := range d.headers {
+		if h == 0 {
+			continue
+		}
+		v, err := r.readBits(width)
+		if err != nil {
+			return nil, 0, err
+		}
+		d.prefix[i] = d.prefix[i]<<uint(width) | v
+	}
+	d.pass++
+
+	values, err := d.values(bits)
+	if err != nil {
+		return nil, 0, err
+	}
+	return values, bits, nil
+}
+
+// readHeader reads the value count and pass list.
+func (d *ProgressiveDecoder) readHeader() error {
+	count, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return err
+	}
+	if count > 1<<32 {
+		return errors.New("varfloat: invalid progressive value count")
+	}
+	n, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return noEOF(err)
+	}
+	if n == 0 || n > 53 {
+		return errors.New("varfloat: invalid progressive pass count")
+	}
+	raw, err := readPayload(d.r, n)
+	if err != nil {
+		return err
+	}
+	passes := make([]int, n)
+	for i, p := range raw {
+		passes[i] = int(p)
+	}
+	if err := checkProgressivePasses(passes); err != nil {
+		return err
+	}
+	d.count = count
+	d.passes = passes
+	return nil
+}
+
+// values reconstructs every value from the mantissa prefixes received so far,
+// using the middle of each prefix's interval at the full precision.
+func (d *ProgressiveDecoder) values(bits int) ([]float64, error) {
+	full := d.passes[len(d.passes)-1]
+	c := Config{MantissaBits: full}
+	mantMax := float64(mantMaxForBits(full))
+	missing := uint(full - bits)
+
+	out := make([]float64, len(d.headers))
+	for i, h := range d.headers {
+		if h == 0 {
+			continue
+		}
+		if missing == 0 {
+			v, err := c.join(h, d.prefix[i])
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+			continue
+		}
+		base, err := c.join(h, 0)
+		if err != nil {
+			return nil, err
+		}
+		lo := float64(d.prefix[i] << missing)
+		hi := min(float64((d.prefix[i]+1)<<missing)-1, mantMax)
+		out[i] = base * (1 + (lo+hi)/2/mantMax)
+	}
+	return out, nil
+}
+
+// checkProgressivePasses validates the cumulative bits of each pass.
+func checkProgressivePasses(passes []int) error {
+	if len(passes) == 0 {
+		return errors.New("varfloat: at least one progressive pass is required")
+	}
+	prev := -1
+	for _, p := range passes {
+		if p <= prev {
+			return errors.New("varfloat: progressive pass bits must be strictly increasing")
+		}
+		prev = p
+	}
+	if passes[0] < 0 || prev > 52 {
+		return errors.New("varfloat: mantissa bits must be between 0 and 52")
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package varfloat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+)
+
+func TestProgressivePasses(t *testing.T) {
+	values := append(sampleFloats(200, 11), 0, math.Copysign(0, -1), 1, -1e300, 5e-324)
+	passes := []int{0, 3, 10, 24, 40}
+	b, err := EncodeFloatsProgressive(values, passes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewProgressiveDecoder(bytes.NewReader(b))
+	for _, want := range passes {
+		got, bits, err := d.ReadPass()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bits != want {
+			t.Fatalf("pass decoded with %d bits, want %d", bits, want)
+		}
+		checkVecRelError(t, values, got, bits)
+		if bits == passes[len(passes)-1] {
+			c := Config{MantissaBits: bits}
+			for i, v := range values {
+				if q := c.quantize(v); got[i] != q {
+					t.Errorf("final pass decoded %g as %g, want %g", v, got[i], q)
+				}
+			}
+		}
+	}
+	if _, _, err := d.ReadPass(); err != io.EOF {
+		t.Errorf("ReadPass after the last pass returned %v, want io.EOF", err)
+	}
+}
+
+func TestDecodeFloatsProgressivePrefix(t *testing.T) {
+	values := sampleFloats(50, 12)
+	passes := []int{4, 12, 30}
+	b, err := EncodeFloatsProgressive(values, passes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Find where each pass ends.
+	ends := []int{}
+	offset := 2 + len(passes)
+	for range passes {
+		n, m := binary.Uvarint(b[offset:])
+		offset += m + int(n)
+		ends = append(ends, offset)
+	}
+	if offset != len(b) {
+		t.Fatalf("passes end at %d of %d bytes", offset, len(b))
+	}
+
+	for i := 0; i <= len(b); i++ {
+		got, bits, err := DecodeFloatsProgressive(b[:i])
+		complete := 0
+		for complete < len(ends) && ends[complete] <= i {
+			complete++
+		}
+		if complete == 0 {
+			if err == nil {
+				t.Errorf("decoding %d bytes without a whole first pass succeeded", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("decoding %d bytes: %v", i, err)
+		}
+		if bits != passes[complete-1] || len(got) != len(values) {
+			t.Errorf("decoding %d bytes gave %d values at %d bits, want %d at %d", i, len(got), bits, len(values), passes[complete-1])
+		}
+	}
+}
+
+func TestProgressiveEmpty(t *testing.T) {
+	b, err := EncodeFloatsProgressive(nil, []int{8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, bits, err := DecodeFloatsProgressive(b)
+	if err != nil || len(got) != 0 || bits != 8 {
+		t.Errorf("DecodeFloatsProgressive(empty) = %v, %d, %v", got, bits, err)
+	}
+}
+
+func TestProgressiveInvalid(t *testing.T) {
+	for _, passes := range [][]int{nil, {4, 4}, {8, 3}, {-1, 4}, {10, 53}} {
+		if _, err := EncodeFloatsProgressive([]float64{1}, passes); err == nil {
+			t.Errorf("passes %v accepted", passes)
+		}
+	}
+	for _, v := range []float64{math.Inf(1), math.NaN()} {
+		if _, err := EncodeFloatsProgressive([]float64{v}, []int{8}); err == nil {
+			t.Errorf("%g accepted", v)
+		}
+	}
+
+	// A count of 2^32 values must not be allocated for a five byte pass.
+	b := binary.AppendUvarint(nil, 1<<32)
+	b = append(b, 1, 10, 5, 2, 2, 2, 2, 2)
+	if _, _, err := DecodeFloatsProgressive(b); err == nil {
+		t.Error("oversized count accepted")
+	}
+
+	// A pass length past the end of the input must not be allocated either.
+	b = append(binary.AppendUvarint(nil, 1<<32), 1, 10)
+	b = binary.AppendUvarint(b, 1<<40)
+	if _, _, err := DecodeFloatsProgressive(b); err == nil {
+		t.Error("oversized pass length accepted")
+	}
+}
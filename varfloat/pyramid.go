@@ -0,0 +1,422 @@
+package varfloat
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math"
+)
+
+// PyramidConfig describes the levels of a multi-resolution series.
+//
+// Level 0 holds every sample. Level k (1 <= k <= len(levels)) holds one
+// min/max/mean summary per window of Factor^k consecutive samples.
+type PyramidConfig struct {
+	// Factor is the window growth between levels; it must be >= 2.
+	Factor int
+	// Bits is the mantissa precision of the full-resolution samples.
+	Bits int
+	// LevelBits is the mantissa precision of the downsampled summaries.
+	// Summaries are only for display, so this is usually well below Bits.
+	LevelBits int
+	// ChunkSize is the number of samples (level 0) or windows (other levels)
+	// per stream chunk; it must be >= 1.
+	ChunkSize int
+}
+
+// validate checks that the config is usable.
+func (c PyramidConfig) validate() error {
+	if c.Factor < 2 {
+		return errors.New("varfloat: pyramid factor must be >= 2")
+	}
+	if c.ChunkSize < 1 {
+		return errors.New("varfloat: pyramid chunk size must be >= 1")
+	}
+	if c.Bits < 0 || c.Bits > 52 || c.LevelBits < 0 || c.LevelBits > 52 {
+		return errors.New("varfloat: mantissa bits must be between 0 and 52")
+	}
+	return nil
+}
+
+// PyramidPoint is one point of a pyramid level: the summary of the window of
+// Count samples starting at sample index Start. Level 0 points have Count 1
+// and Min == Max == Mean. If the series ended inside the last window of a
+// level, that point's Count is the number of samples it holds.
+type PyramidPoint struct {
+	Start, Count   int
+	Min, Max, Mean float64
+}
+
+// PyramidWriter writes a series as a full-resolution float stream plus
+// downsampled levels, so long histories can be viewed zoomed out without
+// decoding every sample.
+//
+// The full-resolution stream is a plain FloatStreamEncoder stream, readable by
+// FloatStreamDecoder on its own. Each level is a separate FloatStreamEncoder
+// stream whose chunks hold flattened [min, max, mean] triples, one per window,
+// encoded with LevelBits. Summaries are computed from the exact samples; their
+// min and max are then rounded to LevelBits like any other value.
+//
+// Close ends every level stream with a one-value chunk holding the length of
+// the series at 52 bits, which encodes it exactly. It gives the sample count
+// of a partial last window without reading the full-resolution stream.
+type PyramidWriter struct {
+	cfg     PyramidConfig
+	full    *FloatStreamEncoder
+	levels  []*FloatStreamEncoder
+	samples []float64
+	windows []FloatAggregate
+	pending [][]float64
+	// length is the number of samples written so far.
+	length int
+}
+
+// NewPyramidWriter creates a PyramidWriter that writes full-resolution chunks
+// to full and level k chunks to levels[k-1].
+func NewPyramidWriter(full io.Writer, levels []io.Writer, cfg PyramidConfig) (*PyramidWriter, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	pw := &PyramidWriter{
+		cfg:     cfg,
+		full:    NewFloatStreamEncoder(full),
+		windows: make([]FloatAggregate, len(levels)),
+		pending: make([][]float64, len(levels)),
+	}
+	for _, w := range levels {
+		pw.levels = append(pw.levels, NewFloatStreamEncoder(w))
+	}
+	return pw, nil
+}
+
+// Write appends samples to the series, writing chunks as they fill up.
+func (pw *PyramidWriter) Write(values ...float64) error {
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return errors.New("varfloat: pyramid samples must be finite")
+		}
+		pw.samples = append(pw.samples, v)
+		pw.length++
+		if len(pw.samples) == pw.cfg.ChunkSize {
+			if err := pw.flushSamples(); err != nil {
+				return err
+			}
+		}
+
+		size := 1
+		for k := range pw.windows {
+			size *= pw.cfg.Factor
+			pw.windows[k].add(v)
+			if pw.windows[k].Count == size {
+				if err := pw.closeWindow(k); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Close writes any partial chunks and windows, then the series length that
+// ends each level stream. A trailing partial window is summarized over the
+// samples it has. Close does not close the underlying writers.
+func (pw *PyramidWriter) Close() error {
+	if len(pw.samples) > 0 {
+		if err := pw.flushSamples(); err != nil {
+			return err
+		}
+	}
+	for k := range pw.windows {
+		if pw.windows[k].Count > 0 {
+			if err := pw.closeWindow(k); err != nil {
+				return err
+			}
+		}
+		if len(pw.pending[k]) > 0 {
+			if err := pw.flushLevel(k); err != nil {
+				return err
+			}
+		}
+		if err := pw.levels[k].WriteChunk([]float64{float64(pw.length)}, 52); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushSamples writes the buffered full-resolution samples as one chunk.
+func (pw *PyramidWriter) flushSamples() error {
+	err := pw.full.WriteChunk(pw.samples, pw.cfg.Bits)
+	pw.samples = pw.samples[:0]
+	return err
+}
+
+// closeWindow queues the summary of level k's current window.
+func (pw *PyramidWriter) closeWindow(k int) error {
+	agg := pw.windows[k]
+	pw.windows[k] = FloatAggregate{}
+	pw.pending[k] = append(pw.pending[k], agg.Min, agg.Max, agg.Mean())
+	if len(pw.pending[k]) == 3*pw.cfg.ChunkSize {
+		return pw.flushLevel(k)
+	}
+	return nil
+}
+
+// flushLevel writes level k's queued summaries as one chunk.
+func (pw *PyramidWriter) flushLevel(k int) error {
+	err := pw.levels[k].WriteChunk(pw.pending[k], pw.cfg.LevelBits)
+	pw.pending[k] = pw.pending[k][:0]
+	return err
+}
+
+// PyramidReader reads a series written by PyramidWriter and answers range
+// queries from the finest level that fits within a point budget.
+//
+// Levels are read lazily. A query reads a level's stream at most one chunk
+// past the chunk holding the end of its range, and decodes only the chunks
+// that overlap the range; every chunk holds ChunkSize points, so the others
+// are found without decoding them. Chunks that have been read are kept,
+// encoded, so later queries may go back to earlier ranges.
+//
+// The last window of a downsampled level may be partial. Its sample count
+// comes from the series length that ends the level stream, so no query reads
+// a level other than the one it was answered from.
+type PyramidReader struct {
+	cfg    PyramidConfig
+	levels []*pyramidLevel
+}
+
+// pyramidLevel is the part of one level stream read so far.
+type pyramidLevel struct {
+	r      *bufio.Reader
+	size   int
+	chunks []pyramidChunk
+	eof    bool
+	err    error
+	// ahead is the last chunk read from a downsampled level. It is only
+	// known to hold summaries once another chunk follows it; the final chunk
+	// holds the series length instead.
+	ahead *pyramidChunk
+	// length is the series length from a downsampled level's final chunk,
+	// set once the stream has been read to its end.
+	length int
+}
+
+// pyramidChunk is one chunk of a level stream. points is nil until the chunk
+// is first decoded.
+type pyramidChunk struct {
+	bits    int
+	flags   uint64
+	payload []byte
+	points  []PyramidPoint
+}
+
+// NewPyramidReader creates a PyramidReader over the full-resolution stream
+// and the level streams, in the same order they were given to
+// NewPyramidWriter. cfg must match the writer's Factor and ChunkSize.
+func NewPyramidReader(full io.Reader, levels []io.Reader, cfg PyramidConfig) (*PyramidReader, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	pr := &PyramidReader{cfg: cfg}
+	size := 1
+	for _, r := range append([]io.Reader{full}, levels...) {
+		pr.levels = append(pr.levels, &pyramidLevel{r: bufio.NewReader(r), size: size})
+		size *= cfg.Factor
+	}
+	return pr, nil
+}
+
+// Levels returns the number of levels, including the full-resolution level.
+func (pr *PyramidReader) Levels() int {
+	return len(pr.levels)
+}
+
+// Query returns the points covering samples [start, end) from the finest
+// level that needs at most maxPoints points for that range (or the coarsest
+// level if none does), along with the chosen level. Points of a
+// downsampled level cover whole windows, so the first and last may extend
+// past the range.
+func (pr *PyramidReader) Query(start, end, maxPoints int) ([]PyramidPoint, int, error) {
+	if start < 0 || end < start {
+		return nil, 0, errors.New("varfloat: invalid pyramid query range")
+	}
+	if maxPoints < 1 {
+		return nil, 0, errors.New("varfloat: maxPoints must be >= 1")
+	}
+
+	level, size := 0, 1
+	for level < len(pr.levels)-1 && windowsCovering(start, end, size) > maxPoints {
+		level++
+		size *= pr.cfg.Factor
+	}
+	if end == start {
+		return []PyramidPoint{}, level, nil
+	}
+
+	l := pr.levels[level]
+	first, last := start/size, (end-1)/size
+	out := []PyramidPoint{}
+	for c := first / pr.cfg.ChunkSize; c <= last/pr.cfg.ChunkSize; c++ {
+		pts, err := pr.chunkPoints(l, c)
+		if err != nil {
+			return nil, 0, err
+		}
+		if pts == nil {
+			break
+		}
+		base := c * pr.cfg.ChunkSize
+		lo := max(first-base, 0)
+		hi := min(last-base+1, len(pts))
+		if lo >= hi {
+			break
+		}
+		out = append(out, pts[lo:hi]...)
+	}
+	return out, level, nil
+}
+
+// chunkPoints returns the decoded points of chunk c of level l, reading the
+// stream as far as that chunk. It returns nil if the level has fewer chunks.
+func (pr *PyramidReader) chunkPoints(l *pyramidLevel, c int) ([]PyramidPoint, error) {
+	if err := l.readChunks(c + 1); err != nil {
+		return nil, err
+	}
+	if c >= len(l.chunks) {
+		return nil, nil
+	}
+	ch := &l.chunks[c]
+	if ch.points != nil {
+		return ch.points, nil
+	}
+
+	values, err := decodePyramidChunk(ch)
+	if err != nil {
+		return nil, err
+	}
+	width := 1
+	if l.size > 1 {
+		width = 3
+	}
+	if len(values)%width != 0 {
+		return nil, errors.New("varfloat: pyramid level chunk is not made of triples")
+	}
+	n := len(values) / width
+	if n == 0 || n > pr.cfg.ChunkSize {
+		return nil, errors.New("varfloat: pyramid chunk has the wrong number of points")
+	}
+	lastChunk := false
+	if n < pr.cfg.ChunkSize || width == 3 {
+		if err := l.readChunks(c + 2); err != nil {
+			return nil, err
+		}
+		lastChunk = c+1 >= len(l.chunks)
+	}
+	if n < pr.cfg.ChunkSize && !lastChunk {
+		// Only the final chunk of a level may be short.
+		return nil, errors.New("varfloat: pyramid chunk has the wrong number of points")
+	}
+
+	pts := make([]PyramidPoint, n)
+	base := c * pr.cfg.ChunkSize
+	for i := range pts {
+		if width == 1 {
+			v := values[i]
+			pts[i] = PyramidPoint{Start: base + i, Count: 1, Min: v, Max: v, Mean: v}
+			continue
+		}
+		pts[i] = PyramidPoint{
+			Start: (base + i) * l.size,
+			Count: l.size,
+			Min:   values[3*i],
+			Max:   values[3*i+1],
+			Mean:  values[3*i+2],
+		}
+	}
+	if lastChunk && width == 3 {
+		p := &pts[n-1]
+		p.Count = l.length - p.Start
+		if p.Count < 1 || p.Count > l.size {
+			return nil, errors.New("varfloat: pyramid level does not match the series length")
+		}
+	}
+	ch.points = pts
+	return pts, nil
+}
+
+// readChunks reads chunks from the level stream until n have been read or
+// the stream ends. A downsampled level is read one chunk ahead, so that its
+// final chunk is taken as the series length rather than as summaries.
+func (l *pyramidLevel) readChunks(n int) error {
+	if l.err != nil {
+		return l.err
+	}
+	for len(l.chunks) < n && !l.eof {
+		bits, flags, payload, err := readChunk(l.r, floatStreamFlags)
+		if err == io.EOF {
+			l.eof = true
+			if l.size > 1 {
+				return l.readLength()
+			}
+			break
+		}
+		if err != nil {
+			l.err = err
+			return err
+		}
+		ch := pyramidChunk{bits: bits, flags: flags, payload: payload}
+		if l.size == 1 {
+			l.chunks = append(l.chunks, ch)
+			continue
+		}
+		if l.ahead != nil {
+			l.chunks = append(l.chunks, *l.ahead)
+		}
+		l.ahead = &ch
+	}
+	return nil
+}
+
+// readLength decodes the series length from the final chunk of a downsampled
+// level.
+func (l *pyramidLevel) readLength() error {
+	if l.ahead == nil {
+		l.err = errors.New("varfloat: pyramid level is missing the series length")
+		return l.err
+	}
+	values, err := decodePyramidChunk(l.ahead)
+	l.ahead = nil
+	if err == nil && (len(values) != 1 || !(values[0] >= 0 && values[0] <= 1<<53)) {
+		err = errors.New("varfloat: invalid pyramid series length")
+	}
+	if err != nil {
+		l.err = err
+		return err
+	}
+	l.length = int(math.Round(values[0]))
+	return nil
+}
+
+// decodePyramidChunk decodes the values of a level chunk.
+func decodePyramidChunk(ch *pyramidChunk) ([]float64, error) {
+	buf := ch.payload
+	if ch.flags&chunkFlagStats != 0 {
+		var err error
+		if _, buf, err = parseChunkStats(buf); err != nil {
+			return nil, err
+		}
+	}
+	if len(buf) == 0 {
+		return nil, nil
+	}
+	return decodeFloatsChunk(buf, ch.bits, ch.flags)
+}
+
+// windowsCovering returns how many windows of the given size overlap
+// [start, end).
+func windowsCovering(start, end, size int) int {
+	if end <= start {
+		return 0
+	}
+	return (end+size-1)/size - start/size
+}
@@ -0,0 +1,228 @@
+package varfloat
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"testing"
+)
+
+var testPyramidConfig = PyramidConfig{Factor: 4, Bits: 30, LevelBits: 20, ChunkSize: 16}
+
+// writeTestPyramid writes n samples of a noisy sine wave with the given
+// number of downsampled levels.
+func writeTestPyramid(t *testing.T, n, levels int, cfg PyramidConfig) ([]float64, *bytes.Buffer, []*bytes.Buffer) {
+	t.Helper()
+	full := new(bytes.Buffer)
+	bufs := make([]*bytes.Buffer, levels)
+	ws := make([]io.Writer, levels)
+	for i := range bufs {
+		bufs[i] = new(bytes.Buffer)
+		ws[i] = bufs[i]
+	}
+	pw, err := NewPyramidWriter(full, ws, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := make([]float64, n)
+	noise := sampleFloats(n, 7)
+	for i := range samples {
+		samples[i] = 100*math.Sin(float64(i)/50) + noise[i]/1e4
+	}
+	if err := pw.Write(samples...); err != nil {
+		t.Fatal(err)
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return samples, full, bufs
+}
+
+func newTestPyramidReader(t *testing.T, full *bytes.Buffer, levels []*bytes.Buffer, cfg PyramidConfig) *PyramidReader {
+	t.Helper()
+	rs := make([]io.Reader, len(levels))
+	for i, b := range levels {
+		rs[i] = bytes.NewReader(b.Bytes())
+	}
+	pr, err := NewPyramidReader(bytes.NewReader(full.Bytes()), rs, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pr
+}
+
+func TestPyramidQuery(t *testing.T) {
+	const n = 1000
+	samples, full, levels := writeTestPyramid(t, n, 3, testPyramidConfig)
+	pr := newTestPyramidReader(t, full, levels, testPyramidConfig)
+	if pr.Levels() != 4 {
+		t.Fatalf("Levels() = %d, want 4", pr.Levels())
+	}
+
+	tests := []struct {
+		start, end, maxPoints, level int
+	}{
+		{0, 10, 100, 0},
+		{990, 1000, 10, 0},
+		{100, 300, 100, 1},
+		{0, 1000, 100, 2},
+		{0, 1000, 5, 3},
+		{950, 1200, 10, 3},
+		{900, 1000, 1, 3},
+		{500, 500, 1, 0},
+		{2000, 3000, 1000, 0},
+	}
+	for _, tt := range tests {
+		pts, level, err := pr.Query(tt.start, tt.end, tt.maxPoints)
+		if err != nil {
+			t.Fatalf("Query(%d, %d, %d): %v", tt.start, tt.end, tt.maxPoints, err)
+		}
+		if level != tt.level {
+			t.Errorf("Query(%d, %d, %d) chose level %d, want %d", tt.start, tt.end, tt.maxPoints, level, tt.level)
+		}
+		size := int(math.Pow(4, float64(level)))
+		want := windowsCovering(min(tt.start, n), min(tt.end, n), size)
+		if len(pts) != want {
+			t.Fatalf("Query(%d, %d, %d) returned %d points, want %d", tt.start, tt.end, tt.maxPoints, len(pts), want)
+		}
+		bits := testPyramidConfig.LevelBits
+		if level == 0 {
+			bits = testPyramidConfig.Bits
+		}
+		for _, p := range pts {
+			window := samples[p.Start:min(p.Start+size, n)]
+			if p.Count != len(window) {
+				t.Errorf("level %d point at %d has Count %d, want %d", level, p.Start, p.Count, len(window))
+			}
+			lo, hi, sum := math.Inf(1), math.Inf(-1), 0.0
+			for _, v := range window {
+				lo, hi, sum = math.Min(lo, v), math.Max(hi, v), sum+v
+			}
+			checkVecRelError(t, []float64{lo, hi, sum / float64(len(window))}, []float64{p.Min, p.Max, p.Mean}, bits)
+		}
+	}
+}
+
+func TestPyramidPartialWindowCount(t *testing.T) {
+	// 70 samples leave a final window of 2 samples at level 1 and 6 at level 2.
+	_, full, levels := writeTestPyramid(t, 70, 2, testPyramidConfig)
+	pr := newTestPyramidReader(t, full, levels, testPyramidConfig)
+	for _, tt := range []struct{ maxPoints, count int }{{5, 2}, {2, 6}} {
+		pts, level, err := pr.Query(60, 70, tt.maxPoints)
+		if err != nil {
+			t.Fatal(err)
+		}
+		last := pts[len(pts)-1]
+		if last.Count != tt.count {
+			t.Errorf("level %d final window has Count %d, want %d", level, last.Count, tt.count)
+		}
+	}
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestPyramidCoarseQuerySkipsFullResolution(t *testing.T) {
+	// 10006 samples leave a final window of 2 samples at level 1 and 6 at
+	// level 2.
+	_, full, levels := writeTestPyramid(t, 10006, 2, testPyramidConfig)
+	fr := &countingReader{r: bytes.NewReader(full.Bytes())}
+	rs := []io.Reader{bytes.NewReader(levels[0].Bytes()), bytes.NewReader(levels[1].Bytes())}
+	pr, err := NewPyramidReader(fr, rs, testPyramidConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct{ maxPoints, count int }{{10, 2}, {1, 6}} {
+		pts, level, err := pr.Query(9990, 10006, tt.maxPoints)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last := pts[len(pts)-1]; last.Count != tt.count {
+			t.Errorf("level %d final window has Count %d, want %d", level, last.Count, tt.count)
+		}
+	}
+	if fr.n != 0 {
+		t.Errorf("coarse queries read %d bytes of the full-resolution stream", fr.n)
+	}
+}
+
+func TestPyramidReadsLazily(t *testing.T) {
+	_, full, levels := writeTestPyramid(t, 100000, 1, testPyramidConfig)
+	fr := &countingReader{r: bytes.NewReader(full.Bytes())}
+	lr := &countingReader{r: bytes.NewReader(levels[0].Bytes())}
+	pr, err := NewPyramidReader(fr, []io.Reader{lr}, testPyramidConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := pr.Query(0, 100, 100); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := pr.Query(0, 2000, 1000); err != nil {
+		t.Fatal(err)
+	}
+	if fr.n > full.Len()/10 || lr.n > levels[0].Len()/10 {
+		t.Errorf("early queries read %d of %d full and %d of %d level bytes", fr.n, full.Len(), lr.n, levels[0].Len())
+	}
+	// Earlier ranges stay available after reading further.
+	pts, _, err := pr.Query(5, 6, 1)
+	if err != nil || len(pts) != 1 || pts[0].Start != 5 {
+		t.Errorf("Query(5, 6, 1) = %v, %v", pts, err)
+	}
+}
+
+func TestPyramidInvalid(t *testing.T) {
+	if _, err := NewPyramidWriter(io.Discard, nil, PyramidConfig{Factor: 1, ChunkSize: 1}); err == nil {
+		t.Error("factor 1 accepted")
+	}
+	if _, err := NewPyramidReader(bytes.NewReader(nil), nil, PyramidConfig{Factor: 2}); err == nil {
+		t.Error("chunk size 0 accepted")
+	}
+	pw, err := NewPyramidWriter(io.Discard, nil, testPyramidConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pw.Write(math.NaN()); err == nil {
+		t.Error("NaN sample accepted")
+	}
+
+	_, full, levels := writeTestPyramid(t, 100, 1, testPyramidConfig)
+	pr := newTestPyramidReader(t, full, levels, testPyramidConfig)
+	if _, _, err := pr.Query(-1, 5, 10); err == nil {
+		t.Error("negative start accepted")
+	}
+	if _, _, err := pr.Query(5, 4, 10); err == nil {
+		t.Error("end before start accepted")
+	}
+	if _, _, err := pr.Query(0, 5, 0); err == nil {
+		t.Error("maxPoints 0 accepted")
+	}
+
+	// Every truncation of the level stream must fail a query for its end.
+	// One that cuts between chunks leaves a last chunk of summaries, which
+	// does not read as the series length.
+	lb := levels[0].Bytes()
+	for i := 0; i < len(lb); i++ {
+		pr := newTestPyramidReader(t, full, []*bytes.Buffer{bytes.NewBuffer(lb[:i])}, testPyramidConfig)
+		if _, _, err := pr.Query(0, 100, 25); err == nil {
+			t.Errorf("query over %d of %d level bytes succeeded", i, len(lb))
+		}
+	}
+
+	// A reader whose chunk size differs from the writer's sees short chunks
+	// in the middle of the stream.
+	cfg := testPyramidConfig
+	cfg.ChunkSize = 32
+	pr = newTestPyramidReader(t, full, levels, cfg)
+	if _, _, err := pr.Query(0, 100, 100); err == nil {
+		t.Error("mismatched chunk size accepted")
+	}
+}
@@ -0,0 +1,309 @@
+package varfloat
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// Quat is a rotation quaternion with vector part (X, Y, Z) and scalar part W.
+type Quat struct {
+	X, Y, Z, W float64
+}
+
+// QuatNormalize returns a unit-length copy of q. If q has zero length, it
+// returns the identity rotation.
+func QuatNormalize(q Quat) Quat {
+	// Scale by the largest component first so the length cannot overflow or
+	// underflow.
+	m := max(math.Abs(q.X), math.Abs(q.Y), math.Abs(q.Z), math.Abs(q.W))
+	if m == 0 {
+		return Quat{W: 1}
+	}
+	q = Quat{X: q.X / m, Y: q.Y / m, Z: q.Z / m, W: q.W / m}
+	n := math.Sqrt(q.X*q.X + q.Y*q.Y + q.Z*q.Z + q.W*q.W)
+	return Quat{X: q.X / n, Y: q.Y / n, Z: q.Z / n, W: q.W / n}
+}
+
+// QuatAngle returns the angle, in radians, of the rotation that takes a to b.
+// q and -q describe the same rotation, so the result is in [0, π].
+func QuatAngle(a, b Quat) float64 {
+	a, b = QuatNormalize(a), QuatNormalize(b)
+	if a.X*b.X+a.Y*b.Y+a.Z*b.Z+a.W*b.W < 0 {
+		b = Quat{X: -b.X, Y: -b.Y, Z: -b.Z, W: -b.W}
+	}
+	// The angle between the quaternions is 2*atan2(|a-b|, |a+b|), which
+	// unlike acos of the dot product stays accurate for tiny angles.
+	diff := math.Sqrt((a.X-b.X)*(a.X-b.X) + (a.Y-b.Y)*(a.Y-b.Y) + (a.Z-b.Z)*(a.Z-b.Z) + (a.W-b.W)*(a.W-b.W))
+	sum := math.Sqrt((a.X+b.X)*(a.X+b.X) + (a.Y+b.Y)*(a.Y+b.Y) + (a.Z+b.Z)*(a.Z+b.Z) + (a.W+b.W)*(a.W+b.W))
+	return 4 * math.Atan2(diff, sum)
+}
+
+// AppendQuat encodes a rotation with smallest-three compression and appends
+// it to dst.
+//
+// A unit quaternion's largest component is determined (up to sign) by the
+// other three, and those three always lie in [-1/sqrt(2), 1/sqrt(2)]. Since q
+// and -q are the same rotation, the quaternion is flipped so the largest
+// component is positive, and only its 2-bit index plus the other three
+// components (bits bits each, bounded to that range) are written:
+//
+//	[2-bit index][3 x bits-bit components], padded to a whole byte
+//
+// q is normalized with QuatNormalize first and must be finite. Use BitsForQuatMaxAngularError to
+// choose bits from an angular error budget. bits must be in [1, 32].
+func AppendQuat(dst []byte, q Quat, bits int) ([]byte, error) {
+	if err := checkQuatBits(bits); err != nil {
+		return nil, err
+	}
+	var w bitWriter
+	if err := writeQuat(&w, q, bits); err != nil {
+		return nil, err
+	}
+	return append(dst, w.bytes()...), nil
+}
+
+// ConsumeQuat decodes a rotation written by AppendQuat from the beginning of
+// b using the same bits. The result has unit length.
+func ConsumeQuat(b []byte, bits int) (Quat, int, error) {
+	if err := checkQuatBits(bits); err != nil {
+		return Quat{}, 0, err
+	}
+	r := bitReader{b: b}
+	q, err := readQuat(&r, bits)
+	if err != nil {
+		return Quat{}, 0, err
+	}
+	return q, packedLen(1, 2+3*bits), nil
+}
+
+// EncodeQuatSlice encodes a slice of rotations with smallest-three
+// compression. The slice length is written as a uvarint, followed by all
+// quaternions bit-packed back to back (2+3*bits bits each).
+func EncodeQuatSlice(qs []Quat, bits int) ([]byte, error) {
+	if err := checkQuatBits(bits); err != nil {
+		return nil, err
+	}
+	var buf [10]byte
+	n := binary.PutUvarint(buf[:], uint64(len(qs)))
+	out := append([]byte(nil), buf[:n]...)
+
+	var w bitWriter
+	for _, q := range qs {
+		if err := writeQuat(&w, q, bits); err != nil {
+			return nil, err
+		}
+	}
+	return append(out, w.bytes()...), nil
+}
+
+// DecodeQuatSlice decodes a slice of rotations encoded by EncodeQuatSlice
+// using the same bits.
+func DecodeQuatSlice(b []byte, bits int) ([]Quat, int, error) {
+	if err := checkQuatBits(bits); err != nil {
+		return nil, 0, err
+	}
+	count, n := binary.Uvarint(b)
+	if n <= 0 {
+		return nil, 0, errors.New("varfloat: invalid slice length")
+	}
+	if count > uint64(len(b)-n)*8 {
+		return nil, 0, errors.New("varfloat: slice length exceeds buffer")
+	}
+
+	r := bitReader{b: b[n:]}
+	out := make([]Quat, 0, count)
+	for i := uint64(0); i < count; i++ {
+		q, err := readQuat(&r, bits)
+		if err != nil {
+			return nil, 0, err
+		}
+		out = append(out, q)
+	}
+	return out, n + packedLen(int(count), 2+3*bits), nil
+}
+
+// MaxQuatAngularErrorForBits returns an upper bound, in radians, on the
+// rotation angle between a quaternion and its smallest-three encoding with
+// the given bits.
+//
+// Each stored component is off by at most half a step, 1/(sqrt(2)*(2^bits-1)).
+// Rebuilding the largest component (which is at least 1/2) can amplify that
+// error, and the rotation angle is twice the angle between the quaternions,
+// which together give:
+//
+//	maxAngle ≈ 4*sqrt(3/2) / (2^bits-1)
+//
+// This is a first-order estimate. It holds from 3 bits up; with fewer bits
+// the encoding can be off by almost any rotation, so π is returned. bits
+// outside [1,32] are clamped into that range.
+func MaxQuatAngularErrorForBits(bits int) float64 {
+	if bits < 3 {
+		return math.Pi
+	} else if bits > 32 {
+		bits = 32
+	}
+	return 4 * math.Sqrt(1.5) / (math.Ldexp(1, bits) - 1)
+}
+
+// BitsForQuatMaxAngularError returns the smallest bit count whose
+// MaxQuatAngularErrorForBits is within maxAngle (in radians). maxAngle must be
+// in (0, π].
+func BitsForQuatMaxAngularError(maxAngle float64) (int, error) {
+	if maxAngle <= 0 || maxAngle > math.Pi {
+		return 0, errors.New("varfloat: maxAngle must be in (0, π]")
+	}
+	for bits := 1; bits < 32; bits++ {
+		if MaxQuatAngularErrorForBits(bits) <= maxAngle {
+			return bits, nil
+		}
+	}
+	return 32, nil
+}
+
+// QuatEncoder is a convenience wrapper that holds a chosen component precision
+// and exposes helpers for encoding Quat values and slices.
+type QuatEncoder struct {
+	Bits int
+}
+
+// NewQuatEncoder constructs a QuatEncoder from a desired maximum rotation
+// error in radians. It uses BitsForQuatMaxAngularError under the hood.
+func NewQuatEncoder(maxAngle float64) (*QuatEncoder, error) {
+	bits, err := BitsForQuatMaxAngularError(maxAngle)
+	if err != nil {
+		return nil, err
+	}
+	return &QuatEncoder{Bits: bits}, nil
+}
+
+// Encode encodes a single Quat using the encoder's bits.
+func (e *QuatEncoder) Encode(q Quat) ([]byte, error) {
+	return AppendQuat(nil, q, e.Bits)
+}
+
+// EncodeSlice encodes a slice of Quat values using the encoder's bits.
+func (e *QuatEncoder) EncodeSlice(qs []Quat) ([]byte, error) {
+	return EncodeQuatSlice(qs, e.Bits)
+}
+
+// QuatStreamEncoder writes chunks of Quat slices to an io.Writer using the
+// same chunk format as FloatStreamEncoder but with EncodeQuatSlice payloads.
+type QuatStreamEncoder struct {
+	w io.Writer
+}
+
+// NewQuatStreamEncoder creates a QuatStreamEncoder that writes to w.
+func NewQuatStreamEncoder(w io.Writer) *QuatStreamEncoder {
+	return &QuatStreamEncoder{w: w}
+}
+
+// WriteChunk encodes a slice of Quat values with the given bits and writes it
+// as a self-contained chunk to the underlying writer.
+func (e *QuatStreamEncoder) WriteChunk(qs []Quat, bits int) error {
+	payload, err := EncodeQuatSlice(qs, bits)
+	if err != nil {
+		return err
+	}
+	return writeChunk(e.w, bits, 0, payload)
+}
+
+// QuatStreamDecoder reads chunks of Quat slices from an io.Reader that were
+// written by QuatStreamEncoder.
+type QuatStreamDecoder struct {
+	r *bufio.Reader
+}
+
+// NewQuatStreamDecoder creates a QuatStreamDecoder that reads from r.
+func NewQuatStreamDecoder(r io.Reader) *QuatStreamDecoder {
+	return &QuatStreamDecoder{r: bufio.NewReader(r)}
+}
+
+// ReadChunk reads and decodes the next Quat slice chunk from the stream. It
+// returns the decoded quaternions, the bits that were used to encode them,
+// and an error. On EOF without any bytes read, it returns (nil, 0, io.EOF).
+func (d *QuatStreamDecoder) ReadChunk() ([]Quat, int, error) {
+	bits, _, buf, err := readChunk(d.r, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	if buf == nil {
+		return nil, bits, nil
+	}
+
+	qs, _, err := DecodeQuatSlice(buf, bits)
+	if err != nil {
+		return nil, 0, err
+	}
+	return qs, bits, nil
+}
+
+// checkQuatBits validates the per-component bit count for the quaternion
+// helpers.
+func checkQuatBits(bits int) error {
+	if bits < 1 || bits > 32 {
+		return errors.New("varfloat: quaternion bits must be between 1 and 32")
+	}
+	return nil
+}
+
+// writeQuat writes one smallest-three encoded quaternion to w.
+func writeQuat(w *bitWriter, q Quat, bits int) error {
+	for _, c := range [4]float64{q.X, q.Y, q.Z, q.W} {
+		if math.IsNaN(c) || math.IsInf(c, 0) {
+			return errors.New("varfloat: quaternion encoding requires finite components")
+		}
+	}
+	q = QuatNormalize(q)
+	comps := [4]float64{q.X, q.Y, q.Z, q.W}
+
+	largest := 0
+	for i := 1; i < 4; i++ {
+		if math.Abs(comps[i]) > math.Abs(comps[largest]) {
+			largest = i
+		}
+	}
+	if comps[largest] < 0 {
+		for i := range comps {
+			comps[i] = -comps[i]
+		}
+	}
+
+	w.writeBits(uint64(largest), 2)
+	for i, c := range comps {
+		if i == largest {
+			continue
+		}
+		// Scale [-1/sqrt(2), 1/sqrt(2)] onto [-1, 1].
+		w.writeBits(quantizeUnit(c*math.Sqrt2, bits), bits)
+	}
+	return nil
+}
+
+// readQuat reads one smallest-three encoded quaternion from r.
+func readQuat(r *bitReader, bits int) (Quat, error) {
+	idx, err := r.readBits(2)
+	if err != nil {
+		return Quat{}, err
+	}
+	largest := int(idx)
+
+	var comps [4]float64
+	sumSq := 0.0
+	for i := range comps {
+		if i == largest {
+			continue
+		}
+		qc, err := r.readBits(bits)
+		if err != nil {
+			return Quat{}, err
+		}
+		c := dequantizeUnit(qc, bits) / math.Sqrt2
+		comps[i] = c
+		sumSq += c * c
+	}
+	comps[largest] = math.Sqrt(math.Max(0, 1-sumSq))
+	return QuatNormalize(Quat{X: comps[0], Y: comps[1], Z: comps[2], W: comps[3]}), nil
+}
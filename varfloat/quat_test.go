@@ -0,0 +1,128 @@
+package varfloat
+
+import (
+	"bytes"
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// sampleQuats returns the identity, a few axis-aligned and evenly mixed
+// rotations, and n random rotations.
+func sampleQuats(n int, seed uint64) []Quat {
+	qs := []Quat{
+		{W: 1},
+		{X: 1},
+		{Y: -1},
+		{X: 0.5, Y: 0.5, Z: 0.5, W: 0.5},
+		{X: -0.5, Y: 0.5, Z: -0.5, W: 0.5},
+		{X: 1, W: 1},
+	}
+	r := rand.New(rand.NewPCG(seed, seed))
+	for range n {
+		qs = append(qs, Quat{X: r.NormFloat64(), Y: r.NormFloat64(), Z: r.NormFloat64(), W: r.NormFloat64()})
+	}
+	return qs
+}
+
+func TestQuatAngularError(t *testing.T) {
+	qs := sampleQuats(2000, 9)
+	for _, bits := range []int{1, 2, 3, 4, 8, 12, 16, 24, 32} {
+		bound := MaxQuatAngularErrorForBits(bits)
+		b, err := EncodeQuatSlice(qs, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, n, err := DecodeQuatSlice(b, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(b) || len(got) != len(qs) {
+			t.Fatalf("bits=%d: %d rotations in %d of %d bytes", bits, len(got), n, len(b))
+		}
+		for i, q := range qs {
+			if a := QuatAngle(q, got[i]); a > bound {
+				t.Errorf("bits=%d: %v decoded as %v, %g rad > %g", bits, q, got[i], a, bound)
+			}
+		}
+	}
+}
+
+func TestQuatAngle(t *testing.T) {
+	tests := []struct {
+		a, b Quat
+		want float64
+	}{
+		{Quat{W: 1}, Quat{W: 1}, 0},
+		{Quat{W: 1}, Quat{W: -1}, 0},
+		{Quat{W: 1}, Quat{X: 1}, math.Pi},
+		{Quat{W: 1}, Quat{Z: math.Sin(1e-9), W: math.Cos(1e-9)}, 2e-9},
+		{Quat{W: 1e300}, Quat{X: 1e-300, W: 1e-300}, math.Pi / 2},
+	}
+	for _, tt := range tests {
+		if got := QuatAngle(tt.a, tt.b); math.Abs(got-tt.want) > 1e-15 {
+			t.Errorf("QuatAngle(%v, %v) = %g, want %g", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestQuatInvalid(t *testing.T) {
+	for _, bits := range []int{0, 33} {
+		if _, err := AppendQuat(nil, Quat{W: 1}, bits); err == nil {
+			t.Errorf("%d bits accepted", bits)
+		}
+	}
+	if _, err := AppendQuat(nil, Quat{X: math.NaN(), W: 1}, 8); err == nil {
+		t.Error("NaN accepted")
+	}
+	if _, err := EncodeQuatSlice([]Quat{{W: math.Inf(1)}}, 8); err == nil {
+		t.Error("Inf accepted")
+	}
+
+	b, err := EncodeQuatSlice(sampleQuats(3, 10), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTruncated(t, b, func(b []byte) error {
+		_, _, err := DecodeQuatSlice(b, 10)
+		return err
+	})
+	empty, _ := EncodeQuatSlice(nil, 10)
+	if got, n, err := DecodeQuatSlice(empty, 10); err != nil || len(got) != 0 || n != len(empty) {
+		t.Errorf("empty slice: %v, %d, %v", got, n, err)
+	}
+}
+
+func TestQuatEncoderAndStream(t *testing.T) {
+	enc, err := NewQuatEncoder(0.001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if MaxQuatAngularErrorForBits(enc.Bits) > 0.001 {
+		t.Errorf("NewQuatEncoder(0.001) chose %d bits", enc.Bits)
+	}
+	q := Quat{X: 0.1, Y: 0.2, Z: 0.3, W: 0.9}
+	b, err := enc.Encode(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := ConsumeQuat(b, enc.Bits)
+	if err != nil || QuatAngle(q, got) > 0.001 {
+		t.Errorf("QuatEncoder round trip: %v, %v", got, err)
+	}
+
+	var buf bytes.Buffer
+	qs := sampleQuats(10, 11)
+	if err := NewQuatStreamEncoder(&buf).WriteChunk(qs, 12); err != nil {
+		t.Fatal(err)
+	}
+	dec, bits, err := NewQuatStreamDecoder(&buf).ReadChunk()
+	if err != nil || bits != 12 || len(dec) != len(qs) {
+		t.Fatalf("ReadChunk: %d rotations at %d bits, %v", len(dec), bits, err)
+	}
+	for i := range qs {
+		if QuatAngle(qs[i], dec[i]) > MaxQuatAngularErrorForBits(12) {
+			t.Errorf("rotation %d decoded as %v", i, dec[i])
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package varfloat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// FieldValue holds the value of one snapshot field. Only the member matching
+// the field's kind is used.
+type FieldValue struct {
+	Float float64
+	Int   int64
+	Vec3  Vec3
+}
+
+// EntityState holds the values of one entity, indexed like the schema.
+type EntityState []FieldValue
+
+// DefaultSnapshotHistory is the number of snapshots SnapshotEncoder and
+// SnapshotDecoder keep as possible baselines by default.
+const DefaultSnapshotHistory = 64
+
+// SnapshotEncoder writes game-state snapshots as deltas against the last
+// snapshot the client acknowledged, for one client connection.
+//
+// The schema lists the fields of every entity using the same specs as
+// columnar tables: ColumnFloat and ColumnVec3 fields are varfloats with Bits
+// mantissa bits, ColumnInt fields are bounded ints in [Min, Max] (Bits 0 means
+// lossless). A field is only sent when its quantized encoding differs from
+// the baseline, so small jitter below the field's precision costs nothing, and
+// entities with no changed fields are not sent at all.
+//
+// Layout of an encoded snapshot:
+//
+//	[uvarint seq][uvarint baselineSeq+1, or 0 for none]
+//	[uvarint changedCount] per entity: [uvarint id][field mask][changed fields]
+//	[uvarint removedCount] [uvarint id]...
+//
+// The field mask is one bit per schema field, packed MSB-first into whole
+// bytes. Entities are written in ascending id order.
+type SnapshotEncoder struct {
+	// History is the maximum number of unacknowledged snapshots kept as
+	// possible baselines. Older ones are dropped; if the client then acks one
+	// of them, the next snapshot is sent in full.
+	History int
+
+	schema   []ColumnSpec
+	seq      uint64
+	baseline uint64
+	hasBase  bool
+	sent     map[uint64]map[uint32][][]byte
+	order    []uint64
+}
+
+// NewSnapshotEncoder creates a SnapshotEncoder for entities described by
+// schema.
+func NewSnapshotEncoder(schema []ColumnSpec) (*SnapshotEncoder, error) {
+	if err := checkColumnSpecs(schema); err != nil {
+		return nil, err
+	}
+	return &SnapshotEncoder{
+		History: DefaultSnapshotHistory,
+		schema:  append([]ColumnSpec(nil), schema...),
+		sent:    make(map[uint64]map[uint32][][]byte),
+	}, nil
+}
+
+// Encode encodes the full state of every entity as a delta against the
+// current baseline and returns the snapshot's sequence number with the
+// encoded bytes. Sequence numbers start at 1 and increase by one per call.
+func (e *SnapshotEncoder) Encode(entities map[uint32]EntityState) (uint64, []byte, error) {
+	fields := make(map[uint32][][]byte, len(entities))
+	for id, st := range entities {
+		enc, err := encodeEntityFields(e.schema, st)
+		if err != nil {
+			return 0, nil, fmt.Errorf("varfloat: entity %d: %w", id, err)
+		}
+		fields[id] = enc
+	}
+
+	var base map[uint32][][]byte
+	if e.hasBase {
+		base = e.sent[e.baseline]
+	}
+
+	e.seq++
+	out := binary.AppendUvarint(nil, e.seq)
+	if base != nil {
+		out = binary.AppendUvarint(out, e.baseline+1)
+	} else {
+		out = binary.AppendUvarint(out, 0)
+	}
+
+	ids := make([]uint32, 0, len(fields))
+	for id := range fields {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	var body []byte
+	changed := 0
+	maskLen := (len(e.schema) + 7) / 8
+	for _, id := range ids {
+		cur, prev := fields[id], base[id]
+		mask := make([]byte, maskLen)
+		var vals []byte
+		for i, f := range cur {
+			if prev != nil && bytes.Equal(f, prev[i]) {
+				continue
+			}
+			mask[i/8] |= 0x80 >> uint(i%8)
+			vals = append(vals, f...)
+		}
+		if prev != nil && vals == nil {
+			continue
+		}
+		changed++
+		body = binary.AppendUvarint(body, uint64(id))
+		body = append(body, mask...)
+		body = append(body, vals...)
+	}
+	out = binary.AppendUvarint(out, uint64(changed))
+	out = append(out, body...)
+
+	var removed []uint32
+	for id := range base {
+		if _, ok := fields[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+	slices.Sort(removed)
+	out = binary.AppendUvarint(out, uint64(len(removed)))
+	for _, id := range removed {
+		out = binary.AppendUvarint(out, uint64(id))
+	}
+
+	e.sent[e.seq] = fields
+	e.order = append(e.order, e.seq)
+	for len(e.order) > max(e.History, 1) {
+		if e.order[0] != e.baseline || !e.hasBase {
+			delete(e.sent, e.order[0])
+		}
+		e.order = e.order[1:]
+	}
+	return e.seq, out, nil
+}
+
+// Ack records that the client received snapshot seq. Later snapshots are
+// encoded against the newest acknowledged snapshot; acks for older snapshots
+// are ignored.
+func (e *SnapshotEncoder) Ack(seq uint64) {
+	if e.hasBase && seq <= e.baseline {
+		return
+	}
+	if _, ok := e.sent[seq]; !ok {
+		// Too old to use as a baseline (or never sent).
+		return
+	}
+	if e.hasBase {
+		delete(e.sent, e.baseline)
+	}
+	e.baseline, e.hasBase = seq, true
+	for s := range e.sent {
+		if s < seq {
+			delete(e.sent, s)
+		}
+	}
+	e.order = slices.DeleteFunc(e.order, func(s uint64) bool { return s < seq })
+}
+
+// SnapshotDecoder reconstructs full snapshots on the client from the deltas
+// written by SnapshotEncoder.
+//
+// A snapshot can only be decoded while its baseline is still held, so one
+// that arrives after a newer snapshot with a later baseline fails with an
+// error and should be dropped; the next one will decode normally.
+type SnapshotDecoder struct {
+	// History is the maximum number of decoded snapshots kept as possible
+	// baselines; the oldest are dropped first.
+	History int
+
+	schema   []ColumnSpec
+	received map[uint64]map[uint32]EntityState
+}
+
+// NewSnapshotDecoder creates a SnapshotDecoder for entities described by
+// schema, which must match the encoder's.
+func NewSnapshotDecoder(schema []ColumnSpec) (*SnapshotDecoder, error) {
+	if err := checkColumnSpecs(schema); err != nil {
+		return nil, err
+	}
+	return &SnapshotDecoder{
+		History:  DefaultSnapshotHistory,
+		schema:   append([]ColumnSpec(nil), schema...),
+		received: make(map[uint64]map[uint32]EntityState),
+	}, nil
+}
+
+// Decode decodes a snapshot and returns its sequence number and the full
+// (quantized) state of every entity. The client should then acknowledge the
+// sequence number to the server. Snapshots older than the baseline used by b
+// are no longer needed and are dropped.
+func (d *SnapshotDecoder) Decode(b []byte) (uint64, map[uint32]EntityState, error) {
+	seq, n := binary.Uvarint(b)
+	if n <= 0 {
+		return 0, nil, errors.New("varfloat: invalid snapshot sequence")
+	}
+	offset := n
+	basePlus1, n := binary.Uvarint(b[offset:])
+	if n <= 0 {
+		return 0, nil, errors.New("varfloat: invalid snapshot baseline")
+	}
+	offset += n
+
+	state := make(map[uint32]EntityState)
+	if basePlus1 != 0 {
+		base, ok := d.received[basePlus1-1]
+		if !ok {
+			return 0, nil, errors.New("varfloat: snapshot baseline is unknown")
+		}
+		for id, st := range base {
+			state[id] = st
+		}
+	}
+
+	count, n := binary.Uvarint(b[offset:])
+	if n <= 0 || count > uint64(len(b)) {
+		return 0, nil, errors.New("varfloat: invalid snapshot entity count")
+	}
+	offset += n
+	maskLen := (len(d.schema) + 7) / 8
+	for i := uint64(0); i < count; i++ {
+		id, n := binary.Uvarint(b[offset:])
+		if n <= 0 || id > 1<<32-1 {
+			return 0, nil, errors.New("varfloat: invalid snapshot entity id")
+		}
+		offset += n
+		if len(b)-offset < maskLen {
+			return 0, nil, errors.New("varfloat: snapshot field mask truncated")
+		}
+		mask := b[offset : offset+maskLen]
+		offset += maskLen
+
+		prev, known := state[uint32(id)]
+		st := make(EntityState, len(d.schema))
+		copy(st, prev)
+		for f, spec := range d.schema {
+			if mask[f/8]&(0x80>>uint(f%8)) == 0 {
+				if !known {
+					return 0, nil, errors.New("varfloat: new snapshot entity is missing fields")
+				}
+				continue
+			}
+			v, n, err := consumeField(spec, b[offset:])
+			if err != nil {
+				return 0, nil, err
+			}
+			st[f] = v
+			offset += n
+		}
+		state[uint32(id)] = st
+	}
+
+	removed, n := binary.Uvarint(b[offset:])
+	if n <= 0 || removed > uint64(len(b)) {
+		return 0, nil, errors.New("varfloat: invalid snapshot removal count")
+	}
+	offset += n
+	for i := uint64(0); i < removed; i++ {
+		id, n := binary.Uvarint(b[offset:])
+		if n <= 0 || id > 1<<32-1 {
+			return 0, nil, errors.New("varfloat: invalid snapshot entity id")
+		}
+		offset += n
+		delete(state, uint32(id))
+	}
+	if offset != len(b) {
+		return 0, nil, errors.New("varfloat: trailing bytes after snapshot")
+	}
+
+	d.received[seq] = state
+	for s := range d.received {
+		if basePlus1 != 0 && s < basePlus1-1 {
+			delete(d.received, s)
+		}
+	}
+	for len(d.received) > max(d.History, 1) {
+		delete(d.received, slices.Min(slices.Collect(maps.Keys(d.received))))
+	}
+
+	out := make(map[uint32]EntityState, len(state))
+	for id, st := range state {
+		out[id] = slices.Clone(st)
+	}
+	return seq, out, nil
+}
+
+// encodeEntityFields encodes each field of st on its own.
+func encodeEntityFields(schema []ColumnSpec, st EntityState) ([][]byte, error) {
+	if len(st) != len(schema) {
+		return nil, fmt.Errorf("varfloat: entity has %d fields, schema has %d", len(st), len(schema))
+	}
+	out := make([][]byte, len(schema))
+	for i, spec := range schema {
+		c := Config{MantissaBits: spec.Bits}
+		switch spec.Kind {
+		case ColumnFloat:
+			out[i] = c.Append(nil, st[i].Float)
+		case ColumnInt:
+			enc, err := AppendIntBounded(nil, st[i].Int, spec.Min, spec.Max, columnIntBits(spec))
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %w", spec.Name, err)
+			}
+			out[i] = enc
+		case ColumnVec3:
+			v := st[i].Vec3
+			out[i] = c.Append(c.Append(c.Append(nil, v.X), v.Y), v.Z)
+		}
+	}
+	return out, nil
+}
+
+// consumeField decodes one field written by encodeEntityFields.
+func consumeField(spec ColumnSpec, b []byte) (FieldValue, int, error) {
+	c := Config{MantissaBits: spec.Bits}
+	switch spec.Kind {
+	case ColumnInt:
+		v, n, err := ConsumeIntBounded(b, spec.Min, spec.Max, columnIntBits(spec))
+		return FieldValue{Int: v}, n, err
+	case ColumnVec3:
+		var comps [3]float64
+		offset := 0
+		for i := range comps {
+			v, n, err := c.Consume(b[offset:])
+			if err != nil {
+				return FieldValue{}, 0, err
+			}
+			comps[i] = v
+			offset += n
+		}
+		return FieldValue{Vec3: Vec3{X: comps[0], Y: comps[1], Z: comps[2]}}, offset, nil
+	default:
+		v, n, err := c.Consume(b)
+		return FieldValue{Float: v}, n, err
+	}
+}
@@ -0,0 +1,232 @@
+package varfloat
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+var testSnapshotSchema = []ColumnSpec{
+	{Name: "pos", Kind: ColumnVec3, Bits: 16},
+	{Name: "health", Kind: ColumnInt, Min: 0, Max: 100},
+	{Name: "yaw", Kind: ColumnFloat, Bits: 10},
+}
+
+// testEntities returns the state of a few entities at a tick. Entity 3 only
+// exists for the first five ticks and entity 4 joins at tick 3.
+func testEntities(tick int) map[uint32]EntityState {
+	out := map[uint32]EntityState{}
+	for _, id := range []uint32{1, 2, 3, 4} {
+		if id == 3 && tick >= 5 || id == 4 && tick < 3 {
+			continue
+		}
+		f := float64(id)
+		out[id] = EntityState{
+			{Vec3: Vec3{X: f * 10, Y: float64(tick) * f, Z: -f}},
+			{Int: int64(100 - tick*int(id)%100)},
+			// Jitter far below the 10 bit precision should never be sent.
+			{Float: 1.5 + float64(tick%2)*1e-9},
+		}
+	}
+	return out
+}
+
+func newTestSnapshotPair(t *testing.T) (*SnapshotEncoder, *SnapshotDecoder) {
+	t.Helper()
+	enc, err := NewSnapshotEncoder(testSnapshotSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec, err := NewSnapshotDecoder(testSnapshotSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return enc, dec
+}
+
+// checkEntities compares decoded entities against the originals within each
+// field's precision.
+func checkEntities(t *testing.T, want, got map[uint32]EntityState) {
+	t.Helper()
+	if !slices.Equal(slices.Sorted(maps.Keys(want)), slices.Sorted(maps.Keys(got))) {
+		t.Fatalf("decoded entities %v, want %v", slices.Sorted(maps.Keys(got)), slices.Sorted(maps.Keys(want)))
+	}
+	for id, w := range want {
+		g := got[id]
+		checkVecRelError(t, []float64{w[0].Vec3.X, w[0].Vec3.Y, w[0].Vec3.Z}, []float64{g[0].Vec3.X, g[0].Vec3.Y, g[0].Vec3.Z}, 16)
+		if g[1].Int != w[1].Int {
+			t.Errorf("entity %d health %d, want %d", id, g[1].Int, w[1].Int)
+		}
+		checkVecRelError(t, []float64{w[2].Float}, []float64{g[2].Float}, 10)
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	enc, dec := newTestSnapshotPair(t)
+	full, fullDec := newTestSnapshotPair(t)
+	for tick := 0; tick < 10; tick++ {
+		seq, b, err := enc.Encode(testEntities(tick))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, gotState, err := dec.Decode(b)
+		if err != nil {
+			t.Fatalf("tick %d: %v", tick, err)
+		}
+		if got != seq || seq != uint64(tick+1) {
+			t.Errorf("tick %d: encoded seq %d, decoded %d", tick, seq, got)
+		}
+		checkEntities(t, testEntities(tick), gotState)
+
+		// A delta must decode to the same state as a full snapshot.
+		_, fb, err := full.Encode(testEntities(tick))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, fullState, err := fullDec.Decode(fb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for id, st := range fullState {
+			if !slices.EqualFunc(st, gotState[id], func(a, b FieldValue) bool { return a == b }) {
+				t.Errorf("tick %d entity %d: delta decoded %v, full %v", tick, id, gotState[id], st)
+			}
+		}
+		if tick > 0 && len(b) >= len(fb) {
+			t.Errorf("tick %d: delta is %d bytes, full snapshot %d", tick, len(b), len(fb))
+		}
+		enc.Ack(seq)
+	}
+}
+
+func TestSnapshotUnchanged(t *testing.T) {
+	enc, dec := newTestSnapshotPair(t)
+	state := testEntities(0)
+	seq, b, err := enc.Encode(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := dec.Decode(b); err != nil {
+		t.Fatal(err)
+	}
+	enc.Ack(seq)
+	_, b, err = enc.Encode(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// seq, baseline, no changed entities, no removals.
+	if len(b) != 4 {
+		t.Errorf("unchanged snapshot is %d bytes, want 4", len(b))
+	}
+	_, got, err := dec.Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEntities(t, state, got)
+}
+
+func TestSnapshotLostAndLateAcks(t *testing.T) {
+	enc, dec := newTestSnapshotPair(t)
+	enc.History = 3
+
+	seq1, b1, _ := enc.Encode(testEntities(0))
+	if _, _, err := dec.Decode(b1); err != nil {
+		t.Fatal(err)
+	}
+	enc.Ack(seq1)
+
+	// Snapshots 2..5 are all sent against 1 while their acks are lost.
+	for tick := 1; tick <= 4; tick++ {
+		_, b, err := enc.Encode(testEntities(tick))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, got, err := dec.Decode(b); err != nil {
+			t.Fatalf("tick %d: %v", tick, err)
+		} else {
+			checkEntities(t, testEntities(tick), got)
+		}
+	}
+
+	// Snapshot 2 has fallen out of the encoder's history, so acking it is
+	// ignored and the baseline stays at 1.
+	enc.Ack(2)
+	if enc.baseline != seq1 {
+		t.Errorf("baseline moved to %d after acking a dropped snapshot", enc.baseline)
+	}
+	_, b, err := enc.Encode(testEntities(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, got, err := dec.Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEntities(t, testEntities(5), got)
+
+	// Acking 6 moves the baseline on; the decoder then drops snapshots
+	// before 6, so a late copy of a snapshot against 1 no longer decodes.
+	enc.Ack(6)
+	_, b7, err := enc.Encode(testEntities(6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := dec.Decode(b7); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := dec.Decode(b); err == nil {
+		t.Error("snapshot against a dropped baseline decoded")
+	}
+}
+
+func TestSnapshotInvalid(t *testing.T) {
+	if _, err := NewSnapshotEncoder([]ColumnSpec{{Name: "a"}, {Name: "a"}}); err == nil {
+		t.Error("duplicate field accepted")
+	}
+	if _, err := NewSnapshotDecoder([]ColumnSpec{{Name: "n", Kind: ColumnInt, Min: 5, Max: 1}}); err == nil {
+		t.Error("min > max accepted")
+	}
+
+	enc, dec := newTestSnapshotPair(t)
+	if _, _, err := enc.Encode(map[uint32]EntityState{1: {{Float: 1}}}); err == nil {
+		t.Error("entity with missing fields accepted")
+	}
+	bad := testEntities(0)
+	bad[1][1].Int = 101
+	if _, _, err := enc.Encode(bad); err == nil {
+		t.Error("out of range int accepted")
+	}
+
+	seq, b, err := enc.Encode(testEntities(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTruncated(t, b, func(b []byte) error {
+		_, _, err := dec.Decode(b)
+		return err
+	})
+	if _, _, err := dec.Decode(append(b, 0)); err == nil {
+		t.Error("trailing bytes accepted")
+	}
+	if _, _, err := dec.Decode([]byte{2, 1, 0, 0}); err == nil {
+		t.Error("unknown baseline accepted")
+	}
+	// An entity seen for the first time must carry every field.
+	if _, _, err := dec.Decode([]byte{2, 0, 1, 9, 0x00, 0}); err == nil {
+		t.Error("new entity without fields accepted")
+	}
+	if _, _, err := dec.Decode([]byte{2, 0, 0xff, 0xff, 0xff, 0xff, 0x0f}); err == nil {
+		t.Error("oversized entity count accepted")
+	}
+
+	if _, _, err := dec.Decode(b); err != nil {
+		t.Fatal(err)
+	}
+	enc.Ack(seq)
+	_, b, _ = enc.Encode(testEntities(1))
+	_, got, err := dec.Decode(b)
+	if err != nil {
+		t.Fatalf("Decode after rejected input: %v", err)
+	}
+	checkEntities(t, testEntities(1), got)
+}
@@ -0,0 +1,480 @@
+package varfloat
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// DType is the element type a Tensor was stored in and is decoded back to.
+type DType uint8
+
+const (
+	// DTypeFloat64 is float64.
+	DTypeFloat64 DType = iota
+	// DTypeFloat32 is float32.
+	DTypeFloat32
+	// DTypeFloat16 is IEEE binary16 (see Float16).
+	DTypeFloat16
+	// DTypeBFloat16 is bfloat16 (see BFloat16).
+	DTypeBFloat16
+)
+
+// round rounds v to the precision of d.
+func (d DType) round(v float64) float64 {
+	switch d {
+	case DTypeFloat32:
+		return float64(float32(v))
+	case DTypeFloat16:
+		return Float16FromFloat64(v).Float64()
+	case DTypeBFloat16:
+		return BFloat16FromFloat64(v).Float64()
+	}
+	return v
+}
+
+// Tensor is a dense n-dimensional array stored in row-major order (the last
+// dimension varies fastest), such as ML weights or activations.
+//
+// Data always holds float64s; DType records the element type the tensor is
+// exchanged as, and decoded tensors are rounded to it. A tensor with an empty
+// Shape is a scalar holding one element.
+type Tensor struct {
+	DType DType
+	Shape []int
+	Data  []float64
+}
+
+// TensorFromFloat32 builds a DTypeFloat32 tensor from row-major float32 data.
+func TensorFromFloat32(data []float32, shape ...int) (Tensor, error) {
+	flat := make([]float64, len(data))
+	for i, v := range data {
+		flat[i] = float64(v)
+	}
+	t := Tensor{DType: DTypeFloat32, Shape: append([]int(nil), shape...), Data: flat}
+	if err := t.validate(); err != nil {
+		return Tensor{}, err
+	}
+	return t, nil
+}
+
+// Float32s returns the tensor's data as float32s.
+func (t Tensor) Float32s() []float32 {
+	out := make([]float32, len(t.Data))
+	for i, v := range t.Data {
+		out[i] = float32(v)
+	}
+	return out
+}
+
+// validate checks that the shape is usable and matches the data.
+func (t Tensor) validate() error {
+	if t.DType > DTypeBFloat16 {
+		return errors.New("varfloat: unknown tensor dtype")
+	}
+	n, err := tensorElems(t.Shape)
+	if err != nil {
+		return err
+	}
+	if n != len(t.Data) {
+		return fmt.Errorf("varfloat: tensor shape holds %d elements, data has %d", n, len(t.Data))
+	}
+	return nil
+}
+
+// maxTensorRank is the largest number of dimensions a tensor may have.
+const maxTensorRank = 32
+
+// tensorElems returns the number of elements of a tensor with the given shape.
+func tensorElems(shape []int) (int, error) {
+	if len(shape) > maxTensorRank {
+		return 0, errors.New("varfloat: tensor rank is too large")
+	}
+	n := 1
+	for _, d := range shape {
+		if d < 0 {
+			return 0, errors.New("varfloat: tensor dimensions must be >= 0")
+		}
+		if d > 0 && n > math.MaxInt32/d {
+			return 0, errors.New("varfloat: tensor is too large")
+		}
+		n *= d
+	}
+	return n, nil
+}
+
+// TensorQuantMode selects how tensor data is quantized.
+type TensorQuantMode uint8
+
+const (
+	// TensorPerTensor writes every element as a varfloat with Bits mantissa
+	// bits, i.e. one relative precision for the whole tensor. Inf and NaN are
+	// kept.
+	TensorPerTensor TensorQuantMode = iota
+
+	// TensorPerChannel splits the tensor into channels along Axis and
+	// quantizes each channel to signed Bits-bit integers scaled by the
+	// channel's largest magnitude (absmax), as is usual for weights. The
+	// integer grid puts each element within half its channel's scale,
+	// absmax/(2^(Bits-1)-1), and decoding then rounds to DType, which adds up
+	// to half a DType ulp at the element's magnitude (for normal values
+	// 2^-11 relative for Float16, 2^-8 for BFloat16, 2^-24 for Float32).
+	// With many bits the DType rounding dominates. Elements must be finite.
+	TensorPerChannel
+)
+
+// TensorQuant describes how EncodeTensor quantizes a tensor's data.
+type TensorQuant struct {
+	Mode TensorQuantMode
+	// Bits is the mantissa bits for TensorPerTensor ([0, 52]) or the integer
+	// width for TensorPerChannel ([2, 32]).
+	Bits int
+	// Axis is the channel dimension for TensorPerChannel, e.g. 0 for the
+	// output channels of a [out, in] weight matrix.
+	Axis int
+}
+
+// validate checks that q can be used on a tensor with the given shape.
+func (q TensorQuant) validate(shape []int) error {
+	switch q.Mode {
+	case TensorPerTensor:
+		if q.Bits < 0 || q.Bits > 52 {
+			return errors.New("varfloat: mantissa bits must be between 0 and 52")
+		}
+	case TensorPerChannel:
+		if q.Bits < 2 || q.Bits > 32 {
+			return errors.New("varfloat: per-channel bits must be between 2 and 32")
+		}
+		if q.Axis < 0 || q.Axis >= len(shape) {
+			return errors.New("varfloat: per-channel axis is out of range")
+		}
+	default:
+		return errors.New("varfloat: unknown tensor quantization mode")
+	}
+	return nil
+}
+
+// tensorHeader is everything needed to decode tensor data: the shape and
+// dtype, the quantization and, per channel, the scales.
+type tensorHeader struct {
+	dtype  DType
+	shape  []int
+	quant  TensorQuant
+	scales []float64
+
+	elems int
+	// inner is the number of elements per step along the channel axis.
+	inner int
+}
+
+// newTensorHeader validates t and q and computes the per-channel scales.
+func newTensorHeader(t Tensor, q TensorQuant) (*tensorHeader, error) {
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+	if err := q.validate(t.Shape); err != nil {
+		return nil, err
+	}
+	h := &tensorHeader{dtype: t.DType, shape: t.Shape, quant: q, elems: len(t.Data)}
+	if q.Mode == TensorPerChannel {
+		h.setInner()
+		h.scales = make([]float64, t.Shape[q.Axis])
+		qmax := float64(int64(1)<<(q.Bits-1) - 1)
+		for i, v := range t.Data {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return nil, errors.New("varfloat: per-channel quantization requires finite values")
+			}
+			c := h.channel(i)
+			h.scales[c] = math.Max(h.scales[c], math.Abs(v))
+		}
+		for c := range h.scales {
+			h.scales[c] /= qmax
+		}
+	}
+	return h, nil
+}
+
+// setInner computes h.inner from the shape and channel axis.
+func (h *tensorHeader) setInner() {
+	h.inner = 1
+	for _, d := range h.shape[h.quant.Axis+1:] {
+		h.inner *= d
+	}
+}
+
+// channel returns the channel of the element at row-major index i.
+func (h *tensorHeader) channel(i int) int {
+	return i / h.inner % h.shape[h.quant.Axis]
+}
+
+// append writes the header:
+//
+//	[1-byte dtype][uvarint rank][uvarint dim]...[1-byte mode][1-byte bits]
+//	per channel only: [uvarint axis][8-byte scale]...
+func (h *tensorHeader) append(dst []byte) []byte {
+	dst = append(dst, byte(h.dtype))
+	dst = binary.AppendUvarint(dst, uint64(len(h.shape)))
+	for _, d := range h.shape {
+		dst = binary.AppendUvarint(dst, uint64(d))
+	}
+	dst = append(dst, byte(h.quant.Mode), byte(h.quant.Bits))
+	if h.quant.Mode == TensorPerChannel {
+		dst = binary.AppendUvarint(dst, uint64(h.quant.Axis))
+		for _, s := range h.scales {
+			dst = append(dst, EncodeFloat64Fixed(s)...)
+		}
+	}
+	return dst
+}
+
+// parseTensorHeader reads a header written by tensorHeader.append, returning
+// it and the number of bytes consumed.
+func parseTensorHeader(b []byte) (*tensorHeader, int, error) {
+	if len(b) < 1 {
+		return nil, 0, errors.New("varfloat: tensor header truncated")
+	}
+	h := &tensorHeader{dtype: DType(b[0])}
+	offset := 1
+	rank, n := binary.Uvarint(b[offset:])
+	if n <= 0 || rank > maxTensorRank {
+		return nil, 0, errors.New("varfloat: invalid tensor rank")
+	}
+	offset += n
+	h.shape = make([]int, rank)
+	for i := range h.shape {
+		d, n := binary.Uvarint(b[offset:])
+		if n <= 0 || d > math.MaxInt32 {
+			return nil, 0, errors.New("varfloat: invalid tensor dimension")
+		}
+		h.shape[i] = int(d)
+		offset += n
+	}
+	elems, err := tensorElems(h.shape)
+	if err != nil {
+		return nil, 0, err
+	}
+	h.elems = elems
+	if h.dtype > DTypeBFloat16 {
+		return nil, 0, errors.New("varfloat: unknown tensor dtype")
+	}
+
+	if len(b)-offset < 2 {
+		return nil, 0, errors.New("varfloat: tensor header truncated")
+	}
+	h.quant.Mode = TensorQuantMode(b[offset])
+	h.quant.Bits = int(b[offset+1])
+	offset += 2
+	if h.quant.Mode == TensorPerChannel {
+		axis, n := binary.Uvarint(b[offset:])
+		if n <= 0 || axis >= rank {
+			return nil, 0, errors.New("varfloat: per-channel axis is out of range")
+		}
+		h.quant.Axis = int(axis)
+		offset += n
+	}
+	if err := h.quant.validate(h.shape); err != nil {
+		return nil, 0, err
+	}
+	if h.quant.Mode == TensorPerChannel {
+		h.setInner()
+		channels := h.shape[h.quant.Axis]
+		if len(b)-offset < 8*channels {
+			return nil, 0, errors.New("varfloat: tensor scales truncated")
+		}
+		h.scales = make([]float64, channels)
+		for c := range h.scales {
+			h.scales[c], _, _ = DecodeFloat64Fixed(b[offset:])
+			offset += 8
+		}
+	}
+	return h, offset, nil
+}
+
+// encodeData encodes the elements starting at row-major index start. For
+// TensorPerTensor this is an EncodeFloats payload; for TensorPerChannel it is
+//
+//	[uvarint count][packed Bits-bit zigzag integers]
+func (h *tensorHeader) encodeData(values []float64, start int) ([]byte, error) {
+	if h.quant.Mode == TensorPerTensor {
+		return EncodeFloats(values, h.quant.Bits)
+	}
+	qmax := float64(int64(1)<<(h.quant.Bits-1) - 1)
+	out := binary.AppendUvarint(nil, uint64(len(values)))
+	var w bitWriter
+	for i, v := range values {
+		q := 0.0
+		if s := h.scales[h.channel(start+i)]; s > 0 {
+			q = math.Max(-qmax, math.Min(qmax, math.Round(v/s)))
+		}
+		w.writeBits(zigZagEncode(int64(q)), h.quant.Bits)
+	}
+	return append(out, w.bytes()...), nil
+}
+
+// decodeData decodes elements written by encodeData for the elements
+// starting at row-major index start, rounding them to the tensor's dtype, and
+// returns them with the number of bytes consumed.
+func (h *tensorHeader) decodeData(b []byte, start int) ([]float64, int, error) {
+	var (
+		values []float64
+		n      int
+		err    error
+	)
+	if h.quant.Mode == TensorPerTensor {
+		values, n, err = DecodeFloats(b, h.quant.Bits)
+		if err != nil {
+			return nil, 0, err
+		}
+	} else {
+		count, used := binary.Uvarint(b)
+		if used <= 0 {
+			return nil, 0, errors.New("varfloat: invalid tensor data length")
+		}
+		if count > uint64(len(b)-used)*8 {
+			return nil, 0, errors.New("varfloat: tensor data length exceeds buffer")
+		}
+		if start+int(count) > h.elems {
+			return nil, 0, errors.New("varfloat: tensor data exceeds its shape")
+		}
+		r := bitReader{b: b[used:]}
+		values = make([]float64, count)
+		for i := range values {
+			u, err := r.readBits(h.quant.Bits)
+			if err != nil {
+				return nil, 0, err
+			}
+			values[i] = float64(zigZagDecode(u)) * h.scales[h.channel(start+i)]
+		}
+		n = used + packedLen(int(count), h.quant.Bits)
+	}
+	if start+len(values) > h.elems {
+		return nil, 0, errors.New("varfloat: tensor data exceeds its shape")
+	}
+	for i, v := range values {
+		values[i] = h.dtype.round(v)
+	}
+	return values, n, nil
+}
+
+// EncodeTensor encodes t, including its dtype and shape, with the given
+// quantization. The layout is a header (dtype, shape, quantization and any
+// per-channel scales) followed by the data in row-major order; see
+// TensorQuantMode for the data formats.
+func EncodeTensor(t Tensor, q TensorQuant) ([]byte, error) {
+	h, err := newTensorHeader(t, q)
+	if err != nil {
+		return nil, err
+	}
+	data, err := h.encodeData(t.Data, 0)
+	if err != nil {
+		return nil, err
+	}
+	return append(h.append(nil), data...), nil
+}
+
+// DecodeTensor decodes a tensor written by EncodeTensor, returning it, the
+// quantization it was written with and the number of bytes consumed.
+func DecodeTensor(b []byte) (Tensor, TensorQuant, int, error) {
+	h, n, err := parseTensorHeader(b)
+	if err != nil {
+		return Tensor{}, TensorQuant{}, 0, err
+	}
+	data, used, err := h.decodeData(b[n:], 0)
+	if err != nil {
+		return Tensor{}, TensorQuant{}, 0, err
+	}
+	if len(data) != h.elems {
+		return Tensor{}, TensorQuant{}, 0, errors.New("varfloat: tensor data does not match its shape")
+	}
+	return Tensor{DType: h.dtype, Shape: h.shape, Data: data}, h.quant, n + used, nil
+}
+
+// DefaultTensorChunkSize is the number of elements per chunk that
+// TensorStreamEncoder writes by default.
+const DefaultTensorChunkSize = 1 << 16
+
+// TensorStreamEncoder writes tensors to an io.Writer using the same chunk
+// framing as FloatStreamEncoder, so large tensors are written in pieces of
+// ChunkSize elements instead of one large buffer. Each tensor is one header
+// chunk (as in EncodeTensor) followed by as many data chunks as it needs.
+type TensorStreamEncoder struct {
+	w io.Writer
+
+	// ChunkSize is the number of elements per data chunk.
+	ChunkSize int
+}
+
+// NewTensorStreamEncoder creates a TensorStreamEncoder that writes to w.
+func NewTensorStreamEncoder(w io.Writer) *TensorStreamEncoder {
+	return &TensorStreamEncoder{w: w, ChunkSize: DefaultTensorChunkSize}
+}
+
+// WriteTensor writes t with the given quantization.
+func (e *TensorStreamEncoder) WriteTensor(t Tensor, q TensorQuant) error {
+	if e.ChunkSize < 1 {
+		return errors.New("varfloat: tensor chunk size must be >= 1")
+	}
+	h, err := newTensorHeader(t, q)
+	if err != nil {
+		return err
+	}
+	if err := writeChunk(e.w, 0, 0, h.append(nil)); err != nil {
+		return err
+	}
+	for start := 0; start < len(t.Data); start += e.ChunkSize {
+		end := min(start+e.ChunkSize, len(t.Data))
+		payload, err := h.encodeData(t.Data[start:end], start)
+		if err != nil {
+			return err
+		}
+		if err := writeChunk(e.w, 0, 0, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TensorStreamDecoder reads tensors from an io.Reader that were written by
+// TensorStreamEncoder.
+type TensorStreamDecoder struct {
+	r *bufio.Reader
+}
+
+// NewTensorStreamDecoder creates a TensorStreamDecoder that reads from r.
+func NewTensorStreamDecoder(r io.Reader) *TensorStreamDecoder {
+	return &TensorStreamDecoder{r: bufio.NewReader(r)}
+}
+
+// ReadTensor reads the next tensor from the stream, returning it and the
+// quantization it was written with. At the end of the stream it returns
+// io.EOF; a tensor cut short returns io.ErrUnexpectedEOF.
+func (d *TensorStreamDecoder) ReadTensor() (Tensor, TensorQuant, error) {
+	_, _, buf, err := readChunk(d.r, 0)
+	if err != nil {
+		return Tensor{}, TensorQuant{}, err
+	}
+	h, _, err := parseTensorHeader(buf)
+	if err != nil {
+		return Tensor{}, TensorQuant{}, err
+	}
+
+	data := make([]float64, 0, min(h.elems, DefaultTensorChunkSize))
+	for len(data) < h.elems {
+		_, _, buf, err := readChunk(d.r, 0)
+		if err != nil {
+			return Tensor{}, TensorQuant{}, noEOF(err)
+		}
+		values, _, err := h.decodeData(buf, len(data))
+		if err != nil {
+			return Tensor{}, TensorQuant{}, err
+		}
+		if len(values) == 0 {
+			return Tensor{}, TensorQuant{}, errors.New("varfloat: empty tensor data chunk")
+		}
+		data = append(data, values...)
+	}
+	return Tensor{DType: h.dtype, Shape: h.shape, Data: data}, h.quant, nil
+}
@@ -0,0 +1,264 @@
+package varfloat
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+var tensorDTypes = []DType{DTypeFloat64, DTypeFloat32, DTypeFloat16, DTypeBFloat16}
+
+// sampleTensor returns a tensor of the given dtype and shape whose elements
+// are already representable in that dtype.
+func sampleTensor(dtype DType, seed uint64, shape ...int) Tensor {
+	n, _ := tensorElems(shape)
+	r := rand.New(rand.NewPCG(seed, seed))
+	data := make([]float64, n)
+	for i := range data {
+		data[i] = dtype.round((r.Float64()*2 - 1) * math.Pow(2, float64(r.IntN(8)-4)))
+	}
+	return Tensor{DType: dtype, Shape: shape, Data: data}
+}
+
+// dtypeHalfUlp bounds half a unit in the last place of d at magnitude |v|.
+func dtypeHalfUlp(d DType, v float64) float64 {
+	mant, minSub := 52, 0x1p-1074
+	switch d {
+	case DTypeFloat32:
+		mant, minSub = 23, 0x1p-149
+	case DTypeFloat16:
+		mant, minSub = 10, 0x1p-24
+	case DTypeBFloat16:
+		mant, minSub = 7, 0x1p-133
+	}
+	return math.Max(math.Abs(v)*math.Ldexp(1, -mant-1), minSub/2)
+}
+
+func TestTensorPerTensor(t *testing.T) {
+	for _, dtype := range tensorDTypes {
+		tensor := sampleTensor(dtype, 71, 3, 4, 5)
+		tensor.Data[0], tensor.Data[1], tensor.Data[2] = math.Inf(-1), math.NaN(), math.Copysign(0, -1)
+		for _, bits := range []int{0, 6, 11, 52} {
+			q := TensorQuant{Mode: TensorPerTensor, Bits: bits}
+			b, err := EncodeTensor(tensor, q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, gotQ, n, err := DecodeTensor(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != len(b) || gotQ != q || got.DType != dtype || !slices.Equal(got.Shape, tensor.Shape) {
+				t.Fatalf("dtype %d bits %d: decoded %+v %v from %d of %d bytes", dtype, bits, gotQ, got.Shape, n, len(b))
+			}
+			for i, v := range tensor.Data {
+				g := got.Data[i]
+				if !sameFloat(g, dtype.round(g)) {
+					t.Errorf("dtype %d bits %d: %g is not rounded to the dtype", dtype, bits, g)
+				}
+				if i < 3 {
+					if !sameFloat(g, v) {
+						t.Errorf("dtype %d bits %d: %g decoded as %g", dtype, bits, v, g)
+					}
+					continue
+				}
+				if d := math.Abs(g - v); d > math.Abs(v)*MaxRelErrorForBits(bits)+dtypeHalfUlp(dtype, v) {
+					t.Errorf("dtype %d bits %d: %g decoded as %g", dtype, bits, v, g)
+				}
+			}
+		}
+	}
+}
+
+func TestTensorPerChannel(t *testing.T) {
+	for _, dtype := range tensorDTypes {
+		for _, axis := range []int{0, 1, 2} {
+			tensor := sampleTensor(dtype, 72, 3, 4, 5)
+			h := &tensorHeader{shape: tensor.Shape, quant: TensorQuant{Axis: axis}}
+			h.setInner()
+			// Zero the last channel entirely.
+			for i := range tensor.Data {
+				if h.channel(i) == tensor.Shape[axis]-1 {
+					tensor.Data[i] = 0
+				}
+			}
+			for _, bits := range []int{2, 4, 8, 16, 32} {
+				q := TensorQuant{Mode: TensorPerChannel, Bits: bits, Axis: axis}
+				b, err := EncodeTensor(tensor, q)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, gotQ, n, err := DecodeTensor(b)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if n != len(b) || gotQ != q {
+					t.Fatalf("decoded %+v from %d of %d bytes", gotQ, n, len(b))
+				}
+
+				absmax := make([]float64, tensor.Shape[axis])
+				for i, v := range tensor.Data {
+					absmax[h.channel(i)] = math.Max(absmax[h.channel(i)], math.Abs(v))
+				}
+				qmax := math.Ldexp(1, bits-1) - 1
+				for i, v := range tensor.Data {
+					// The documented bound: half the channel's scale plus
+					// half a dtype ulp.
+					bound := absmax[h.channel(i)]/qmax/2*(1+1e-12) + dtypeHalfUlp(dtype, v)
+					if d := math.Abs(got.Data[i] - v); d > bound {
+						t.Errorf("dtype %d axis %d bits %d: %g decoded as %g (error %g > %g)", dtype, axis, bits, v, got.Data[i], d, bound)
+					}
+					if absmax[h.channel(i)] == 0 && got.Data[i] != 0 {
+						t.Errorf("zero channel decoded %g", got.Data[i])
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestTensorShapes(t *testing.T) {
+	for _, shape := range [][]int{{}, {0}, {0, 3}, {7}, {1, 1, 1, 2}} {
+		tensor := sampleTensor(DTypeFloat32, 73, shape...)
+		modes := []TensorQuant{{Mode: TensorPerTensor, Bits: 23}}
+		if len(shape) > 0 {
+			modes = append(modes, TensorQuant{Mode: TensorPerChannel, Bits: 8, Axis: len(shape) - 1})
+		}
+		for _, q := range modes {
+			b, err := EncodeTensor(tensor, q)
+			if err != nil {
+				t.Fatalf("shape %v: %v", shape, err)
+			}
+			got, _, _, err := DecodeTensor(b)
+			if err != nil {
+				t.Fatalf("shape %v: %v", shape, err)
+			}
+			if !slices.Equal(got.Shape, shape) || len(got.Data) != len(tensor.Data) {
+				t.Errorf("shape %v decoded as %v with %d elements", shape, got.Shape, len(got.Data))
+			}
+		}
+	}
+}
+
+func TestTensorFromFloat32(t *testing.T) {
+	data := []float32{1.5, -2.25, 3e-8, 0}
+	tensor, err := TensorFromFloat32(data, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tensor.DType != DTypeFloat32 || !slices.Equal(tensor.Float32s(), data) {
+		t.Errorf("TensorFromFloat32 = %+v", tensor)
+	}
+	if _, err := TensorFromFloat32(data, 3); err == nil {
+		t.Error("shape not matching the data accepted")
+	}
+}
+
+func TestTensorInvalid(t *testing.T) {
+	good := sampleTensor(DTypeFloat16, 74, 2, 3)
+	nan := sampleTensor(DTypeFloat32, 74, 2, 3)
+	nan.Data[4] = math.NaN()
+	tests := []struct {
+		tensor Tensor
+		q      TensorQuant
+	}{
+		{Tensor{DType: DTypeBFloat16 + 1, Shape: []int{1}, Data: []float64{1}}, TensorQuant{}},
+		{Tensor{Shape: []int{2, 2}, Data: []float64{1}}, TensorQuant{}},
+		{Tensor{Shape: []int{-1}}, TensorQuant{}},
+		{Tensor{Shape: make([]int, maxTensorRank+1)}, TensorQuant{}},
+		{Tensor{Shape: []int{1 << 20, 1 << 20}}, TensorQuant{}},
+		{good, TensorQuant{Mode: TensorPerTensor, Bits: 53}},
+		{good, TensorQuant{Mode: TensorPerChannel, Bits: 1}},
+		{good, TensorQuant{Mode: TensorPerChannel, Bits: 33}},
+		{good, TensorQuant{Mode: TensorPerChannel, Bits: 8, Axis: 2}},
+		{good, TensorQuant{Mode: TensorPerChannel + 1, Bits: 8}},
+		{nan, TensorQuant{Mode: TensorPerChannel, Bits: 8}},
+	}
+	for _, tt := range tests {
+		if _, err := EncodeTensor(tt.tensor, tt.q); err == nil {
+			t.Errorf("EncodeTensor(%v, %+v) succeeded", tt.tensor.Shape, tt.q)
+		}
+	}
+
+	for _, q := range []TensorQuant{{Mode: TensorPerTensor, Bits: 10}, {Mode: TensorPerChannel, Bits: 6, Axis: 1}} {
+		b, err := EncodeTensor(good, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkTruncated(t, b, func(b []byte) error {
+			_, _, _, err := DecodeTensor(b)
+			return err
+		})
+	}
+
+	for _, b := range [][]byte{
+		{byte(DTypeBFloat16 + 1), 0, 0, 10},        // unknown dtype
+		{0, maxTensorRank + 1},                     // rank too large
+		{0, 1, 0xff, 0xff, 0xff, 0xff, 0x0f},       // dimension too large
+		{0, 1, 2, byte(TensorPerChannel), 8, 1},    // axis out of range
+		{0, 1, 2, byte(TensorPerChannel + 1), 8},   // unknown mode
+		{0, 1, 2, byte(TensorPerTensor), 10, 3, 0}, // more data than the shape
+	} {
+		if _, _, _, err := DecodeTensor(b); err == nil {
+			t.Errorf("% x decoded", b)
+		}
+	}
+}
+
+func TestTensorStream(t *testing.T) {
+	tensors := []struct {
+		tensor Tensor
+		q      TensorQuant
+	}{
+		{sampleTensor(DTypeFloat32, 75, 10, 7), TensorQuant{Mode: TensorPerChannel, Bits: 8, Axis: 1}},
+		{sampleTensor(DTypeFloat16, 76, 0, 4), TensorQuant{Mode: TensorPerTensor, Bits: 11}},
+		{sampleTensor(DTypeBFloat16, 77, 33), TensorQuant{Mode: TensorPerTensor, Bits: 8}},
+	}
+	var buf bytes.Buffer
+	enc := NewTensorStreamEncoder(&buf)
+	enc.ChunkSize = 16
+	for _, tt := range tensors {
+		if err := enc.WriteTensor(tt.tensor, tt.q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream := buf.Bytes()
+
+	dec := NewTensorStreamDecoder(bytes.NewReader(stream))
+	for i, tt := range tensors {
+		got, q, err := dec.ReadTensor()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Chunking must not change the data.
+		b, err := EncodeTensor(tt.tensor, tt.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _, _, err := DecodeTensor(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q != tt.q || !slices.Equal(got.Shape, want.Shape) || !slices.Equal(got.Data, want.Data) {
+			t.Errorf("tensor %d: streamed %+v %v differs from EncodeTensor", i, q, got.Shape)
+		}
+	}
+	if _, _, err := dec.ReadTensor(); err != io.EOF {
+		t.Errorf("expected io.EOF after the last tensor, got %v", err)
+	}
+
+	// Cut inside the first tensor's data.
+	_, _, err := NewTensorStreamDecoder(bytes.NewReader(stream[:40])).ReadTensor()
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("truncated stream: %v, want io.ErrUnexpectedEOF", err)
+	}
+
+	enc.ChunkSize = 0
+	if err := enc.WriteTensor(tensors[0].tensor, tensors[0].q); err == nil {
+		t.Error("zero chunk size accepted")
+	}
+}
@@ -0,0 +1,375 @@
+package varfloat
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Mat3 is a 3x3 matrix stored in row-major order.
+type Mat3 [9]float64
+
+func (Mat3) dims() int        { return 9 }
+func (Mat3) typeName() string { return "Mat3" }
+func (m Mat3) appendComponents(dst []float64) []float64 {
+	return append(dst, m[:]...)
+}
+func (Mat3) fromComponents(c []float64) Mat3 {
+	var m Mat3
+	copy(m[:], c)
+	return m
+}
+
+// Mat4 is a 4x4 matrix stored in row-major order.
+type Mat4 [16]float64
+
+func (Mat4) dims() int        { return 16 }
+func (Mat4) typeName() string { return "Mat4" }
+func (m Mat4) appendComponents(dst []float64) []float64 {
+	return append(dst, m[:]...)
+}
+func (Mat4) fromComponents(c []float64) Mat4 {
+	var m Mat4
+	copy(m[:], c)
+	return m
+}
+
+// EncodeMat3 encodes a single 3x3 matrix using the given mantissa bit
+// precision for every element.
+func EncodeMat3(m Mat3, bits int) ([]byte, error) {
+	return encodeVec(m, bits)
+}
+
+// DecodeMat3 decodes a single 3x3 matrix that was encoded with EncodeMat3
+// and the same mantissa bit precision.
+func DecodeMat3(b []byte, bits int) (Mat3, int, error) {
+	return decodeVec[Mat3](b, bits)
+}
+
+// EncodeMat3Slice encodes a slice of 3x3 matrices as a flat EncodeFloats
+// payload of 9 elements per matrix.
+func EncodeMat3Slice(ms []Mat3, bits int) ([]byte, error) {
+	return encodeVecSlice(ms, bits)
+}
+
+// DecodeMat3Slice decodes a slice of 3x3 matrices that was encoded with
+// EncodeMat3Slice and the same mantissa bit precision.
+func DecodeMat3Slice(b []byte, bits int) ([]Mat3, int, error) {
+	return decodeVecSlice[Mat3](b, bits)
+}
+
+// EncodeMat4 encodes a single 4x4 matrix using the given mantissa bit
+// precision for every element.
+func EncodeMat4(m Mat4, bits int) ([]byte, error) {
+	return encodeVec(m, bits)
+}
+
+// DecodeMat4 decodes a single 4x4 matrix that was encoded with EncodeMat4
+// and the same mantissa bit precision.
+func DecodeMat4(b []byte, bits int) (Mat4, int, error) {
+	return decodeVec[Mat4](b, bits)
+}
+
+// EncodeMat4Slice encodes a slice of 4x4 matrices as a flat EncodeFloats
+// payload of 16 elements per matrix.
+func EncodeMat4Slice(ms []Mat4, bits int) ([]byte, error) {
+	return encodeVecSlice(ms, bits)
+}
+
+// DecodeMat4Slice decodes a slice of 4x4 matrices that was encoded with
+// EncodeMat4Slice and the same mantissa bit precision.
+func DecodeMat4Slice(b []byte, bits int) ([]Mat4, int, error) {
+	return decodeVecSlice[Mat4](b, bits)
+}
+
+// Mat3StreamEncoder writes chunks of Mat3 slices to an io.Writer using the
+// same chunk format as FloatStreamEncoder.
+type Mat3StreamEncoder struct {
+	w io.Writer
+
+	// Entropy enables Huffman-coded payloads, as for FloatStreamEncoder.
+	Entropy bool
+
+	// ErrorStats, if set, records every component written.
+	ErrorStats *ErrorStats
+}
+
+// NewMat3StreamEncoder creates a Mat3StreamEncoder that writes to w.
+func NewMat3StreamEncoder(w io.Writer) *Mat3StreamEncoder {
+	return &Mat3StreamEncoder{w: w}
+}
+
+// WriteChunk encodes a slice of Mat3 values with the given mantissa bits and
+// writes it as a self-contained chunk to the underlying writer.
+func (e *Mat3StreamEncoder) WriteChunk(ms []Mat3, bits int) error {
+	return writeVecChunk(e.w, ms, bits, e.Entropy, e.ErrorStats)
+}
+
+// Mat3StreamDecoder reads chunks of Mat3 slices from an io.Reader that were
+// written by Mat3StreamEncoder.
+type Mat3StreamDecoder struct {
+	r *bufio.Reader
+}
+
+// NewMat3StreamDecoder creates a Mat3StreamDecoder that reads from r.
+func NewMat3StreamDecoder(r io.Reader) *Mat3StreamDecoder {
+	return &Mat3StreamDecoder{r: bufio.NewReader(r)}
+}
+
+// ReadChunk reads and decodes the next Mat3 slice chunk from the stream. On
+// EOF without any bytes read, it returns (nil, 0, io.EOF).
+func (d *Mat3StreamDecoder) ReadChunk() ([]Mat3, int, error) {
+	return readVecChunk[Mat3](d.r)
+}
+
+// Mat4StreamEncoder writes chunks of Mat4 slices to an io.Writer using the
+// same chunk format as FloatStreamEncoder.
+type Mat4StreamEncoder struct {
+	w io.Writer
+
+	// Entropy enables Huffman-coded payloads, as for FloatStreamEncoder.
+	Entropy bool
+
+	// ErrorStats, if set, records every component written.
+	ErrorStats *ErrorStats
+}
+
+// NewMat4StreamEncoder creates a Mat4StreamEncoder that writes to w.
+func NewMat4StreamEncoder(w io.Writer) *Mat4StreamEncoder {
+	return &Mat4StreamEncoder{w: w}
+}
+
+// WriteChunk encodes a slice of Mat4 values with the given mantissa bits and
+// writes it as a self-contained chunk to the underlying writer.
+func (e *Mat4StreamEncoder) WriteChunk(ms []Mat4, bits int) error {
+	return writeVecChunk(e.w, ms, bits, e.Entropy, e.ErrorStats)
+}
+
+// Mat4StreamDecoder reads chunks of Mat4 slices from an io.Reader that were
+// written by Mat4StreamEncoder.
+type Mat4StreamDecoder struct {
+	r *bufio.Reader
+}
+
+// NewMat4StreamDecoder creates a Mat4StreamDecoder that reads from r.
+func NewMat4StreamDecoder(r io.Reader) *Mat4StreamDecoder {
+	return &Mat4StreamDecoder{r: bufio.NewReader(r)}
+}
+
+// ReadChunk reads and decodes the next Mat4 slice chunk from the stream. On
+// EOF without any bytes read, it returns (nil, 0, io.EOF).
+func (d *Mat4StreamDecoder) ReadChunk() ([]Mat4, int, error) {
+	return readVecChunk[Mat4](d.r)
+}
+
+// TRS is a transform made of a translation, a rotation and a per-axis scale,
+// applied as scale, then rotation, then translation.
+type TRS struct {
+	Translation Vec3
+	Rotation    Quat
+	Scale       Vec3
+}
+
+// TRSCodec encodes TRS transforms with a separate precision for each part.
+//
+// A full Mat4 spends 16 varfloats on what is really 10 numbers, and its
+// rotation elements are bounded by 1 so their exponent bits are mostly wasted.
+// TRSCodec writes the translation and scale components as varfloats with
+// TranslationBits and ScaleBits mantissa bits (like EncodeVec3, without the
+// length prefix) and the rotation with smallest-three compression using
+// RotationBits (see AppendQuat).
+type TRSCodec struct {
+	TranslationBits int
+	RotationBits    int
+	ScaleBits       int
+}
+
+// NewTRSCodec creates a TRSCodec from per-part error budgets: maximum relative
+// error for translation and scale components (see BitsForMaxRelError) and
+// maximum rotation error in radians (see BitsForQuatMaxAngularError).
+func NewTRSCodec(maxTranslationRelErr, maxRotationAngle, maxScaleRelErr float64) (*TRSCodec, error) {
+	tBits, err := BitsForMaxRelError(maxTranslationRelErr)
+	if err != nil {
+		return nil, err
+	}
+	rBits, err := BitsForQuatMaxAngularError(maxRotationAngle)
+	if err != nil {
+		return nil, err
+	}
+	sBits, err := BitsForMaxRelError(maxScaleRelErr)
+	if err != nil {
+		return nil, err
+	}
+	return &TRSCodec{TranslationBits: tBits, RotationBits: rBits, ScaleBits: sBits}, nil
+}
+
+// Validate checks that the codec's bit counts are in range.
+func (c *TRSCodec) Validate() error {
+	if c.TranslationBits < 0 || c.TranslationBits > 52 || c.ScaleBits < 0 || c.ScaleBits > 52 {
+		return errors.New("varfloat: mantissa bits must be between 0 and 52")
+	}
+	return checkQuatBits(c.RotationBits)
+}
+
+// Append encodes t and appends it to dst.
+func (c *TRSCodec) Append(dst []byte, t TRS) ([]byte, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	tc := Config{MantissaBits: c.TranslationBits}
+	dst = tc.Append(dst, t.Translation.X)
+	dst = tc.Append(dst, t.Translation.Y)
+	dst = tc.Append(dst, t.Translation.Z)
+
+	dst, err := AppendQuat(dst, t.Rotation, c.RotationBits)
+	if err != nil {
+		return nil, err
+	}
+
+	sc := Config{MantissaBits: c.ScaleBits}
+	dst = sc.Append(dst, t.Scale.X)
+	dst = sc.Append(dst, t.Scale.Y)
+	dst = sc.Append(dst, t.Scale.Z)
+	return dst, nil
+}
+
+// Consume decodes a transform written by Append from the beginning of b. It
+// returns the decoded transform and the number of bytes consumed.
+func (c *TRSCodec) Consume(b []byte) (TRS, int, error) {
+	if err := c.Validate(); err != nil {
+		return TRS{}, 0, err
+	}
+	var comps [6]float64
+	offset := 0
+	tc := Config{MantissaBits: c.TranslationBits}
+	for i := 0; i < 3; i++ {
+		v, n, err := tc.Consume(b[offset:])
+		if err != nil {
+			return TRS{}, 0, err
+		}
+		comps[i] = v
+		offset += n
+	}
+
+	q, n, err := ConsumeQuat(b[offset:], c.RotationBits)
+	if err != nil {
+		return TRS{}, 0, err
+	}
+	offset += n
+
+	sc := Config{MantissaBits: c.ScaleBits}
+	for i := 3; i < 6; i++ {
+		v, n, err := sc.Consume(b[offset:])
+		if err != nil {
+			return TRS{}, 0, err
+		}
+		comps[i] = v
+		offset += n
+	}
+	return TRS{
+		Translation: Vec3{X: comps[0], Y: comps[1], Z: comps[2]},
+		Rotation:    q,
+		Scale:       Vec3{X: comps[3], Y: comps[4], Z: comps[5]},
+	}, offset, nil
+}
+
+// EncodeSlice encodes a slice of transforms with a uvarint length prefix.
+func (c *TRSCodec) EncodeSlice(ts []TRS) ([]byte, error) {
+	var buf [10]byte
+	n := binary.PutUvarint(buf[:], uint64(len(ts)))
+	out := append([]byte(nil), buf[:n]...)
+	for _, t := range ts {
+		var err error
+		out, err = c.Append(out, t)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// DecodeSlice decodes a slice of transforms written by EncodeSlice.
+func (c *TRSCodec) DecodeSlice(b []byte) ([]TRS, int, error) {
+	count, n := binary.Uvarint(b)
+	if n <= 0 {
+		return nil, 0, errors.New("varfloat: invalid slice length")
+	}
+	offset := n
+
+	var out []TRS
+	for i := uint64(0); i < count; i++ {
+		t, used, err := c.Consume(b[offset:])
+		if err != nil {
+			return nil, 0, err
+		}
+		out = append(out, t)
+		offset += used
+	}
+	return out, offset, nil
+}
+
+// TRSStreamEncoder writes chunks of TRS slices to an io.Writer using the same
+// chunk framing as FloatStreamEncoder. Each chunk records the codec's bit
+// counts, so the decoder needs no configuration and the precision can change
+// between chunks.
+type TRSStreamEncoder struct {
+	w io.Writer
+}
+
+// NewTRSStreamEncoder creates a TRSStreamEncoder that writes to w.
+func NewTRSStreamEncoder(w io.Writer) *TRSStreamEncoder {
+	return &TRSStreamEncoder{w: w}
+}
+
+// WriteChunk encodes ts with codec and writes it as a self-contained chunk to
+// the underlying writer. The chunk payload is the three bit counts as single
+// bytes (translation, rotation, scale) followed by codec.EncodeSlice(ts).
+func (e *TRSStreamEncoder) WriteChunk(ts []TRS, codec *TRSCodec) error {
+	slice, err := codec.EncodeSlice(ts)
+	if err != nil {
+		return err
+	}
+	payload := make([]byte, 0, 3+len(slice))
+	payload = append(payload, byte(codec.TranslationBits), byte(codec.RotationBits), byte(codec.ScaleBits))
+	payload = append(payload, slice...)
+	return writeChunk(e.w, 0, 0, payload)
+}
+
+// TRSStreamDecoder reads chunks of TRS slices from an io.Reader that were
+// written by TRSStreamEncoder.
+type TRSStreamDecoder struct {
+	r *bufio.Reader
+}
+
+// NewTRSStreamDecoder creates a TRSStreamDecoder that reads from r.
+func NewTRSStreamDecoder(r io.Reader) *TRSStreamDecoder {
+	return &TRSStreamDecoder{r: bufio.NewReader(r)}
+}
+
+// ReadChunk reads and decodes the next TRS slice chunk from the stream. It
+// returns the decoded transforms, the codec they were encoded with, and an
+// error. On EOF without any bytes read, it returns (nil, nil, io.EOF).
+func (d *TRSStreamDecoder) ReadChunk() ([]TRS, *TRSCodec, error) {
+	_, _, buf, err := readChunk(d.r, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(buf) < 3 {
+		return nil, nil, errors.New("varfloat: TRS chunk too short")
+	}
+
+	codec := &TRSCodec{
+		TranslationBits: int(buf[0]),
+		RotationBits:    int(buf[1]),
+		ScaleBits:       int(buf[2]),
+	}
+	if err := codec.Validate(); err != nil {
+		return nil, nil, err
+	}
+	ts, _, err := codec.DecodeSlice(buf[3:])
+	if err != nil {
+		return nil, nil, err
+	}
+	return ts, codec, nil
+}
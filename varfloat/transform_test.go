@@ -0,0 +1,132 @@
+package varfloat
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestMatRoundTrip(t *testing.T) {
+	m3 := Mat3{1, 0, 0, 0, 0.8, -0.6, 0, 0.6, 0.8}
+	m4 := Mat4{2, 0, 0, 0, 0, 2, 0, 0, 0, 0, 2, 0, 1e4, -3, 0.25, 1}
+	for _, bits := range []int{4, 12, 52} {
+		b, err := EncodeMat3(m3, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got3, n, err := DecodeMat3(b, bits)
+		if err != nil || n != len(b) {
+			t.Fatalf("Mat3: %d of %d bytes, %v", n, len(b), err)
+		}
+		checkVecRelError(t, m3[:], got3[:], bits)
+
+		b, err = EncodeMat4Slice([]Mat4{m4, {}}, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got4, n, err := DecodeMat4Slice(b, bits)
+		if err != nil || n != len(b) || len(got4) != 2 {
+			t.Fatalf("Mat4: %d matrices in %d of %d bytes, %v", len(got4), n, len(b), err)
+		}
+		checkVecRelError(t, m4[:], got4[0][:], bits)
+		if got4[1] != (Mat4{}) {
+			t.Errorf("zero matrix decoded as %v", got4[1])
+		}
+	}
+
+	// Nine components are one Mat3 but not a Mat4.
+	b, _ := EncodeMat3(m3, 10)
+	if _, _, err := DecodeMat4(b, 10); err == nil {
+		t.Error("Mat3 payload decoded as Mat4")
+	}
+}
+
+func TestMatStream(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewMat3StreamEncoder(&buf)
+	enc.Entropy = true
+	ms := []Mat3{{1, 2, 3, 4, 5, 6, 7, 8, 9}, {-1, 0, 0, 0, -1, 0, 0, 0, 1}}
+	if err := enc.WriteChunk(ms, 10); err != nil {
+		t.Fatal(err)
+	}
+	got, bits, err := NewMat3StreamDecoder(&buf).ReadChunk()
+	if err != nil || bits != 10 || len(got) != 2 {
+		t.Fatalf("%d matrices at %d bits, %v", len(got), bits, err)
+	}
+	checkVecRelError(t, vecFlatten(ms), vecFlatten(got), 10)
+}
+
+func TestTRSCodecErrorBudget(t *testing.T) {
+	c, err := NewTRSCodec(1e-4, 1e-3, 1e-2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ts []TRS
+	for i, q := range sampleQuats(200, 17) {
+		f := float64(i)
+		ts = append(ts, TRS{
+			Translation: Vec3{X: f * 10.5, Y: -f, Z: 1e5 / (f + 1)},
+			Rotation:    q,
+			Scale:       Vec3{X: 1, Y: 1 + f/100, Z: 0.5},
+		})
+	}
+	b, err := c.EncodeSlice(ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, n, err := c.DecodeSlice(b)
+	if err != nil || n != len(b) || len(got) != len(ts) {
+		t.Fatalf("%d transforms in %d of %d bytes, %v", len(got), n, len(b), err)
+	}
+	for i, tr := range ts {
+		g := got[i]
+		checkVecRelError(t, vecFlatten([]Vec3{tr.Translation}), vecFlatten([]Vec3{g.Translation}), c.TranslationBits)
+		checkVecRelError(t, vecFlatten([]Vec3{tr.Scale}), vecFlatten([]Vec3{g.Scale}), c.ScaleBits)
+		if a := QuatAngle(tr.Rotation, g.Rotation); a > 1e-3 {
+			t.Errorf("transform %d: rotation off by %g rad", i, a)
+		}
+	}
+
+	checkTruncated(t, b[:60], func(b []byte) error {
+		_, _, err := c.DecodeSlice(b)
+		return err
+	})
+	if _, err := NewTRSCodec(0, 1e-3, 1e-2); err == nil {
+		t.Error("zero translation error accepted")
+	}
+	if _, err := (&TRSCodec{TranslationBits: 10, RotationBits: 0, ScaleBits: 10}).Append(nil, TRS{}); err == nil {
+		t.Error("0 rotation bits accepted")
+	}
+}
+
+func TestTRSStream(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewTRSStreamEncoder(&buf)
+	coarse := &TRSCodec{TranslationBits: 6, RotationBits: 8, ScaleBits: 4}
+	fine := &TRSCodec{TranslationBits: 30, RotationBits: 20, ScaleBits: 20}
+	tr := TRS{Translation: Vec3{X: 1, Y: 2, Z: 3}, Rotation: Quat{W: 1}, Scale: Vec3{X: 1, Y: 1, Z: 1}}
+	for _, c := range []*TRSCodec{coarse, fine} {
+		if err := enc.WriteChunk([]TRS{tr}, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dec := NewTRSStreamDecoder(&buf)
+	for _, want := range []*TRSCodec{coarse, fine} {
+		got, c, err := dec.ReadChunk()
+		if err != nil || *c != *want || len(got) != 1 {
+			t.Fatalf("codec %+v, %d transforms, %v", c, len(got), err)
+		}
+		if math.Abs(got[0].Translation.Z-3) > 3*MaxRelErrorForBits(want.TranslationBits) {
+			t.Errorf("translation decoded as %v", got[0].Translation)
+		}
+	}
+
+	// A chunk whose header names an invalid rotation precision.
+	var bad bytes.Buffer
+	if err := writeChunk(&bad, 0, 0, []byte{10, 0, 10, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := NewTRSStreamDecoder(&bad).ReadChunk(); err == nil {
+		t.Error("invalid codec in chunk accepted")
+	}
+}
@@ -0,0 +1,213 @@
+package varfloat
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// AppendUnitVec3 encodes a direction using the octahedral mapping and appends
+// it to dst.
+//
+// Unit normals and directions carry no magnitude information, so spending
+// three varfloat headers on them is wasteful. The octahedral mapping projects
+// the unit sphere onto the octahedron |x|+|y|+|z| = 1 and unfolds it onto the
+// square [-1,1]^2, leaving two bounded values that are each quantized to bits
+// bits (2*bits bits per vector, padded to a whole byte).
+//
+// Only the direction of v is kept; the zero vector encodes as +Z and
+// components must be finite.
+// Use BitsForMaxAngularError to choose bits from an angular error budget.
+// bits must be in [1, 32].
+func AppendUnitVec3(dst []byte, v Vec3, bits int) ([]byte, error) {
+	if err := checkUnitVec3Bits(bits); err != nil {
+		return nil, err
+	}
+	u, w, err := octEncode(v)
+	if err != nil {
+		return nil, err
+	}
+	var bw bitWriter
+	bw.writeBits(quantizeUnit(u, bits), bits)
+	bw.writeBits(quantizeUnit(w, bits), bits)
+	return append(dst, bw.bytes()...), nil
+}
+
+// ConsumeUnitVec3 decodes a direction written by AppendUnitVec3 from the
+// beginning of b using the same bits. The result has unit length.
+func ConsumeUnitVec3(b []byte, bits int) (Vec3, int, error) {
+	if err := checkUnitVec3Bits(bits); err != nil {
+		return Vec3{}, 0, err
+	}
+	r := bitReader{b: b}
+	v, err := readUnitVec3(&r, bits)
+	if err != nil {
+		return Vec3{}, 0, err
+	}
+	return v, packedLen(2, bits), nil
+}
+
+// EncodeUnitVec3Slice encodes a slice of directions with the octahedral
+// mapping. The slice length is written as a uvarint, followed by all vectors
+// bit-packed back to back (2*bits bits each).
+func EncodeUnitVec3Slice(vs []Vec3, bits int) ([]byte, error) {
+	if err := checkUnitVec3Bits(bits); err != nil {
+		return nil, err
+	}
+	var buf [10]byte
+	n := binary.PutUvarint(buf[:], uint64(len(vs)))
+	out := append([]byte(nil), buf[:n]...)
+
+	var bw bitWriter
+	for _, v := range vs {
+		u, w, err := octEncode(v)
+		if err != nil {
+			return nil, err
+		}
+		bw.writeBits(quantizeUnit(u, bits), bits)
+		bw.writeBits(quantizeUnit(w, bits), bits)
+	}
+	return append(out, bw.bytes()...), nil
+}
+
+// DecodeUnitVec3Slice decodes a slice of directions encoded by
+// EncodeUnitVec3Slice using the same bits.
+func DecodeUnitVec3Slice(b []byte, bits int) ([]Vec3, int, error) {
+	if err := checkUnitVec3Bits(bits); err != nil {
+		return nil, 0, err
+	}
+	count, n := binary.Uvarint(b)
+	if n <= 0 {
+		return nil, 0, errors.New("varfloat: invalid slice length")
+	}
+	if count > uint64(len(b)-n)*8 {
+		return nil, 0, errors.New("varfloat: slice length exceeds buffer")
+	}
+
+	r := bitReader{b: b[n:]}
+	out := make([]Vec3, 0, count)
+	for i := uint64(0); i < count; i++ {
+		v, err := readUnitVec3(&r, bits)
+		if err != nil {
+			return nil, 0, err
+		}
+		out = append(out, v)
+	}
+	return out, n + packedLen(int(count), 2*bits), nil
+}
+
+// MaxAngularErrorForBits returns an upper bound, in radians, on the angle
+// between a unit vector and its octahedral encoding with the given bits.
+//
+// Each coordinate on the unfolded square is off by at most half a step,
+// 1/(2^bits-1). The worst case is a diagonal error at the face centers of the
+// octahedron, where a unit move in (u, w) is a sqrt(6) move on the face and
+// the face is 1/sqrt(3) from the origin, giving:
+//
+//	maxAngle ≈ 3*sqrt(2) / (2^bits-1)
+//
+// plus 2^-48 radians for float64 rounding in the decoder, which only matters
+// at the highest bit counts. bits outside [1,32] are clamped into that range.
+func MaxAngularErrorForBits(bits int) float64 {
+	if bits < 1 {
+		bits = 1
+	} else if bits > 32 {
+		bits = 32
+	}
+	return 3*math.Sqrt2/(math.Ldexp(1, bits)-1) + 0x1p-48
+}
+
+// BitsForMaxAngularError returns the smallest bit count whose
+// MaxAngularErrorForBits is within maxAngle (in radians). maxAngle must be in
+// (0, π].
+func BitsForMaxAngularError(maxAngle float64) (int, error) {
+	if maxAngle <= 0 || maxAngle > math.Pi {
+		return 0, errors.New("varfloat: maxAngle must be in (0, π]")
+	}
+	for bits := 1; bits < 32; bits++ {
+		if MaxAngularErrorForBits(bits) <= maxAngle {
+			return bits, nil
+		}
+	}
+	return 32, nil
+}
+
+// checkUnitVec3Bits validates the per-coordinate bit count for the octahedral
+// helpers.
+func checkUnitVec3Bits(bits int) error {
+	if bits < 1 || bits > 32 {
+		return errors.New("varfloat: unit vector bits must be between 1 and 32")
+	}
+	return nil
+}
+
+// readUnitVec3 reads one octahedral-encoded vector from r.
+func readUnitVec3(r *bitReader, bits int) (Vec3, error) {
+	qu, err := r.readBits(bits)
+	if err != nil {
+		return Vec3{}, err
+	}
+	qw, err := r.readBits(bits)
+	if err != nil {
+		return Vec3{}, err
+	}
+	return octDecode(dequantizeUnit(qu, bits), dequantizeUnit(qw, bits)), nil
+}
+
+// octEncode maps a direction onto the unfolded octahedron square [-1,1]^2.
+func octEncode(v Vec3) (float64, float64, error) {
+	for _, c := range [3]float64{v.X, v.Y, v.Z} {
+		if math.IsNaN(c) || math.IsInf(c, 0) {
+			return 0, 0, errors.New("varfloat: unit vector encoding requires finite components")
+		}
+	}
+	// Scale by the largest component rather than normalizing, so very large
+	// or very small vectors keep their direction instead of overflowing or
+	// underflowing to zero length.
+	m := max(math.Abs(v.X), math.Abs(v.Y), math.Abs(v.Z))
+	if m == 0 {
+		return 0, 0, nil
+	}
+	v = Vec3{X: v.X / m, Y: v.Y / m, Z: v.Z / m}
+	l1 := math.Abs(v.X) + math.Abs(v.Y) + math.Abs(v.Z)
+	u, w := v.X/l1, v.Y/l1
+	if v.Z < 0 {
+		u, w = (1-math.Abs(w))*signNotZero(u), (1-math.Abs(u))*signNotZero(w)
+	}
+	return u, w, nil
+}
+
+// octDecode reverses octEncode, returning a unit vector.
+func octDecode(u, w float64) Vec3 {
+	v := Vec3{X: u, Y: w, Z: 1 - math.Abs(u) - math.Abs(w)}
+	if v.Z < 0 {
+		v.X, v.Y = (1-math.Abs(w))*signNotZero(u), (1-math.Abs(u))*signNotZero(w)
+	}
+	return Vec3Normalize(v)
+}
+
+// signNotZero returns -1 for negative x and 1 otherwise.
+func signNotZero(x float64) float64 {
+	if x < 0 {
+		return -1
+	}
+	return 1
+}
+
+// quantizeUnit maps x in [-1,1] onto an integer in [0, 2^bits-1].
+func quantizeUnit(x float64, bits int) uint64 {
+	levels := math.Ldexp(1, bits) - 1
+	q := math.Round((x + 1) / 2 * levels)
+	if q < 0 {
+		q = 0
+	} else if q > levels {
+		q = levels
+	}
+	return uint64(q)
+}
+
+// dequantizeUnit reverses quantizeUnit.
+func dequantizeUnit(q uint64, bits int) float64 {
+	levels := math.Ldexp(1, bits) - 1
+	return float64(q)/levels*2 - 1
+}
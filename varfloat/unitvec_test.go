@@ -0,0 +1,123 @@
+package varfloat
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// angleBetween returns the angle in radians between a and b.
+func angleBetween(a, b Vec3) float64 {
+	cross := Vec3{X: a.Y*b.Z - a.Z*b.Y, Y: a.Z*b.X - a.X*b.Z, Z: a.X*b.Y - a.Y*b.X}
+	return math.Atan2(Vec3Length(cross), a.X*b.X+a.Y*b.Y+a.Z*b.Z)
+}
+
+// sampleDirections returns the axes, the octahedron face centers and n random
+// directions.
+func sampleDirections(n int, seed uint64) []Vec3 {
+	vs := []Vec3{{X: 1}, {X: -1}, {Y: 1}, {Y: -1}, {Z: 1}, {Z: -1}}
+	for _, x := range []float64{-1, 1} {
+		for _, y := range []float64{-1, 1} {
+			for _, z := range []float64{-1, 1} {
+				vs = append(vs, Vec3{X: x, Y: y, Z: z})
+			}
+		}
+	}
+	r := rand.New(rand.NewPCG(seed, seed))
+	for range n {
+		vs = append(vs, Vec3{X: r.NormFloat64(), Y: r.NormFloat64(), Z: r.NormFloat64()})
+	}
+	return vs
+}
+
+func TestUnitVec3AngularError(t *testing.T) {
+	vs := sampleDirections(2000, 7)
+	for _, bits := range []int{1, 4, 8, 12, 16, 24, 32} {
+		bound := MaxAngularErrorForBits(bits)
+		b, err := EncodeUnitVec3Slice(vs, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, n, err := DecodeUnitVec3Slice(b, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(b) || len(got) != len(vs) {
+			t.Fatalf("bits=%d: %d vectors in %d of %d bytes", bits, len(got), n, len(b))
+		}
+		for i, v := range vs {
+			if a := angleBetween(Vec3Normalize(v), got[i]); a > bound {
+				t.Errorf("bits=%d: %v decoded as %v, %g rad > %g", bits, v, got[i], a, bound)
+			}
+			if l := Vec3Length(got[i]); math.Abs(l-1) > 1e-12 {
+				t.Errorf("bits=%d: decoded length %g", bits, l)
+			}
+		}
+	}
+}
+
+func TestUnitVec3Single(t *testing.T) {
+	tests := []struct {
+		v, want Vec3
+	}{
+		{Vec3{}, Vec3{Z: 1}},
+		{Vec3{X: 1e300, Y: 1e300}, Vec3{X: math.Sqrt2 / 2, Y: math.Sqrt2 / 2}},
+		{Vec3{X: 5e-324}, Vec3{X: 1}},
+		{Vec3{Z: -3}, Vec3{Z: -1}},
+	}
+	for _, tt := range tests {
+		b, err := AppendUnitVec3(nil, tt.v, 16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, n, err := ConsumeUnitVec3(b, 16)
+		if err != nil || n != len(b) {
+			t.Fatalf("%v: %d of %d bytes, %v", tt.v, n, len(b), err)
+		}
+		if a := angleBetween(got, tt.want); a > MaxAngularErrorForBits(16) {
+			t.Errorf("%v decoded as %v, want %v", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestUnitVec3Invalid(t *testing.T) {
+	for _, bits := range []int{0, 33} {
+		if _, err := AppendUnitVec3(nil, Vec3{X: 1}, bits); err == nil {
+			t.Errorf("%d bits accepted", bits)
+		}
+	}
+	if _, err := AppendUnitVec3(nil, Vec3{X: math.NaN()}, 8); err == nil {
+		t.Error("NaN accepted")
+	}
+	if _, err := EncodeUnitVec3Slice([]Vec3{{X: math.Inf(1)}}, 8); err == nil {
+		t.Error("Inf accepted")
+	}
+
+	b, err := EncodeUnitVec3Slice(sampleDirections(5, 8), 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTruncated(t, b, func(b []byte) error {
+		_, _, err := DecodeUnitVec3Slice(b, 12)
+		return err
+	})
+	empty, _ := EncodeUnitVec3Slice(nil, 12)
+	if got, n, err := DecodeUnitVec3Slice(empty, 12); err != nil || len(got) != 0 || n != len(empty) {
+		t.Errorf("empty slice: %v, %d, %v", got, n, err)
+	}
+}
+
+func TestBitsForMaxAngularError(t *testing.T) {
+	for _, maxAngle := range []float64{1, 0.01, 1e-4, 1e-7} {
+		bits, err := BitsForMaxAngularError(maxAngle)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if MaxAngularErrorForBits(bits) > maxAngle || (bits > 1 && MaxAngularErrorForBits(bits-1) <= maxAngle) {
+			t.Errorf("BitsForMaxAngularError(%g) = %d", maxAngle, bits)
+		}
+	}
+	if _, err := BitsForMaxAngularError(0); err == nil {
+		t.Error("0 accepted")
+	}
+}
@@ -10,8 +10,11 @@ import (
 
 // Config controls how varfloats are encoded and decoded.
 // MantissaBits is the number of bits used to quantize the mantissa.
+//
+// If ErrorStats is set, every value passed to Append is recorded in it.
 type Config struct {
 	MantissaBits int
+	ErrorStats   *ErrorStats
 }
 
 // DefaultConfig is used by the package-level helpers (Append, Consume, etc).
@@ -116,57 +119,226 @@ func MaxIntQuantizationError(step int64) int64 {
 
 // FloatEncoder is a convenience wrapper that holds a chosen mantissa precision
 // and exposes helpers for encoding single floats or slices.
+//
+// MaxRelErr is the error budget the encoder was built for; if it is zero,
+// MaxRelErrorForBits(Bits) is used instead. Verify controls whether the
+// budget is checked on every encode (see VerifyMode). The output carries no
+// record of its bits, so VerifyEscalate is not supported here; use a
+// FloatStreamEncoder, whose chunks record the bits they were written with.
+//
+// Encoding does not modify the encoder, so one FloatEncoder may be shared by
+// several goroutines.
+//
+// If ErrorStats is set, every encoded value is recorded in it.
 type FloatEncoder struct {
-	Bits int
+	Bits       int
+	MaxRelErr  float64
+	Verify     VerifyMode
+	ErrorStats *ErrorStats
 }
 
 // NewFloatEncoder constructs a FloatEncoder from a desired maximum relative
-// error. It simply calls BitsForMaxRelError and stores the chosen mantissa bit
-// count.
+// error. It calls BitsForMaxRelError and stores the chosen mantissa bit count
+// along with maxRelErr.
 func NewFloatEncoder(maxRelErr float64) (*FloatEncoder, error) {
 	bits, err := BitsForMaxRelError(maxRelErr)
 	if err != nil {
 		return nil, err
 	}
-	return &FloatEncoder{Bits: bits}, nil
+	return &FloatEncoder{Bits: bits, MaxRelErr: maxRelErr}, nil
 }
 
-// Encode encodes a single float64 using the encoder's mantissa bits.
+// Encode encodes a single float64 using the encoder's mantissa bits. Decode
+// it with DecodeFloat and e.Bits.
 func (e *FloatEncoder) Encode(v float64) ([]byte, error) {
-	return EncodeFloat(v, e.Bits)
+	values := []float64{v}
+	return e.encode(values, func(bits int) ([]byte, error) {
+		return EncodeFloat(v, bits)
+	}, func(b []byte, bits int) ([]float64, error) {
+		d, _, err := DecodeFloat(b, bits)
+		return []float64{d}, err
+	})
 }
 
 // EncodeSlice encodes a slice of float64 values using the encoder's mantissa
-// bits.
+// bits. Decode it with DecodeFloats and e.Bits.
 func (e *FloatEncoder) EncodeSlice(values []float64) ([]byte, error) {
+	return e.encode(values, func(bits int) ([]byte, error) {
+		return EncodeFloats(values, bits)
+	}, func(b []byte, bits int) ([]float64, error) {
+		d, _, err := DecodeFloats(b, bits)
+		return d, err
+	})
+}
+
+// encode runs encode under the encoder's verify mode and records the values
+// written.
+func (e *FloatEncoder) encode(values []float64, encode func(bits int) ([]byte, error), decode func(b []byte, bits int) ([]float64, error)) ([]byte, error) {
+	if e.Verify == VerifyEscalate {
+		return nil, errVerifyEscalate
+	}
+	budget := verifyBudget(e.MaxRelErr, e.Bits)
+	out, _, err := encodeVerified(e.Verify, e.Bits, encode, func(b []byte, bits int) (bool, error) {
+		decoded, err := decode(b, bits)
+		if err != nil {
+			return false, err
+		}
+		return floatsWithinRelError(values, decoded, budget), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	e.ErrorStats.observeFloats(values, e.Bits)
+	return out, nil
+}
+
+// AbsErrorEncoder is a convenience wrapper for floats with an absolute error
+// budget over a known range, e.g. sensor readings in [0, 500] that must be
+// within 0.1 units. Values outside [Min, Max] are rejected, since the bound
+// only holds inside the range.
+type AbsErrorEncoder struct {
+	Min, Max  float64
+	MaxAbsErr float64
+	Bits      int
+}
+
+// NewAbsErrorEncoder constructs an AbsErrorEncoder whose decoded values are
+// within maxAbsErr of the originals for every value in [min, max]. It uses
+// BitsForFloatMaxAbsError under the hood.
+func NewAbsErrorEncoder(min, max, maxAbsErr float64) (*AbsErrorEncoder, error) {
+	bits, err := BitsForFloatMaxAbsError(min, max, maxAbsErr)
+	if err != nil {
+		return nil, err
+	}
+	return &AbsErrorEncoder{Min: min, Max: max, MaxAbsErr: maxAbsErr, Bits: bits}, nil
+}
+
+// Encode encodes a single float64 in [Min, Max] using the encoder's mantissa
+// bits. Decode it with DecodeFloat and the same bits.
+func (e *AbsErrorEncoder) Encode(v float64) ([]byte, error) {
+	if err := e.check(v); err != nil {
+		return nil, err
+	}
+	return EncodeFloat(v, e.Bits)
+}
+
+// EncodeSlice encodes a slice of float64 values in [Min, Max] using the
+// encoder's mantissa bits. Decode it with DecodeFloats and the same bits.
+func (e *AbsErrorEncoder) EncodeSlice(values []float64) ([]byte, error) {
+	for _, v := range values {
+		if err := e.check(v); err != nil {
+			return nil, err
+		}
+	}
 	return EncodeFloats(values, e.Bits)
 }
 
+// check rejects values outside the encoder's range.
+func (e *AbsErrorEncoder) check(v float64) error {
+	if !(v >= e.Min && v <= e.Max) {
+		return errors.New("varfloat: value outside the encoder's range")
+	}
+	return nil
+}
+
 // Vec3Encoder is a convenience wrapper that holds a chosen mantissa precision
 // and exposes helpers for encoding Vec3 values and slices.
+//
+// If SharedExponent is set, vectors are written with AppendVec3Shared /
+// EncodeVec3SliceShared, which store one exponent per vector instead of one
+// per component.
+//
+// MaxRelErr and Verify work as for FloatEncoder, with the error of a vector
+// measured as |decoded-original| / |original|. As there, VerifyEscalate is
+// only available on the stream encoder (Vec3StreamEncoder), and encoding does
+// not modify the encoder.
+//
+// If ErrorStats is set, every encoded component is recorded in it.
 type Vec3Encoder struct {
-	Bits int
+	Bits           int
+	SharedExponent bool
+	MaxRelErr      float64
+	Verify         VerifyMode
+	ErrorStats     *ErrorStats
 }
 
 // NewVec3Encoder constructs a Vec3Encoder from a desired maximum relative
-// error on vector magnitudes. It uses BitsForMaxRelError under the hood.
+// error on vector magnitudes. It uses BitsForMaxRelError under the hood and
+// stores maxRelErr for verification.
 func NewVec3Encoder(maxRelErr float64) (*Vec3Encoder, error) {
 	bits, err := BitsForMaxRelError(maxRelErr)
 	if err != nil {
 		return nil, err
 	}
-	return &Vec3Encoder{Bits: bits}, nil
+	return &Vec3Encoder{Bits: bits, MaxRelErr: maxRelErr}, nil
 }
 
 // Encode encodes a single Vec3 using the encoder's mantissa bits.
 func (e *Vec3Encoder) Encode(v Vec3) ([]byte, error) {
-	return EncodeVec3(v, e.Bits)
+	return e.encode([]Vec3{v}, func(bits int) ([]byte, error) {
+		if e.SharedExponent {
+			return AppendVec3Shared(nil, v, bits)
+		}
+		return EncodeVec3(v, bits)
+	}, func(b []byte, bits int) ([]Vec3, error) {
+		var (
+			d   Vec3
+			err error
+		)
+		if e.SharedExponent {
+			d, _, err = ConsumeVec3Shared(b, bits)
+		} else {
+			d, _, err = DecodeVec3(b, bits)
+		}
+		return []Vec3{d}, err
+	})
 }
 
 // EncodeSlice encodes a slice of Vec3 values using the encoder's mantissa
 // bits.
 func (e *Vec3Encoder) EncodeSlice(vs []Vec3) ([]byte, error) {
-	return EncodeVec3Slice(vs, e.Bits)
+	return e.encode(vs, func(bits int) ([]byte, error) {
+		if e.SharedExponent {
+			return EncodeVec3SliceShared(vs, bits)
+		}
+		return EncodeVec3Slice(vs, bits)
+	}, func(b []byte, bits int) ([]Vec3, error) {
+		var (
+			d   []Vec3
+			err error
+		)
+		if e.SharedExponent {
+			d, _, err = DecodeVec3SliceShared(b, bits)
+		} else {
+			d, _, err = DecodeVec3Slice(b, bits)
+		}
+		return d, err
+	})
+}
+
+// encode runs encode under the encoder's verify mode and records the
+// components written.
+func (e *Vec3Encoder) encode(vs []Vec3, encode func(bits int) ([]byte, error), decode func(b []byte, bits int) ([]Vec3, error)) ([]byte, error) {
+	if e.Verify == VerifyEscalate {
+		return nil, errVerifyEscalate
+	}
+	budget := verifyBudget(e.MaxRelErr, e.Bits)
+	out, _, err := encodeVerified(e.Verify, e.Bits, encode, func(b []byte, bits int) (bool, error) {
+		decoded, err := decode(b, bits)
+		if err != nil {
+			return false, err
+		}
+		return vec3sWithinRelError(vs, decoded, budget), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if e.SharedExponent {
+		e.ErrorStats.observeVec3Shared(vs, e.Bits)
+	} else if e.ErrorStats != nil {
+		e.ErrorStats.observeFloats(vecFlatten(vs), e.Bits)
+	}
+	return out, nil
 }
 
 // FloatStreamEncoder writes chunks of float64 slices to an io.Writer. Each chunk
@@ -176,8 +348,36 @@ func (e *Vec3Encoder) EncodeSlice(vs []Vec3) ([]byte, error) {
 //
 // where EncodeFloats payload is the usual length-prefixed varfloat encoding for
 // the provided slice.
+//
+// If Entropy is set, chunks are written with an EncodeFloatsEntropy payload
+// instead. The header byte then has its high bit set and is followed by a
+// uvarint of chunk flags; FloatStreamDecoder handles both forms.
+//
+// If ChunkStats is set, each payload is prefixed with the count, min, max and
+// sum of the chunk's decoded values (33 bytes or so), which lets
+// FloatStreamDecoder.Aggregate answer range queries without decoding whole
+// chunks.
+//
+// MaxRelErr and Verify work as for FloatEncoder, with MaxRelErrorForBits of
+// the bits passed to WriteChunk as the default budget. With VerifyEscalate a
+// chunk that misses the budget is written with more bits; the chunk header
+// records them, so ReadChunk returns the bits actually used.
 type FloatStreamEncoder struct {
 	w io.Writer
+
+	// Entropy enables the Huffman-coded payload from EncodeFloatsEntropy.
+	Entropy bool
+
+	// ChunkStats writes per-chunk statistics ahead of each payload.
+	ChunkStats bool
+
+	// ErrorStats, if set, records every value written.
+	ErrorStats *ErrorStats
+
+	// MaxRelErr is the relative error budget checked when Verify is set.
+	MaxRelErr float64
+	// Verify selects how the budget is enforced (see VerifyMode).
+	Verify VerifyMode
 }
 
 // NewFloatStreamEncoder creates a FloatStreamEncoder that writes to w.
@@ -188,35 +388,58 @@ func NewFloatStreamEncoder(w io.Writer) *FloatStreamEncoder {
 // WriteChunk encodes a slice of float64 values with the given mantissa bits and
 // writes it as a self-contained chunk to the underlying writer.
 func (e *FloatStreamEncoder) WriteChunk(values []float64, bits int) error {
-	if bits < 0 || bits > 52 {
-		return errors.New("varfloat: mantissa bits must be between 0 and 52")
-	}
-
-	payload, err := EncodeFloats(values, bits)
+	budget := verifyBudget(e.MaxRelErr, bits)
+	var flags uint64
+	payload, bits, err := encodeVerified(e.Verify, bits, func(bits int) ([]byte, error) {
+		payload, f, err := encodeFloatsChunk(values, bits, e.Entropy)
+		flags = f
+		return payload, err
+	}, func(b []byte, bits int) (bool, error) {
+		decoded, err := decodeFloatsChunk(b, bits, flags)
+		if err != nil {
+			return false, err
+		}
+		return floatsWithinRelError(values, decoded, budget), nil
+	})
 	if err != nil {
 		return err
 	}
+	return e.writeEncoded(values, bits, flags, payload)
+}
 
-	var lenBuf [10]byte
-	byteLen := uint64(len(payload))
-	nLen := binary.PutUvarint(lenBuf[:], byteLen)
-
-	header := []byte{byte(bits)}
-	header = append(header, lenBuf[:nLen]...)
-
-	if _, err := e.w.Write(header); err != nil {
-		return err
+// writeEncoded writes the payload built by encodeFloatsChunk for values,
+// adding chunk statistics if enabled.
+func (e *FloatStreamEncoder) writeEncoded(values []float64, bits int, flags uint64, payload []byte) error {
+	if e.ChunkStats {
+		stats, err := chunkStatsFor(values, bits)
+		if err != nil {
+			return err
+		}
+		payload = append(appendChunkStats(nil, stats), payload...)
+		flags |= chunkFlagStats
 	}
-	if _, err := e.w.Write(payload); err != nil {
+	if err := writeChunk(e.w, bits, flags, payload); err != nil {
 		return err
 	}
+	e.ErrorStats.observeFloats(values, bits)
 	return nil
 }
 
+// floatStreamFlags are the chunk flags FloatStreamDecoder understands.
+const floatStreamFlags = chunkFlagEntropy | chunkFlagStats
+
 // FloatStreamDecoder reads chunks of float64 slices from an io.Reader that were
 // written by FloatStreamEncoder.
 type FloatStreamDecoder struct {
 	r *bufio.Reader
+
+	// pos is the stream index of the next value to be read.
+	pos int
+
+	// pending holds the values of a chunk that Aggregate only partly
+	// consumed, encoded with pendingBits.
+	pending     []float64
+	pendingBits int
 }
 
 // NewFloatStreamDecoder creates a FloatStreamDecoder that reads from r.
@@ -227,34 +450,34 @@ func NewFloatStreamDecoder(r io.Reader) *FloatStreamDecoder {
 // ReadChunk reads and decodes the next chunk from the stream, returning the
 // decoded slice, the mantissa bits that were used to encode it, and an error.
 // On EOF without any bytes read, it returns (nil, 0, io.EOF).
+//
+// If a previous Aggregate call stopped inside a chunk, ReadChunk first returns
+// the rest of that chunk.
 func (d *FloatStreamDecoder) ReadChunk() ([]float64, int, error) {
-	bitsByte, err := d.r.ReadByte()
-	if err != nil {
-		return nil, 0, err
+	if len(d.pending) > 0 {
+		values := d.pending
+		d.pending = nil
+		d.pos += len(values)
+		return values, d.pendingBits, nil
 	}
-	bits := int(bitsByte)
-	if bits < 0 || bits > 52 {
-		return nil, 0, errors.New("varfloat: invalid mantissa bits in stream header")
-	}
-
-	byteLen, err := binary.ReadUvarint(d.r)
+	bits, flags, buf, err := readChunk(d.r, floatStreamFlags)
 	if err != nil {
 		return nil, 0, err
 	}
-
-	if byteLen == 0 {
-		return nil, bits, nil
+	if flags&chunkFlagStats != 0 {
+		if _, buf, err = parseChunkStats(buf); err != nil {
+			return nil, 0, err
+		}
 	}
-
-	buf := make([]byte, byteLen)
-	if _, err := io.ReadFull(d.r, buf); err != nil {
-		return nil, 0, err
+	if len(buf) == 0 {
+		return nil, bits, nil
 	}
 
-	values, _, err := DecodeFloats(buf, bits)
+	values, err := decodeFloatsChunk(buf, bits, flags)
 	if err != nil {
 		return nil, 0, err
 	}
+	d.pos += len(values)
 	return values, bits, nil
 }
 
@@ -262,6 +485,21 @@ func (d *FloatStreamDecoder) ReadChunk() ([]float64, int, error) {
 // chunk format as FloatStreamEncoder but with EncodeVec3Slice payloads.
 type Vec3StreamEncoder struct {
 	w io.Writer
+
+	// Entropy enables Huffman-coded payloads, as for FloatStreamEncoder.
+	Entropy bool
+
+	// SharedExponent writes EncodeVec3SliceShared payloads instead. It cannot
+	// be combined with Entropy.
+	SharedExponent bool
+
+	// ErrorStats, if set, records every component written.
+	ErrorStats *ErrorStats
+
+	// MaxRelErr and Verify work as for FloatStreamEncoder, with the error of
+	// a vector measured as for Vec3Encoder.
+	MaxRelErr float64
+	Verify    VerifyMode
 }
 
 // NewVec3StreamEncoder creates a Vec3StreamEncoder that writes to w.
@@ -272,27 +510,40 @@ func NewVec3StreamEncoder(w io.Writer) *Vec3StreamEncoder {
 // WriteChunk encodes a slice of Vec3 values with the given mantissa bits and
 // writes it as a self-contained chunk to the underlying writer.
 func (e *Vec3StreamEncoder) WriteChunk(vs []Vec3, bits int) error {
-	if bits < 0 || bits > 52 {
-		return errors.New("varfloat: mantissa bits must be between 0 and 52")
+	if e.SharedExponent && e.Entropy {
+		return errors.New("varfloat: Entropy and SharedExponent cannot be combined")
+	}
+	if e.Verify == VerifyOff && !e.SharedExponent {
+		return writeVecChunk(e.w, vs, bits, e.Entropy, e.ErrorStats)
 	}
 
-	payload, err := EncodeVec3Slice(vs, bits)
+	budget := verifyBudget(e.MaxRelErr, bits)
+	var flags uint64
+	payload, bits, err := encodeVerified(e.Verify, bits, func(bits int) ([]byte, error) {
+		if e.SharedExponent {
+			flags = chunkFlagSharedExponent
+			return EncodeVec3SliceShared(vs, bits)
+		}
+		payload, f, err := encodeFloatsChunk(vecFlatten(vs), bits, e.Entropy)
+		flags = f
+		return payload, err
+	}, func(b []byte, bits int) (bool, error) {
+		decoded, err := decodeVec3Chunk(b, bits, flags)
+		if err != nil {
+			return false, err
+		}
+		return vec3sWithinRelError(vs, decoded, budget), nil
+	})
 	if err != nil {
 		return err
 	}
-
-	var lenBuf [10]byte
-	byteLen := uint64(len(payload))
-	nLen := binary.PutUvarint(lenBuf[:], byteLen)
-
-	header := []byte{byte(bits)}
-	header = append(header, lenBuf[:nLen]...)
-
-	if _, err := e.w.Write(header); err != nil {
+	if err := writeChunk(e.w, bits, flags, payload); err != nil {
 		return err
 	}
-	if _, err := e.w.Write(payload); err != nil {
-		return err
+	if e.SharedExponent {
+		e.ErrorStats.observeVec3Shared(vs, bits)
+	} else if e.ErrorStats != nil {
+		e.ErrorStats.observeFloats(vecFlatten(vs), bits)
 	}
 	return nil
 }
@@ -312,34 +563,58 @@ func NewVec3StreamDecoder(r io.Reader) *Vec3StreamDecoder {
 // returns the decoded vectors, the mantissa bits that were used to encode them,
 // and an error. On EOF without any bytes read, it returns (nil, 0, io.EOF).
 func (d *Vec3StreamDecoder) ReadChunk() ([]Vec3, int, error) {
-	bitsByte, err := d.r.ReadByte()
+	bits, flags, buf, err := readChunk(d.r, chunkFlagEntropy|chunkFlagSharedExponent)
 	if err != nil {
 		return nil, 0, err
 	}
-	bits := int(bitsByte)
-	if bits < 0 || bits > 52 {
-		return nil, 0, errors.New("varfloat: invalid mantissa bits in stream header")
+	if buf == nil {
+		return nil, bits, nil
 	}
 
-	byteLen, err := binary.ReadUvarint(d.r)
+	vs, err := decodeVec3Chunk(buf, bits, flags)
 	if err != nil {
 		return nil, 0, err
 	}
+	return vs, bits, nil
+}
 
-	if byteLen == 0 {
-		return nil, bits, nil
+// decodeVec3Chunk decodes a Vec3StreamEncoder chunk payload.
+func decodeVec3Chunk(buf []byte, bits int, flags uint64) ([]Vec3, error) {
+	if flags&chunkFlagSharedExponent != 0 {
+		vs, _, err := DecodeVec3SliceShared(buf, bits)
+		return vs, err
 	}
+	flat, err := decodeFloatsChunk(buf, bits, flags)
+	if err != nil {
+		return nil, err
+	}
+	return vecUnflatten[Vec3](flat)
+}
 
-	buf := make([]byte, byteLen)
-	if _, err := io.ReadFull(d.r, buf); err != nil {
-		return nil, 0, err
+// encodeFloatsChunk builds a stream chunk payload for values, returning the
+// chunk flags that describe it.
+func encodeFloatsChunk(values []float64, bits int, entropy bool) ([]byte, uint64, error) {
+	if entropy {
+		payload, err := EncodeFloatsEntropy(values, bits)
+		return payload, chunkFlagEntropy, err
 	}
+	payload, err := EncodeFloats(values, bits)
+	return payload, 0, err
+}
 
-	vs, _, err := DecodeVec3Slice(buf, bits)
-	if err != nil {
-		return nil, 0, err
+// decodeFloatsChunk decodes a stream chunk payload written by
+// encodeFloatsChunk.
+func decodeFloatsChunk(buf []byte, bits int, flags uint64) ([]float64, error) {
+	var (
+		values []float64
+		err    error
+	)
+	if flags&chunkFlagEntropy != 0 {
+		values, _, err = DecodeFloatsEntropy(buf, bits)
+	} else {
+		values, _, err = DecodeFloats(buf, bits)
 	}
-	return vs, bits, nil
+	return values, err
 }
 
 // Append encodes v as a varfloat using DefaultConfig and appends it to dst.
@@ -349,28 +624,85 @@ func Append(dst []byte, v float64) []byte {
 }
 
 // Append encodes v as a varfloat with the receiver configuration and appends it to dst.
-// It returns the extended slice.
+// It returns the extended slice. Infinities and NaN round-trip (NaN payloads
+// are not kept).
 func (c Config) Append(dst []byte, v float64) []byte {
-	// Special case zero: single-byte encoding 0x00
+	header, mant := c.split(v)
+	if c.ErrorStats != nil {
+		q, err := c.join(header, mant)
+		if err == nil {
+			c.ErrorStats.observe(v, q, false)
+		}
+	}
+
+	// Special case zero: single-byte encoding 0x00, or 0x01 for -0.
+	if header <= headerNegZero {
+		return append(dst, byte(header))
+	}
+
+	// Encode header and mant as standard uvarints.
+	var buf [10]byte
+
+	n := binary.PutUvarint(buf[:], header)
+	dst = append(dst, buf[:n]...)
+
+	n = binary.PutUvarint(buf[:], mant)
+	dst = append(dst, buf[:n]...)
+
+	return dst
+}
+
+// Exponents past the float64 range that mark non-finite values. Finite
+// values never have an exponent above 1023, so these do not change the
+// encoding of any finite value.
+const (
+	expInf = 1024
+	expNaN = 1025
+)
+
+// headerNegZero is the header of -0: a set sign bit with no exponent, which
+// no other value uses.
+const headerNegZero = 1
+
+// split quantizes v into the header and mantissa words written by Append.
+// A zero header means v is zero and no mantissa follows; headerNegZero is the
+// same for -0. Infinities and NaN are written with the reserved exponents
+// expInf and expNaN and a zero mantissa.
+func (c Config) split(v float64) (header, mant uint64) {
 	if v == 0 {
-		return append(dst, 0)
+		if math.Signbit(v) {
+			return headerNegZero, 0
+		}
+		return 0, 0
 	}
 
 	sign := 0
-	if v < 0 {
+	if math.Signbit(v) {
 		sign = 1
 		v = -v
 	}
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		e := int64(expInf)
+		if math.IsNaN(v) {
+			e = expNaN
+		}
+		return (zigZagEncode(e)+1)<<1 | uint64(sign), 0
+	}
 
 	m, e := math.Frexp(v) // v = m * 2^e, 0.5 <= m < 1
 	m *= 2
-	e -= 1 // now v = (m/2) * 2^e', with 1 <= m < 2
+	e -= 1 // now v = m * 2^e', with 1 <= m < 2
 
 	// Quantize mantissa in [1, 2) to c.MantissaBits.
-	var mant uint64
 	mantMax := mantMaxForBits(c.MantissaBits)
 	if mantMax > 0 {
 		mant = uint64(math.Round((m - 1.0) * float64(mantMax)))
+		// Rounding up to m' = 2 at the top exponent would decode as 2^1024,
+		// i.e. +Inf; stay on the largest finite grid point instead. The
+		// error is still under one step, within MaxRelErrorForBits.
+		if e == 1023 && mant == uint64(mantMax) {
+			mant--
+		}
 	}
 
 	// ZigZag encode exponent.
@@ -378,19 +710,9 @@ func (c Config) Append(dst []byte, v float64) []byte {
 
 	// Pack header: ((ez + 1) << 1) | sign.
 	// ez+1 ensures header != 0 so 0x00 is reserved for zero.
-	header := (ez + 1) << 1
+	header = (ez + 1) << 1
 	header |= uint64(sign)
-
-	// Encode header and mant as standard uvarints.
-	var buf [10]byte
-
-	n := binary.PutUvarint(buf[:], header)
-	dst = append(dst, buf[:n]...)
-
-	n = binary.PutUvarint(buf[:], mant)
-	dst = append(dst, buf[:n]...)
-
-	return dst
+	return header, mant
 }
 
 // Vec3 represents a simple 3D vector stored as three float64 components.
@@ -417,59 +739,37 @@ func Vec3Normalize(v Vec3) Vec3 {
 	}
 }
 
+func (Vec3) dims() int        { return 3 }
+func (Vec3) typeName() string { return "Vec3" }
+func (v Vec3) appendComponents(dst []float64) []float64 {
+	return append(dst, v.X, v.Y, v.Z)
+}
+func (Vec3) fromComponents(c []float64) Vec3 {
+	return Vec3{X: c[0], Y: c[1], Z: c[2]}
+}
+
 // EncodeVec3 encodes a single 3D vector using the given mantissa bit
 // precision. It is a small convenience wrapper around EncodeFloats.
 func EncodeVec3(v Vec3, bits int) ([]byte, error) {
-	return EncodeFloats([]float64{v.X, v.Y, v.Z}, bits)
+	return encodeVec(v, bits)
 }
 
 // DecodeVec3 decodes a single 3D vector that was encoded with EncodeVec3
 // and the same mantissa bit precision.
 func DecodeVec3(b []byte, bits int) (Vec3, int, error) {
-	values, n, err := DecodeFloats(b, bits)
-	if err != nil {
-		return Vec3{}, 0, err
-	}
-	if len(values) != 3 {
-		return Vec3{}, 0, errors.New("varfloat: expected 3 components for Vec3")
-	}
-	return Vec3{X: values[0], Y: values[1], Z: values[2]}, n, nil
+	return decodeVec[Vec3](b, bits)
 }
 
 // EncodeVec3Slice encodes a slice of 3D vectors with a length prefix,
 // similar to EncodeFloats but grouping values into triples.
 func EncodeVec3Slice(vs []Vec3, bits int) ([]byte, error) {
-	if len(vs) == 0 {
-		return EncodeFloats(nil, bits)
-	}
-	flat := make([]float64, 0, len(vs)*3)
-	for _, v := range vs {
-		flat = append(flat, v.X, v.Y, v.Z)
-	}
-	return EncodeFloats(flat, bits)
+	return encodeVecSlice(vs, bits)
 }
 
 // DecodeVec3Slice decodes a slice of 3D vectors that was encoded with
 // EncodeVec3Slice and the same mantissa bit precision.
 func DecodeVec3Slice(b []byte, bits int) ([]Vec3, int, error) {
-	flat, n, err := DecodeFloats(b, bits)
-	if err != nil {
-		return nil, 0, err
-	}
-	if len(flat)%3 != 0 {
-		return nil, 0, errors.New("varfloat: Vec3 slice encoding length is not a multiple of 3")
-	}
-	count := len(flat) / 3
-	out := make([]Vec3, 0, count)
-	for i := 0; i < count; i++ {
-		base := i * 3
-		out = append(out, Vec3{
-			X: flat[base],
-			Y: flat[base+1],
-			Z: flat[base+2],
-		})
-	}
-	return out, n, nil
+	return decodeVecSlice[Vec3](b, bits)
 }
 
 // EncodeFloatsWithMantissa encodes a slice of float64 values with a 1-byte
@@ -511,35 +811,46 @@ func DecodeFloatsWithMantissa(b []byte) ([]float64, int, int, error) {
 // EncodeVec3SliceWithMantissa encodes a slice of Vec3 values with a 1-byte
 // mantissa-bit header followed by the normal EncodeVec3Slice payload.
 func EncodeVec3SliceWithMantissa(vs []Vec3, bits int) ([]byte, error) {
-	if bits < 0 || bits > 52 {
-		return nil, errors.New("varfloat: mantissa bits must be between 0 and 52")
-	}
-	payload, err := EncodeVec3Slice(vs, bits)
-	if err != nil {
-		return nil, err
-	}
-	out := make([]byte, 0, 1+len(payload))
-	out = append(out, byte(bits))
-	out = append(out, payload...)
-	return out, nil
+	return encodeVecSliceWithMantissa(vs, bits)
 }
 
 // DecodeVec3SliceWithMantissa decodes a slice of Vec3 values that was encoded
-// with EncodeVec3SliceWithMantissa. It returns the decoded vectors, the
-// mantissa bits recovered from the header, and the number of bytes consumed.
+// with EncodeVec3SliceWithMantissa or EncodeVec3SliceSharedWithMantissa. It
+// returns the decoded vectors, the mantissa bits recovered from the header,
+// and the number of bytes consumed.
 func DecodeVec3SliceWithMantissa(b []byte) ([]Vec3, int, int, error) {
 	if len(b) == 0 {
 		return nil, 0, 0, errors.New("varfloat: empty buffer for DecodeVec3SliceWithMantissa")
 	}
-	bits := int(b[0])
+	bits := int(b[0] &^ chunkExtended)
 	if bits < 0 || bits > 52 {
 		return nil, 0, 0, errors.New("varfloat: invalid mantissa bits in header")
 	}
-	vs, n, err := DecodeVec3Slice(b[1:], bits)
+	offset := 1
+	var flags uint64
+	if b[0]&chunkExtended != 0 {
+		var n int
+		flags, n = binary.Uvarint(b[1:])
+		if n <= 0 || flags != chunkFlagSharedExponent {
+			return nil, 0, 0, errors.New("varfloat: unsupported flags in header")
+		}
+		offset += n
+	}
+
+	var (
+		vs  []Vec3
+		n   int
+		err error
+	)
+	if flags&chunkFlagSharedExponent != 0 {
+		vs, n, err = DecodeVec3SliceShared(b[offset:], bits)
+	} else {
+		vs, n, err = DecodeVec3Slice(b[offset:], bits)
+	}
 	if err != nil {
 		return nil, 0, 0, err
 	}
-	return vs, bits, n + 1, nil
+	return vs, bits, n + offset, nil
 }
 
 // EncodeIntsBoundedSlice encodes a slice of integers known to lie in [min,max]
@@ -590,8 +901,13 @@ func DecodeIntsBoundedSlice(b []byte, min, max int64) ([]int64, int, int, error)
 		return nil, 0, 0, errors.New("varfloat: failed to decode length for ints slice")
 	}
 
-	values := make([]int64, 0, length)
 	offset := 1 + nLen
+	// Every value takes at least one byte.
+	if length > uint64(len(b)-offset) {
+		return nil, 0, 0, errors.New("varfloat: slice length exceeds buffer")
+	}
+
+	values := make([]int64, 0, length)
 	for i := uint64(0); i < length; i++ {
 		v, consumed, err := ConsumeIntBounded(b[offset:], min, max, bits)
 		if err != nil {
@@ -617,9 +933,12 @@ func (c Config) Consume(b []byte) (float64, int, error) {
 		return 0, 0, io.ErrUnexpectedEOF
 	}
 
-	// Zero sentinel.
-	if b[0] == 0 {
+	// Zero sentinels.
+	switch b[0] {
+	case 0:
 		return 0, 1, nil
+	case headerNegZero:
+		return math.Copysign(0, -1), 1, nil
 	}
 
 	// Decode header.
@@ -628,19 +947,49 @@ func (c Config) Consume(b []byte) (float64, int, error) {
 		return 0, 0, errors.New("varfloat: invalid header")
 	}
 
+	// Decode mantissa.
+	mant, mlen := binary.Uvarint(b[n:])
+	if mlen <= 0 {
+		return 0, 0, errors.New("varfloat: invalid mantissa")
+	}
+
+	v, err := c.join(header, mant)
+	if err != nil {
+		return 0, 0, err
+	}
+	return v, n + mlen, nil
+}
+
+// join reverses split, reconstructing a value from its header and mantissa
+// words.
+func (c Config) join(header, mant uint64) (float64, error) {
+	if header == 0 {
+		return 0, nil
+	}
+	if header == headerNegZero {
+		return math.Copysign(0, -1), nil
+	}
+
 	sign := int(header & 1)
 	ezPlus1 := header >> 1
 	if ezPlus1 == 0 {
-		return 0, 0, errors.New("varfloat: invalid header")
+		return 0, errors.New("varfloat: invalid header")
 	}
 	ez := ezPlus1 - 1
 
 	e := zigZagDecode(ez) // exponent e'
 
-	// Decode mantissa.
-	mant, mlen := binary.Uvarint(b[n:])
-	if mlen <= 0 {
-		return 0, 0, errors.New("varfloat: invalid mantissa")
+	switch e {
+	case expInf:
+		if sign == 1 {
+			return math.Inf(-1), nil
+		}
+		return math.Inf(1), nil
+	case expNaN:
+		if sign == 1 {
+			return math.Copysign(math.NaN(), -1), nil
+		}
+		return math.NaN(), nil
 	}
 
 	// Reconstruct mantissa m' in [1, 2).
@@ -649,17 +998,15 @@ func (c Config) Consume(b []byte) (float64, int, error) {
 	if mantMax > 0 {
 		mPrime = 1.0 + float64(mant)/float64(mantMax)
 	}
-	// m = m'/2, in [0.5, 1)
-	m := mPrime * 0.5
 
-	// v = m * 2^e'
-	v := math.Ldexp(m, int(e))
+	// v = m' * 2^e' (split already moved the frexp exponent down by one).
+	v := math.Ldexp(mPrime, int(e))
 
 	if sign == 1 {
 		v = -v
 	}
 
-	return v, n + mlen, nil
+	return v, nil
 }
 
 // mantMaxForBits returns (1<<bits)-1, or 0 if bits <= 0.
@@ -708,18 +1055,29 @@ func EncodeFloatSlice(values []float64, bits int) ([]byte, error) {
 		return nil, err
 	}
 
-	var buf []byte
+	return appendFloatSlice(nil, values, cfg), nil
+}
+
+// appendFloatSlice appends the EncodeFloatSlice encoding of values, written
+// with cfg, to dst.
+func appendFloatSlice(dst []byte, values []float64, cfg Config) []byte {
 	// Prefix length.
 	var lenBuf [10]byte
 	n := binary.PutUvarint(lenBuf[:], uint64(len(values)))
-	buf = append(buf, lenBuf[:n]...)
+	dst = append(dst, lenBuf[:n]...)
 
 	for _, v := range values {
-		buf = cfg.Append(buf, v)
+		dst = cfg.Append(dst, v)
 	}
-	return buf, nil
+	return dst
 }
 
+// maxImpliedValues caps the number of values a slice may hold when they take
+// no input bytes to decode, as with a one-entry dictionary, a long run or a
+// flat box. The input cannot bound such counts, so without a fixed cap a few
+// corrupt bytes could ask for billions of values.
+const maxImpliedValues = 1 << 24
+
 // DecodeFloatSlice decodes a slice of float64 values encoded by EncodeFloatSlice
 // using the given mantissa precision (bits).
 //
@@ -738,6 +1096,10 @@ func DecodeFloatSlice(b []byte, bits int) ([]float64, int, error) {
 	}
 	b = b[n:]
 	consumed := n
+	// Every varfloat takes at least one byte.
+	if length > uint64(len(b)) {
+		return nil, 0, errors.New("varfloat: slice length exceeds buffer")
+	}
 
 	values := make([]float64, 0, length)
 	for i := uint64(0); i < length; i++ {
@@ -952,6 +1314,63 @@ func BitsForIntMaxError(min, max, maxAbsErr int64) (int, error) {
 	return bits, nil
 }
 
+// BitsForFloatMaxAbsError chooses mantissa bits so that every float in
+// [min, max] decodes within maxAbsErr of its original value.
+//
+// A varfloat's error is relative to its power-of-two bucket: a value in
+// [2^e, 2^(e+1)) is off by at most 2^e/(2*(2^bits-1)), plus rounding.
+// Values closer to zero have proportionally smaller errors, so the bound is
+// set by the largest magnitude in the range, and unlike a relative error
+// budget it also holds for values near zero. The bits returned are the fewest
+// whose MaxFloatAbsErrorForBits is within maxAbsErr, including for ranges
+// that reach MaxFloat64. It returns an error if even 52 bits are not enough.
+func BitsForFloatMaxAbsError(min, max, maxAbsErr float64) (int, error) {
+	if math.IsNaN(min) || math.IsInf(min, 0) || math.IsNaN(max) || math.IsInf(max, 0) {
+		return 0, errors.New("varfloat: range bounds must be finite")
+	}
+	if min > max {
+		return 0, errors.New("varfloat: min must be <= max")
+	}
+	if !(maxAbsErr > 0) {
+		return 0, errors.New("varfloat: maxAbsErr must be > 0")
+	}
+	for bits := 0; bits <= 52; bits++ {
+		if MaxFloatAbsErrorForBits(min, max, bits) <= maxAbsErr {
+			return bits, nil
+		}
+	}
+	return 0, errors.New("varfloat: maxAbsErr is too small for the range")
+}
+
+// MaxFloatAbsErrorForBits returns the largest absolute error of a varfloat
+// with the given mantissa bits for values in [min, max]:
+//
+//	maxAbsErr = 2^emax / (2*(2^bits-1)) + 2^emax * 2^-52
+//
+// where 2^emax is the power of two bucket of the largest magnitude in the
+// range. The second term covers float64 rounding of the decoded mantissa and
+// only matters near 52 bits. With 0 bits every mantissa decodes as 1, so the
+// bound is 2^emax. Values above the largest finite grid point, within half a
+// step of MaxFloat64, stay on that point rather than round up to +Inf, so
+// for ranges that reach them the first term is a full step.
+func MaxFloatAbsErrorForBits(min, max float64, bits int) float64 {
+	mag := math.Max(math.Abs(min), math.Abs(max))
+	if mag == 0 {
+		return 0
+	}
+	_, exp := math.Frexp(mag)
+	bucket := math.Ldexp(1, exp-1)
+	mantMax := mantMaxForBits(bits)
+	if mantMax == 0 {
+		return bucket
+	}
+	step := bucket / float64(mantMax)
+	if exp-1 == 1023 && mag > bucket+step*float64(mantMax-1) {
+		return step + bucket*0x1p-52
+	}
+	return step/2 + bucket*0x1p-52
+}
+
 // zigZagEncode maps signed integers to unsigned so that small-magnitude
 // negatives get small codes (like protobuf).
 func zigZagEncode(x int64) uint64 {
@@ -0,0 +1,289 @@
+package varfloat
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// TestConsumeRoundTrip guards against the decoder halving every value, which
+// it did until Consume rebuilt m' * 2^e' instead of m'/2 * 2^e'.
+func TestConsumeRoundTrip(t *testing.T) {
+	values := []float64{1, 3, -0.75, 1e-300, 12345.678, -2}
+	for _, bits := range []int{2, 4, 10, 23, 52} {
+		cfg, err := NewConfig(bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		budget := MaxRelErrorForBits(bits)
+		for _, v := range values {
+			b := cfg.Append(nil, v)
+			got, n, err := cfg.Consume(b)
+			if err != nil {
+				t.Fatalf("bits=%d v=%g: %v", bits, v, err)
+			}
+			if n != len(b) {
+				t.Errorf("bits=%d v=%g: consumed %d of %d bytes", bits, v, n, len(b))
+			}
+			if rel := math.Abs(got-v) / math.Abs(v); rel > budget {
+				t.Errorf("bits=%d: %g decoded as %g (relative error %g > %g)", bits, v, got, rel, budget)
+			}
+		}
+	}
+}
+
+func TestConsumeExactValues(t *testing.T) {
+	tests := []struct {
+		v    float64
+		bits int
+	}{
+		{1, 0},
+		{2, 0},
+		{-0.5, 1},
+		{-4, 7},
+		{3, 52},
+		{-0.75, 52},
+		{1e-300, 52},
+		{math.MaxFloat64, 52},
+	}
+	for _, tt := range tests {
+		b, err := EncodeFloat(tt.v, tt.bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _, err := DecodeFloat(b, tt.bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.bits == 52 {
+			// 2^52-1 mantissa steps are not binary fractions, so allow the
+			// last bit to differ.
+			if math.Abs(got-tt.v) > math.Abs(tt.v)*0x1p-52 {
+				t.Errorf("%g at %d bits decoded as %g", tt.v, tt.bits, got)
+			}
+		} else if got != tt.v {
+			t.Errorf("%g at %d bits decoded as %g", tt.v, tt.bits, got)
+		}
+	}
+}
+
+func TestIntBoundedRoundTrip(t *testing.T) {
+	for _, n := range []int64{-1000, -3, 0, 1, 3, 999, 1000} {
+		b, err := AppendIntAuto(nil, n, -1000, 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _, err := ConsumeIntAuto(b, -1000, 1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != n {
+			t.Errorf("AppendIntAuto(%d) decoded as %d", n, got)
+		}
+	}
+}
+
+func TestConsumeTruncated(t *testing.T) {
+	b := Config{MantissaBits: 20}.Append(nil, 3.25)
+	for i := 0; i < len(b); i++ {
+		if _, _, err := (Config{MantissaBits: 20}).Consume(b[:i]); err == nil {
+			t.Errorf("Consume of %d/%d bytes succeeded", i, len(b))
+		}
+	}
+}
+
+func TestDecodeFloatsEmpty(t *testing.T) {
+	b, err := EncodeFloats(nil, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, n, err := DecodeFloats(b, 10)
+	if err != nil || len(got) != 0 || n != len(b) {
+		t.Errorf("DecodeFloats(empty) = %v, %d, %v", got, n, err)
+	}
+}
+
+func TestDecodeFloatsCorrupt(t *testing.T) {
+	b, err := EncodeFloats([]float64{1, -2.5, 0, 1e9}, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTruncated(t, b, func(b []byte) error {
+		_, _, err := DecodeFloats(b, 16)
+		return err
+	})
+	if _, _, err := DecodeFloats([]byte{0xff, 0xff, 0xff, 0xff, 0x0f, 0}, 16); err == nil {
+		t.Error("oversized count accepted")
+	}
+}
+
+// checkTruncated fails if decode accepts any strict prefix of b.
+func checkTruncated(t *testing.T, b []byte, decode func([]byte) error) {
+	t.Helper()
+	for i := 0; i < len(b); i++ {
+		if err := decode(b[:i]); err == nil {
+			t.Errorf("decoding %d of %d bytes succeeded", i, len(b))
+		}
+	}
+}
+
+// sampleFloats returns n deterministic values spanning several orders of
+// magnitude, with both signs.
+func sampleFloats(n int, seed uint64) []float64 {
+	r := rand.New(rand.NewPCG(seed, seed))
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = (r.Float64()*2 - 1) * math.Pow(10, float64(r.IntN(12)-6))
+	}
+	return out
+}
+
+// sameFloat reports whether a and b are the same value, treating NaNs as
+// equal and telling -0 from 0.
+func sameFloat(a, b float64) bool {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && math.IsNaN(b)
+	}
+	return a == b && math.Signbit(a) == math.Signbit(b)
+}
+
+func TestMaxFloatAbsErrorForBits(t *testing.T) {
+	ranges := [][2]float64{{0, 500}, {-500, 20}, {-1e-3, 1e-3}, {1e9, 3e9}, {-7, -6}, {0, 1}, {1.7e308, math.MaxFloat64}, {-math.MaxFloat64, -1e308}}
+	r := rand.New(rand.NewPCG(3, 3))
+	for _, rg := range ranges {
+		for _, bits := range []int{0, 1, 3, 8, 20, 40, 52} {
+			budget := MaxFloatAbsErrorForBits(rg[0], rg[1], bits)
+			c := Config{MantissaBits: bits}
+			for i := 0; i < 2000; i++ {
+				v := rg[0] + r.Float64()*(rg[1]-rg[0])
+				if i < 2 {
+					v = rg[i]
+				}
+				if d := math.Abs(c.quantize(v) - v); d > budget {
+					t.Fatalf("[%g, %g] at %d bits: %g decoded %g off, bound %g", rg[0], rg[1], bits, v, d, budget)
+				}
+			}
+		}
+	}
+	if got := MaxFloatAbsErrorForBits(0, 0, 10); got != 0 {
+		t.Errorf("bound for [0, 0] = %g, want 0", got)
+	}
+}
+
+func TestBitsForFloatMaxAbsError(t *testing.T) {
+	tests := []struct {
+		min, max, maxAbsErr float64
+	}{
+		{0, 500, 0.1},
+		{-500, 500, 1e-6},
+		{0, 1, 0.5},
+		{1e9, 2e9, 1},
+		{-3, 3, 1e-12},
+		{0, math.MaxFloat64, 1e305},
+	}
+	for _, tt := range tests {
+		bits, err := BitsForFloatMaxAbsError(tt.min, tt.max, tt.maxAbsErr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if MaxFloatAbsErrorForBits(tt.min, tt.max, bits) > tt.maxAbsErr {
+			t.Errorf("%+v: %d bits exceed the budget", tt, bits)
+		}
+		if bits > 0 && MaxFloatAbsErrorForBits(tt.min, tt.max, bits-1) <= tt.maxAbsErr {
+			t.Errorf("%+v: %d bits is not the fewest", tt, bits)
+		}
+	}
+
+	for _, tt := range []struct{ min, max, maxAbsErr float64 }{
+		{1, 0, 1},
+		{0, 1, 0},
+		{0, math.Inf(1), 1},
+		{math.NaN(), 1, 1},
+		{0, 1e9, 1e-12},
+	} {
+		if _, err := BitsForFloatMaxAbsError(tt.min, tt.max, tt.maxAbsErr); err == nil {
+			t.Errorf("%+v accepted", tt)
+		}
+	}
+}
+
+func TestAbsErrorEncoder(t *testing.T) {
+	e, err := NewAbsErrorEncoder(-40, 125, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := []float64{-40, -39.99, 0, 0.004, 21.37, 125}
+	for _, v := range values {
+		b, err := e.Encode(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _, err := DecodeFloat(b, e.Bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(got-v) > e.MaxAbsErr {
+			t.Errorf("%g decoded as %g", v, got)
+		}
+	}
+	b, err := e.EncodeSlice(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := DecodeFloats(b, e.Bits)
+	if err != nil || len(got) != len(values) {
+		t.Fatalf("DecodeFloats = %v, %v", got, err)
+	}
+
+	for _, v := range []float64{-40.001, 125.5, math.NaN()} {
+		if _, err := e.Encode(v); err == nil {
+			t.Errorf("%g outside the range accepted", v)
+		}
+		if _, err := e.EncodeSlice([]float64{0, v}); err == nil {
+			t.Errorf("slice with %g outside the range accepted", v)
+		}
+	}
+}
+
+func TestConsumeSpecialValues(t *testing.T) {
+	negZero := math.Copysign(0, -1)
+	for _, bits := range []int{0, 1, 4, 10, 23, 52} {
+		for _, v := range []float64{0, negZero, math.Inf(1), math.Inf(-1), math.NaN()} {
+			b, err := EncodeFloat(v, bits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, n, err := DecodeFloat(b, bits)
+			if err != nil {
+				t.Fatalf("bits=%d v=%g: %v", bits, v, err)
+			}
+			if n != len(b) || !sameFloat(got, v) {
+				t.Errorf("bits=%d: %g decoded as %g from %d of %d bytes", bits, v, got, n, len(b))
+			}
+		}
+		// Values next to MaxFloat64 must not round up to a mantissa that
+		// decodes as Inf.
+		for _, v := range []float64{math.MaxFloat64, -math.MaxFloat64, math.Nextafter(math.MaxFloat64, 0)} {
+			b, err := EncodeFloat(v, bits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _, err := DecodeFloat(b, bits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.IsInf(got, 0) || math.Abs(got-v) > math.Abs(v)*MaxRelErrorForBits(bits) {
+				t.Errorf("bits=%d: %g decoded as %g", bits, v, got)
+			}
+		}
+	}
+
+	// The wire bytes documented under "Format changes".
+	cfg := Config{MantissaBits: 8}
+	if b := cfg.Append(nil, negZero); len(b) != 1 || b[0] != 0x01 {
+		t.Errorf("-0 encoded as % x, want 01", b)
+	}
+	if b := cfg.Append(nil, 0); len(b) != 1 || b[0] != 0x00 {
+		t.Errorf("0 encoded as % x, want 00", b)
+	}
+}
@@ -0,0 +1,186 @@
+package varfloat
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// vector is implemented by the fixed-size vector types (Vec2, Vec3, Vec4, ...)
+// so that the single-value, slice, WithMantissa and stream helpers can share
+// one implementation. Adding a new size only needs these methods plus thin
+// exported wrappers.
+type vector[V any] interface {
+	// dims returns the number of components.
+	dims() int
+	// typeName is used in error messages.
+	typeName() string
+	// appendComponents appends the components to dst in order.
+	appendComponents(dst []float64) []float64
+	// fromComponents builds a vector from exactly dims() components.
+	fromComponents(c []float64) V
+}
+
+// vecLength returns the Euclidean length of v.
+func vecLength[V vector[V]](v V) float64 {
+	var buf [16]float64
+	sum := 0.0
+	for _, c := range v.appendComponents(buf[:0]) {
+		sum += c * c
+	}
+	return math.Sqrt(sum)
+}
+
+// vecNormalize returns a normalized copy of v, or the zero vector if v has
+// zero length.
+func vecNormalize[V vector[V]](v V) V {
+	var buf [16]float64
+	comps := v.appendComponents(buf[:0])
+	n := vecLength(v)
+	if n == 0 {
+		for i := range comps {
+			comps[i] = 0
+		}
+		return v.fromComponents(comps)
+	}
+	for i := range comps {
+		comps[i] /= n
+	}
+	return v.fromComponents(comps)
+}
+
+// encodeVec encodes a single vector as a length-prefixed EncodeFloats payload.
+func encodeVec[V vector[V]](v V, bits int) ([]byte, error) {
+	return EncodeFloats(v.appendComponents(nil), bits)
+}
+
+// decodeVec decodes a single vector written by encodeVec.
+func decodeVec[V vector[V]](b []byte, bits int) (V, int, error) {
+	var zero V
+	values, n, err := DecodeFloats(b, bits)
+	if err != nil {
+		return zero, 0, err
+	}
+	if len(values) != zero.dims() {
+		return zero, 0, fmt.Errorf("varfloat: expected %d components for %s", zero.dims(), zero.typeName())
+	}
+	return zero.fromComponents(values), n, nil
+}
+
+// vecFlatten returns the components of vs as one flat slice.
+func vecFlatten[V vector[V]](vs []V) []float64 {
+	if len(vs) == 0 {
+		return nil
+	}
+	flat := make([]float64, 0, len(vs)*vs[0].dims())
+	for _, v := range vs {
+		flat = v.appendComponents(flat)
+	}
+	return flat
+}
+
+// vecUnflatten groups a flat component slice back into vectors.
+func vecUnflatten[V vector[V]](flat []float64) ([]V, error) {
+	var zero V
+	dims := zero.dims()
+	if len(flat)%dims != 0 {
+		return nil, fmt.Errorf("varfloat: %s slice encoding length is not a multiple of %d", zero.typeName(), dims)
+	}
+	count := len(flat) / dims
+	out := make([]V, 0, count)
+	for i := 0; i < count; i++ {
+		out = append(out, zero.fromComponents(flat[i*dims:(i+1)*dims]))
+	}
+	return out, nil
+}
+
+// encodeVecSlice encodes vectors as a flat EncodeFloats payload.
+func encodeVecSlice[V vector[V]](vs []V, bits int) ([]byte, error) {
+	return EncodeFloats(vecFlatten(vs), bits)
+}
+
+// decodeVecSlice decodes vectors written by encodeVecSlice.
+func decodeVecSlice[V vector[V]](b []byte, bits int) ([]V, int, error) {
+	flat, n, err := DecodeFloats(b, bits)
+	if err != nil {
+		return nil, 0, err
+	}
+	out, err := vecUnflatten[V](flat)
+	if err != nil {
+		return nil, 0, err
+	}
+	return out, n, nil
+}
+
+// encodeVecSliceWithMantissa prefixes an encodeVecSlice payload with a 1-byte
+// mantissa-bit header.
+func encodeVecSliceWithMantissa[V vector[V]](vs []V, bits int) ([]byte, error) {
+	if bits < 0 || bits > 52 {
+		return nil, errors.New("varfloat: mantissa bits must be between 0 and 52")
+	}
+	payload, err := encodeVecSlice(vs, bits)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, 1+len(payload))
+	out = append(out, byte(bits))
+	out = append(out, payload...)
+	return out, nil
+}
+
+// decodeVecSliceWithMantissa decodes a payload written by
+// encodeVecSliceWithMantissa, returning the vectors, the mantissa bits and the
+// number of bytes consumed.
+func decodeVecSliceWithMantissa[V vector[V]](b []byte) ([]V, int, int, error) {
+	if len(b) == 0 {
+		var zero V
+		return nil, 0, 0, fmt.Errorf("varfloat: empty buffer for Decode%sSliceWithMantissa", zero.typeName())
+	}
+	bits := int(b[0])
+	if bits < 0 || bits > 52 {
+		return nil, 0, 0, errors.New("varfloat: invalid mantissa bits in header")
+	}
+	vs, n, err := decodeVecSlice[V](b[1:], bits)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return vs, bits, n + 1, nil
+}
+
+// writeVecChunk writes vectors as a single stream chunk, recording their
+// components in stats if it is non-nil.
+func writeVecChunk[V vector[V]](w io.Writer, vs []V, bits int, entropy bool, stats *ErrorStats) error {
+	flat := vecFlatten(vs)
+	payload, flags, err := encodeFloatsChunk(flat, bits, entropy)
+	if err != nil {
+		return err
+	}
+	if err := writeChunk(w, bits, flags, payload); err != nil {
+		return err
+	}
+	stats.observeFloats(flat, bits)
+	return nil
+}
+
+// readVecChunk reads a chunk written by writeVecChunk.
+func readVecChunk[V vector[V]](r *bufio.Reader) ([]V, int, error) {
+	bits, flags, buf, err := readChunk(r, chunkFlagEntropy)
+	if err != nil {
+		return nil, 0, err
+	}
+	if buf == nil {
+		return nil, bits, nil
+	}
+
+	flat, err := decodeFloatsChunk(buf, bits, flags)
+	if err != nil {
+		return nil, 0, err
+	}
+	vs, err := vecUnflatten[V](flat)
+	if err != nil {
+		return nil, 0, err
+	}
+	return vs, bits, nil
+}
@@ -0,0 +1,136 @@
+package varfloat
+
+import (
+	"bufio"
+	"io"
+)
+
+// Vec2 represents a simple 2D vector stored as two float64 components.
+type Vec2 struct {
+	X, Y float64
+}
+
+func (Vec2) dims() int        { return 2 }
+func (Vec2) typeName() string { return "Vec2" }
+func (v Vec2) appendComponents(dst []float64) []float64 {
+	return append(dst, v.X, v.Y)
+}
+func (Vec2) fromComponents(c []float64) Vec2 {
+	return Vec2{X: c[0], Y: c[1]}
+}
+
+// Vec2Length returns the Euclidean length of v.
+func Vec2Length(v Vec2) float64 {
+	return vecLength(v)
+}
+
+// Vec2Normalize returns a normalized copy of v. If v has zero length, it
+// returns the zero vector.
+func Vec2Normalize(v Vec2) Vec2 {
+	return vecNormalize(v)
+}
+
+// EncodeVec2 encodes a single 2D vector using the given mantissa bit
+// precision. It is a small convenience wrapper around EncodeFloats.
+func EncodeVec2(v Vec2, bits int) ([]byte, error) {
+	return encodeVec(v, bits)
+}
+
+// DecodeVec2 decodes a single 2D vector that was encoded with EncodeVec2
+// and the same mantissa bit precision.
+func DecodeVec2(b []byte, bits int) (Vec2, int, error) {
+	return decodeVec[Vec2](b, bits)
+}
+
+// EncodeVec2Slice encodes a slice of 2D vectors with a length prefix,
+// similar to EncodeFloats but grouping values into pairs.
+func EncodeVec2Slice(vs []Vec2, bits int) ([]byte, error) {
+	return encodeVecSlice(vs, bits)
+}
+
+// DecodeVec2Slice decodes a slice of 2D vectors that was encoded with
+// EncodeVec2Slice and the same mantissa bit precision.
+func DecodeVec2Slice(b []byte, bits int) ([]Vec2, int, error) {
+	return decodeVecSlice[Vec2](b, bits)
+}
+
+// EncodeVec2SliceWithMantissa encodes a slice of Vec2 values with a 1-byte
+// mantissa-bit header followed by the normal EncodeVec2Slice payload.
+func EncodeVec2SliceWithMantissa(vs []Vec2, bits int) ([]byte, error) {
+	return encodeVecSliceWithMantissa(vs, bits)
+}
+
+// DecodeVec2SliceWithMantissa decodes a slice of Vec2 values that was encoded
+// with EncodeVec2SliceWithMantissa. It returns the decoded vectors, the
+// mantissa bits recovered from the header, and the number of bytes consumed.
+func DecodeVec2SliceWithMantissa(b []byte) ([]Vec2, int, int, error) {
+	return decodeVecSliceWithMantissa[Vec2](b)
+}
+
+// Vec2Encoder is a convenience wrapper that holds a chosen mantissa precision
+// and exposes helpers for encoding Vec2 values and slices.
+type Vec2Encoder struct {
+	Bits int
+}
+
+// NewVec2Encoder constructs a Vec2Encoder from a desired maximum relative
+// error on vector magnitudes. It uses BitsForMaxRelError under the hood.
+func NewVec2Encoder(maxRelErr float64) (*Vec2Encoder, error) {
+	bits, err := BitsForMaxRelError(maxRelErr)
+	if err != nil {
+		return nil, err
+	}
+	return &Vec2Encoder{Bits: bits}, nil
+}
+
+// Encode encodes a single Vec2 using the encoder's mantissa bits.
+func (e *Vec2Encoder) Encode(v Vec2) ([]byte, error) {
+	return EncodeVec2(v, e.Bits)
+}
+
+// EncodeSlice encodes a slice of Vec2 values using the encoder's mantissa
+// bits.
+func (e *Vec2Encoder) EncodeSlice(vs []Vec2) ([]byte, error) {
+	return EncodeVec2Slice(vs, e.Bits)
+}
+
+// Vec2StreamEncoder writes chunks of Vec2 slices to an io.Writer using the same
+// chunk format as FloatStreamEncoder but with EncodeVec2Slice payloads.
+type Vec2StreamEncoder struct {
+	w io.Writer
+
+	// Entropy enables Huffman-coded payloads, as for FloatStreamEncoder.
+	Entropy bool
+
+	// ErrorStats, if set, records every component written.
+	ErrorStats *ErrorStats
+}
+
+// NewVec2StreamEncoder creates a Vec2StreamEncoder that writes to w.
+func NewVec2StreamEncoder(w io.Writer) *Vec2StreamEncoder {
+	return &Vec2StreamEncoder{w: w}
+}
+
+// WriteChunk encodes a slice of Vec2 values with the given mantissa bits and
+// writes it as a self-contained chunk to the underlying writer.
+func (e *Vec2StreamEncoder) WriteChunk(vs []Vec2, bits int) error {
+	return writeVecChunk(e.w, vs, bits, e.Entropy, e.ErrorStats)
+}
+
+// Vec2StreamDecoder reads chunks of Vec2 slices from an io.Reader that were
+// written by Vec2StreamEncoder.
+type Vec2StreamDecoder struct {
+	r *bufio.Reader
+}
+
+// NewVec2StreamDecoder creates a Vec2StreamDecoder that reads from r.
+func NewVec2StreamDecoder(r io.Reader) *Vec2StreamDecoder {
+	return &Vec2StreamDecoder{r: bufio.NewReader(r)}
+}
+
+// ReadChunk reads and decodes the next Vec2 slice chunk from the stream. It
+// returns the decoded vectors, the mantissa bits that were used to encode them,
+// and an error. On EOF without any bytes read, it returns (nil, 0, io.EOF).
+func (d *Vec2StreamDecoder) ReadChunk() ([]Vec2, int, error) {
+	return readVecChunk[Vec2](d.r)
+}
@@ -0,0 +1,176 @@
+package varfloat
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// AppendVec3Shared encodes v with a single shared exponent (in the spirit of
+// RGBE/RGB9E5) and appends it to dst.
+//
+// EncodeVec3 writes three independent varfloats, each with its own
+// sign/exponent header. Components of a position or direction usually have
+// similar magnitudes, so here the exponent E of the largest component is
+// stored once and every component is written as a sign bit plus a
+// (bits+1)-bit magnitude scaled to 2^E:
+//
+//	[uvarint zigzag(E)+1][packed 3 x (1+(bits+1))-bit sign/magnitude]
+//
+// The zero vector is a single 0x00 byte. Each component is off by at most
+// 2^(E-bits-1), i.e. the error is relative to the vector's largest component
+// rather than to each component. bits must be in [0, 52] and components must
+// be finite.
+func AppendVec3Shared(dst []byte, v Vec3, bits int) ([]byte, error) {
+	if bits < 0 || bits > 52 {
+		return nil, errors.New("varfloat: mantissa bits must be between 0 and 52")
+	}
+	comps := [3]float64{v.X, v.Y, v.Z}
+	maxAbs := 0.0
+	for _, c := range comps {
+		if math.IsNaN(c) || math.IsInf(c, 0) {
+			return nil, errors.New("varfloat: shared-exponent Vec3 requires finite components")
+		}
+		if a := math.Abs(c); a > maxAbs {
+			maxAbs = a
+		}
+	}
+	if maxAbs == 0 {
+		return append(dst, 0), nil
+	}
+
+	// maxAbs < 2^exp, so scaled magnitudes are <= 2^bits and fit in bits+1 bits.
+	_, exp := math.Frexp(maxAbs)
+
+	var buf [10]byte
+	n := binary.PutUvarint(buf[:], zigZagEncode(int64(exp))+1)
+	dst = append(dst, buf[:n]...)
+
+	var w bitWriter
+	for _, c := range comps {
+		sign := uint64(0)
+		if c < 0 {
+			sign = 1
+		}
+		w.writeBits(sign, 1)
+		w.writeBits(uint64(math.Round(math.Ldexp(math.Abs(c), bits-exp))), bits+1)
+	}
+	return append(dst, w.bytes()...), nil
+}
+
+// quantizeVec3Shared returns the vector AppendVec3Shared encodes v as.
+func quantizeVec3Shared(v Vec3, bits int) Vec3 {
+	maxAbs := math.Max(math.Abs(v.X), math.Max(math.Abs(v.Y), math.Abs(v.Z)))
+	if maxAbs == 0 || math.IsNaN(maxAbs) || math.IsInf(maxAbs, 0) {
+		return v
+	}
+	_, exp := math.Frexp(maxAbs)
+	q := func(c float64) float64 {
+		return math.Copysign(math.Ldexp(math.Round(math.Ldexp(math.Abs(c), bits-exp)), exp-bits), c)
+	}
+	return Vec3{X: q(v.X), Y: q(v.Y), Z: q(v.Z)}
+}
+
+// ConsumeVec3Shared decodes a vector written by AppendVec3Shared from the
+// beginning of b using the same mantissa bits. It returns the decoded vector
+// and the number of bytes consumed.
+func ConsumeVec3Shared(b []byte, bits int) (Vec3, int, error) {
+	if bits < 0 || bits > 52 {
+		return Vec3{}, 0, errors.New("varfloat: mantissa bits must be between 0 and 52")
+	}
+	if len(b) == 0 {
+		return Vec3{}, 0, errors.New("varfloat: empty buffer for ConsumeVec3Shared")
+	}
+	if b[0] == 0 {
+		return Vec3{}, 1, nil
+	}
+
+	ezPlus1, n := binary.Uvarint(b)
+	if n <= 0 {
+		return Vec3{}, 0, errors.New("varfloat: invalid shared exponent")
+	}
+	exp := int(zigZagDecode(ezPlus1 - 1))
+
+	r := bitReader{b: b[n:]}
+	var comps [3]float64
+	for i := range comps {
+		sign, err := r.readBits(1)
+		if err != nil {
+			return Vec3{}, 0, err
+		}
+		mag, err := r.readBits(bits + 1)
+		if err != nil {
+			return Vec3{}, 0, err
+		}
+		c := math.Ldexp(float64(mag), exp-bits)
+		if sign == 1 {
+			c = -c
+		}
+		comps[i] = c
+	}
+	return Vec3{X: comps[0], Y: comps[1], Z: comps[2]}, n + packedLen(3, bits+2), nil
+}
+
+// EncodeVec3SliceShared encodes a slice of vectors with AppendVec3Shared,
+// prefixed with the slice length as a uvarint.
+func EncodeVec3SliceShared(vs []Vec3, bits int) ([]byte, error) {
+	if bits < 0 || bits > 52 {
+		return nil, errors.New("varfloat: mantissa bits must be between 0 and 52")
+	}
+	var buf [10]byte
+	n := binary.PutUvarint(buf[:], uint64(len(vs)))
+	out := append([]byte(nil), buf[:n]...)
+	for _, v := range vs {
+		var err error
+		out, err = AppendVec3Shared(out, v, bits)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// DecodeVec3SliceShared decodes a slice of vectors encoded by
+// EncodeVec3SliceShared using the same mantissa bits.
+func DecodeVec3SliceShared(b []byte, bits int) ([]Vec3, int, error) {
+	if bits < 0 || bits > 52 {
+		return nil, 0, errors.New("varfloat: mantissa bits must be between 0 and 52")
+	}
+	count, n := binary.Uvarint(b)
+	if n <= 0 {
+		return nil, 0, errors.New("varfloat: invalid slice length")
+	}
+	offset := n
+	if count > uint64(len(b)-offset) {
+		return nil, 0, errors.New("varfloat: slice length exceeds buffer")
+	}
+
+	out := make([]Vec3, 0, count)
+	for i := uint64(0); i < count; i++ {
+		v, used, err := ConsumeVec3Shared(b[offset:], bits)
+		if err != nil {
+			return nil, 0, err
+		}
+		out = append(out, v)
+		offset += used
+	}
+	return out, offset, nil
+}
+
+// EncodeVec3SliceSharedWithMantissa is the shared-exponent counterpart of
+// EncodeVec3SliceWithMantissa. The header byte records the mantissa bits and
+// marks the payload as shared-exponent, so DecodeVec3SliceWithMantissa decodes
+// either form.
+func EncodeVec3SliceSharedWithMantissa(vs []Vec3, bits int) ([]byte, error) {
+	payload, err := EncodeVec3SliceShared(vs, bits)
+	if err != nil {
+		return nil, err
+	}
+	var buf [10]byte
+	n := binary.PutUvarint(buf[:], chunkFlagSharedExponent)
+	out := make([]byte, 0, 1+n+len(payload))
+	out = append(out, byte(bits)|chunkExtended)
+	out = append(out, buf[:n]...)
+	out = append(out, payload...)
+	return out, nil
+}
@@ -0,0 +1,107 @@
+package varfloat
+
+import (
+	"bytes"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestVec3SharedErrorBound(t *testing.T) {
+	vs := []Vec3{
+		{},
+		{X: 1},
+		{X: 1, Y: -2, Z: 3},
+		{X: 1e-9, Y: 1e9, Z: -5},
+		{X: -0.25, Y: -0.25, Z: -0.25},
+		{X: math.MaxFloat64 / 2, Y: 1},
+	}
+	for _, bits := range []int{0, 5, 10, 23, 52} {
+		for _, v := range vs {
+			b, err := AppendVec3Shared(nil, v, bits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, n, err := ConsumeVec3Shared(b, bits)
+			if err != nil {
+				t.Fatalf("%v/%d: %v", v, bits, err)
+			}
+			if n != len(b) {
+				t.Errorf("%v/%d: consumed %d of %d bytes", v, bits, n, len(b))
+			}
+			if got != quantizeVec3Shared(v, bits) {
+				t.Errorf("%v/%d: decoded %v, quantizeVec3Shared gives %v", v, bits, got, quantizeVec3Shared(v, bits))
+			}
+			maxAbs := max(math.Abs(v.X), math.Abs(v.Y), math.Abs(v.Z))
+			_, e := math.Frexp(maxAbs)
+			bound := math.Ldexp(1, e-bits-1)
+			for i, d := range []float64{got.X - v.X, got.Y - v.Y, got.Z - v.Z} {
+				if math.Abs(d) > bound {
+					t.Errorf("%v/%d: component %d off by %g > %g", v, bits, i, math.Abs(d), bound)
+				}
+			}
+		}
+	}
+}
+
+func TestVec3SliceShared(t *testing.T) {
+	vs := []Vec3{{X: 1, Y: 2, Z: 3}, {}, {X: -4, Y: 0.5, Z: 100}}
+	for _, tt := range []struct {
+		name string
+		vs   []Vec3
+	}{{"empty", nil}, {"mixed", vs}} {
+		b, err := EncodeVec3SliceSharedWithMantissa(tt.vs, 12)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, bits, n, err := DecodeVec3SliceWithMantissa(b)
+		if err != nil || bits != 12 || n != len(b) || len(got) != len(tt.vs) {
+			t.Fatalf("%s: %v, %d bits, %d of %d bytes, %v", tt.name, got, bits, n, len(b), err)
+		}
+		for i, v := range tt.vs {
+			if got[i] != quantizeVec3Shared(v, 12) {
+				t.Errorf("%s: vector %d decoded as %v", tt.name, i, got[i])
+			}
+		}
+	}
+
+	b, err := EncodeVec3SliceShared(vs, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTruncated(t, b, func(b []byte) error {
+		_, _, err := DecodeVec3SliceShared(b, 12)
+		return err
+	})
+	if _, _, err := DecodeVec3SliceShared([]byte{0xff, 0xff, 0xff, 0xff, 0x0f, 0}, 12); err == nil {
+		t.Error("oversized count accepted")
+	}
+	for _, c := range []float64{math.NaN(), math.Inf(-1)} {
+		if _, err := AppendVec3Shared(nil, Vec3{X: 1, Y: c}, 12); err == nil {
+			t.Errorf("component %g accepted", c)
+		}
+	}
+}
+
+func TestVec3StreamShared(t *testing.T) {
+	vs := []Vec3{{X: 1, Y: 2, Z: 3}, {X: -4, Y: 0.5, Z: 100}}
+	var buf bytes.Buffer
+	enc := NewVec3StreamEncoder(&buf)
+	enc.SharedExponent = true
+	if err := enc.WriteChunk(vs, 9); err != nil {
+		t.Fatal(err)
+	}
+	enc.Entropy = true
+	if err := enc.WriteChunk(vs, 9); err == nil {
+		t.Error("Entropy with SharedExponent accepted")
+	}
+
+	got, bits, err := NewVec3StreamDecoder(&buf).ReadChunk()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Vec3{quantizeVec3Shared(vs[0], 9), quantizeVec3Shared(vs[1], 9)}
+	if bits != 9 || !slices.Equal(got, want) {
+		t.Errorf("got %v at %d bits, want %v", got, bits, want)
+	}
+}
@@ -0,0 +1,137 @@
+package varfloat
+
+import (
+	"bufio"
+	"io"
+)
+
+// Vec4 represents a simple 4D vector stored as four float64 components, such
+// as RGBA colors or tangents with a handedness sign in W.
+type Vec4 struct {
+	X, Y, Z, W float64
+}
+
+func (Vec4) dims() int        { return 4 }
+func (Vec4) typeName() string { return "Vec4" }
+func (v Vec4) appendComponents(dst []float64) []float64 {
+	return append(dst, v.X, v.Y, v.Z, v.W)
+}
+func (Vec4) fromComponents(c []float64) Vec4 {
+	return Vec4{X: c[0], Y: c[1], Z: c[2], W: c[3]}
+}
+
+// Vec4Length returns the Euclidean length of v.
+func Vec4Length(v Vec4) float64 {
+	return vecLength(v)
+}
+
+// Vec4Normalize returns a normalized copy of v. If v has zero length, it
+// returns the zero vector.
+func Vec4Normalize(v Vec4) Vec4 {
+	return vecNormalize(v)
+}
+
+// EncodeVec4 encodes a single 4D vector using the given mantissa bit
+// precision. It is a small convenience wrapper around EncodeFloats.
+func EncodeVec4(v Vec4, bits int) ([]byte, error) {
+	return encodeVec(v, bits)
+}
+
+// DecodeVec4 decodes a single 4D vector that was encoded with EncodeVec4
+// and the same mantissa bit precision.
+func DecodeVec4(b []byte, bits int) (Vec4, int, error) {
+	return decodeVec[Vec4](b, bits)
+}
+
+// EncodeVec4Slice encodes a slice of 4D vectors with a length prefix,
+// similar to EncodeFloats but grouping values into quadruples.
+func EncodeVec4Slice(vs []Vec4, bits int) ([]byte, error) {
+	return encodeVecSlice(vs, bits)
+}
+
+// DecodeVec4Slice decodes a slice of 4D vectors that was encoded with
+// EncodeVec4Slice and the same mantissa bit precision.
+func DecodeVec4Slice(b []byte, bits int) ([]Vec4, int, error) {
+	return decodeVecSlice[Vec4](b, bits)
+}
+
+// EncodeVec4SliceWithMantissa encodes a slice of Vec4 values with a 1-byte
+// mantissa-bit header followed by the normal EncodeVec4Slice payload.
+func EncodeVec4SliceWithMantissa(vs []Vec4, bits int) ([]byte, error) {
+	return encodeVecSliceWithMantissa(vs, bits)
+}
+
+// DecodeVec4SliceWithMantissa decodes a slice of Vec4 values that was encoded
+// with EncodeVec4SliceWithMantissa. It returns the decoded vectors, the
+// mantissa bits recovered from the header, and the number of bytes consumed.
+func DecodeVec4SliceWithMantissa(b []byte) ([]Vec4, int, int, error) {
+	return decodeVecSliceWithMantissa[Vec4](b)
+}
+
+// Vec4Encoder is a convenience wrapper that holds a chosen mantissa precision
+// and exposes helpers for encoding Vec4 values and slices.
+type Vec4Encoder struct {
+	Bits int
+}
+
+// NewVec4Encoder constructs a Vec4Encoder from a desired maximum relative
+// error on vector magnitudes. It uses BitsForMaxRelError under the hood.
+func NewVec4Encoder(maxRelErr float64) (*Vec4Encoder, error) {
+	bits, err := BitsForMaxRelError(maxRelErr)
+	if err != nil {
+		return nil, err
+	}
+	return &Vec4Encoder{Bits: bits}, nil
+}
+
+// Encode encodes a single Vec4 using the encoder's mantissa bits.
+func (e *Vec4Encoder) Encode(v Vec4) ([]byte, error) {
+	return EncodeVec4(v, e.Bits)
+}
+
+// EncodeSlice encodes a slice of Vec4 values using the encoder's mantissa
+// bits.
+func (e *Vec4Encoder) EncodeSlice(vs []Vec4) ([]byte, error) {
+	return EncodeVec4Slice(vs, e.Bits)
+}
+
+// Vec4StreamEncoder writes chunks of Vec4 slices to an io.Writer using the same
+// chunk format as FloatStreamEncoder but with EncodeVec4Slice payloads.
+type Vec4StreamEncoder struct {
+	w io.Writer
+
+	// Entropy enables Huffman-coded payloads, as for FloatStreamEncoder.
+	Entropy bool
+
+	// ErrorStats, if set, records every component written.
+	ErrorStats *ErrorStats
+}
+
+// NewVec4StreamEncoder creates a Vec4StreamEncoder that writes to w.
+func NewVec4StreamEncoder(w io.Writer) *Vec4StreamEncoder {
+	return &Vec4StreamEncoder{w: w}
+}
+
+// WriteChunk encodes a slice of Vec4 values with the given mantissa bits and
+// writes it as a self-contained chunk to the underlying writer.
+func (e *Vec4StreamEncoder) WriteChunk(vs []Vec4, bits int) error {
+	return writeVecChunk(e.w, vs, bits, e.Entropy, e.ErrorStats)
+}
+
+// Vec4StreamDecoder reads chunks of Vec4 slices from an io.Reader that were
+// written by Vec4StreamEncoder.
+type Vec4StreamDecoder struct {
+	r *bufio.Reader
+}
+
+// NewVec4StreamDecoder creates a Vec4StreamDecoder that reads from r.
+func NewVec4StreamDecoder(r io.Reader) *Vec4StreamDecoder {
+	return &Vec4StreamDecoder{r: bufio.NewReader(r)}
+}
+
+// ReadChunk reads and decodes the next Vec4 slice chunk from the stream. It
+// returns the decoded vectors, the mantissa bits that were used to encode them,
+// and an error. On EOF without any bytes read, it returns (nil, 0, io.EOF).
+func (d *Vec4StreamDecoder) ReadChunk() ([]Vec4, int, error) {
+	return readVecChunk[Vec4](d.r)
+}
@@ -0,0 +1,132 @@
+package varfloat
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+// checkVecRelError fails if any component of got is further from want than
+// the relative error bound for bits.
+func checkVecRelError(t *testing.T, want, got []float64, bits int) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d components, want %d", len(got), len(want))
+	}
+	bound := MaxRelErrorForBits(bits)
+	for i, w := range want {
+		if math.Abs(got[i]-w) > bound*math.Abs(w) {
+			t.Errorf("component %d: %g decoded as %g at %d bits", i, w, got[i], bits)
+		}
+	}
+}
+
+func TestVec2Vec4RoundTrip(t *testing.T) {
+	v2s := []Vec2{{}, {X: 1, Y: -2}, {X: 1e-200, Y: 3e200}}
+	v4s := []Vec4{{}, {X: 1, Y: -2, Z: 0.5, W: 1e10}, {X: -1e-3, Y: 7, Z: 0, W: -0.1}}
+	for _, bits := range []int{2, 10, 30, 52} {
+		b, err := EncodeVec2Slice(v2s, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got2, n, err := DecodeVec2Slice(b, bits)
+		if err != nil || n != len(b) {
+			t.Fatalf("Vec2: %d of %d bytes, %v", n, len(b), err)
+		}
+		checkVecRelError(t, vecFlatten(v2s), vecFlatten(got2), bits)
+
+		b, err = EncodeVec4Slice(v4s, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got4, n, err := DecodeVec4Slice(b, bits)
+		if err != nil || n != len(b) {
+			t.Fatalf("Vec4: %d of %d bytes, %v", n, len(b), err)
+		}
+		checkVecRelError(t, vecFlatten(v4s), vecFlatten(got4), bits)
+
+		for _, v := range v4s {
+			b, err := EncodeVec4(v, bits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _, err := DecodeVec4(b, bits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkVecRelError(t, vecFlatten([]Vec4{v}), vecFlatten([]Vec4{got}), bits)
+		}
+	}
+}
+
+func TestVecWithMantissa(t *testing.T) {
+	vs := []Vec2{{X: 3, Y: 4}, {X: -1, Y: 0.25}}
+	b, err := EncodeVec2SliceWithMantissa(vs, 14)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, bits, n, err := DecodeVec2SliceWithMantissa(b)
+	if err != nil || bits != 14 || n != len(b) {
+		t.Fatalf("%d bits, %d of %d bytes, %v", bits, n, len(b), err)
+	}
+	checkVecRelError(t, vecFlatten(vs), vecFlatten(got), 14)
+
+	// Four components are one Vec4 but not a whole number of Vec3s.
+	if _, _, _, err := DecodeVec3SliceWithMantissa(b); err == nil {
+		t.Error("Vec2 payload decoded as Vec3")
+	}
+	if _, _, _, err := DecodeVec4SliceWithMantissa(nil); err == nil {
+		t.Error("empty buffer accepted")
+	}
+	if _, _, err := DecodeVec2(b[1:], 14); err == nil {
+		t.Error("four components decoded as one Vec2")
+	}
+}
+
+func TestVecStreams(t *testing.T) {
+	for _, entropy := range []bool{false, true} {
+		var buf bytes.Buffer
+		stats := &ErrorStats{}
+		enc := NewVec4StreamEncoder(&buf)
+		enc.Entropy = entropy
+		enc.ErrorStats = stats
+		vs := []Vec4{{X: 1, Y: 2, Z: 3, W: 4}, {X: -5, Y: 6e5, Z: 7e-5, W: 0}}
+		if err := enc.WriteChunk(vs, 12); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.WriteChunk(nil, 12); err != nil {
+			t.Fatal(err)
+		}
+
+		dec := NewVec4StreamDecoder(&buf)
+		got, bits, err := dec.ReadChunk()
+		if err != nil || bits != 12 {
+			t.Fatalf("entropy=%v: %d bits, %v", entropy, bits, err)
+		}
+		checkVecRelError(t, vecFlatten(vs), vecFlatten(got), 12)
+		if got, _, err := dec.ReadChunk(); err != nil || len(got) != 0 {
+			t.Errorf("entropy=%v: empty chunk decoded as %v, %v", entropy, got, err)
+		}
+		if r := stats.Report(); r.Count != 8 || r.MaxRelError > MaxRelErrorForBits(12) {
+			t.Errorf("entropy=%v: stats %+v", entropy, r)
+		}
+	}
+}
+
+func TestVecEncoders(t *testing.T) {
+	e2, err := NewVec2Encoder(0.001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := e2.EncodeSlice([]Vec2{{X: 1, Y: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := DecodeVec2Slice(b, e2.Bits)
+	if err != nil || len(got) != 1 || math.Abs(got[0].Y-2) > 0.002 {
+		t.Errorf("Vec2Encoder round trip: %v, %v", got, err)
+	}
+	if _, err := NewVec4Encoder(0); err == nil {
+		t.Error("NewVec4Encoder(0) accepted")
+	}
+}
@@ -0,0 +1,134 @@
+package varfloat
+
+import (
+	"errors"
+	"math"
+)
+
+// VerifyMode selects how FloatEncoder, Vec3Encoder and the matching stream
+// encoders enforce their error budget.
+//
+// BitsForMaxRelError picks bits from an estimate; with verification on, the
+// encoder decodes every value it has just encoded and compares it against the
+// original, so the budget becomes a guarantee rather than an estimate.
+type VerifyMode uint8
+
+const (
+	// VerifyOff encodes without checking (the default).
+	VerifyOff VerifyMode = iota
+	// VerifyError fails the encode if any value misses the budget.
+	VerifyError
+	// VerifyEscalate re-encodes a chunk with one more mantissa bit at a time
+	// until every value meets the budget, and writes the chunk with the bits
+	// it settled on. It fails like VerifyError if 52 bits are not enough.
+	//
+	// Only FloatStreamEncoder and Vec3StreamEncoder support it, since their
+	// chunk headers tell the decoder which bits were used. FloatEncoder and
+	// Vec3Encoder return an error.
+	VerifyEscalate
+)
+
+// errVerifyEscalate is returned by encoders whose output cannot record
+// escalated bits.
+var errVerifyEscalate = errors.New("varfloat: VerifyEscalate requires a stream encoder")
+
+// encodeVerified calls encode with bits and, unless mode is VerifyOff, checks
+// the result with ok, escalating bits as mode allows. It returns the encoding
+// that passed and the bits it used.
+func encodeVerified(mode VerifyMode, bits int, encode func(bits int) ([]byte, error), ok func(b []byte, bits int) (bool, error)) ([]byte, int, error) {
+	for {
+		out, err := encode(bits)
+		if err != nil || mode == VerifyOff {
+			return out, bits, err
+		}
+		pass, err := ok(out, bits)
+		if err != nil {
+			return nil, 0, err
+		}
+		if pass {
+			return out, bits, nil
+		}
+		if mode != VerifyEscalate || bits >= 52 {
+			return nil, 0, errors.New("varfloat: encoded value exceeds the error budget")
+		}
+		bits++
+	}
+}
+
+// verifyBudget returns the relative error an encoder promises: maxRelErr if
+// set, else MaxRelErrorForBits(bits).
+func verifyBudget(maxRelErr float64, bits int) float64 {
+	if maxRelErr > 0 {
+		return maxRelErr
+	}
+	return MaxRelErrorForBits(bits)
+}
+
+// floatsWithinRelError reports whether every decoded value is within the
+// relative budget of its original. Infinities and NaNs must decode to
+// themselves.
+func floatsWithinRelError(values, decoded []float64, budget float64) bool {
+	for i, v := range values {
+		if isNonFinite(v) {
+			if !sameNonFinite(v, decoded[i]) {
+				return false
+			}
+			continue
+		}
+		if !withinRelError(math.Abs(decoded[i]-v), math.Abs(v), budget) {
+			return false
+		}
+	}
+	return true
+}
+
+// vec3sWithinRelError reports whether every decoded vector is within the
+// relative budget of its original, measured as |decoded-original| /
+// |original| over the finite components. Infinite and NaN components must
+// decode to themselves.
+func vec3sWithinRelError(vs, decoded []Vec3, budget float64) bool {
+	for i, v := range vs {
+		want := [3]float64{v.X, v.Y, v.Z}
+		got := [3]float64{decoded[i].X, decoded[i].Y, decoded[i].Z}
+		var diff, finite [3]float64
+		for k, c := range want {
+			if isNonFinite(c) {
+				if !sameNonFinite(c, got[k]) {
+					return false
+				}
+				continue
+			}
+			diff[k], finite[k] = got[k]-c, c
+		}
+		errAbs := Vec3Length(Vec3{X: diff[0], Y: diff[1], Z: diff[2]})
+		mag := Vec3Length(Vec3{X: finite[0], Y: finite[1], Z: finite[2]})
+		if !withinRelError(errAbs, mag, budget) {
+			return false
+		}
+	}
+	return true
+}
+
+// withinRelError reports whether an error of errAbs on a finite value of
+// magnitude mag is within the relative budget. Zero must decode exactly, and
+// NaN errors (from a finite value decoding as Inf or NaN) never pass.
+func withinRelError(errAbs, mag, budget float64) bool {
+	if mag == 0 {
+		return errAbs == 0
+	}
+	return errAbs <= budget*mag
+}
+
+// isNonFinite reports whether v is an infinity or NaN.
+func isNonFinite(v float64) bool {
+	return math.IsInf(v, 0) || math.IsNaN(v)
+}
+
+// sameNonFinite reports whether got is the same infinity as want, or a NaN
+// when want is a NaN.
+func sameNonFinite(want, got float64) bool {
+	if math.IsNaN(want) {
+		return math.IsNaN(got)
+	}
+	return got == want
+}
@@ -0,0 +1,202 @@
+package varfloat
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestFloatEncoderVerify(t *testing.T) {
+	values := append(sampleFloats(100, 41), 0, -1)
+
+	// BitsForMaxRelError's choice meets its own budget.
+	e, err := NewFloatEncoder(1e-3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Verify = VerifyError
+	b, err := e.EncodeSlice(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := DecodeFloats(b, e.Bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range values {
+		if math.Abs(got[i]-v) > 1e-3*math.Abs(v) {
+			t.Errorf("%g decoded as %g", v, got[i])
+		}
+	}
+
+	// Too few bits for the budget fails rather than writing a bad value.
+	tight := FloatEncoder{Bits: 4, MaxRelErr: 1e-6, Verify: VerifyError}
+	if _, err := tight.Encode(1.1); err == nil {
+		t.Error("encode over budget succeeded")
+	}
+	if _, err := tight.EncodeSlice(values); err == nil {
+		t.Error("slice over budget succeeded")
+	}
+	tight.Verify = VerifyOff
+	if _, err := tight.Encode(1.1); err != nil {
+		t.Errorf("VerifyOff: %v", err)
+	}
+}
+
+func TestVerifyEscalateNeedsStream(t *testing.T) {
+	fe := FloatEncoder{Bits: 4, MaxRelErr: 1e-6, Verify: VerifyEscalate}
+	if _, err := fe.Encode(1.1); err == nil {
+		t.Error("FloatEncoder escalated")
+	}
+	ve := Vec3Encoder{Bits: 4, MaxRelErr: 1e-6, Verify: VerifyEscalate}
+	if _, err := ve.EncodeSlice([]Vec3{{X: 1.1}}); err == nil {
+		t.Error("Vec3Encoder escalated")
+	}
+	if fe.Bits != 4 || ve.Bits != 4 {
+		t.Errorf("encoders modified: bits %d and %d", fe.Bits, ve.Bits)
+	}
+}
+
+func TestFloatStreamVerifyEscalate(t *testing.T) {
+	values := sampleFloats(50, 42)
+	maxBits, err := BitsForMaxRelError(1e-6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entropy := range []bool{false, true} {
+		var buf bytes.Buffer
+		enc := NewFloatStreamEncoder(&buf)
+		enc.Entropy = entropy
+		enc.MaxRelErr = 1e-6
+		enc.Verify = VerifyEscalate
+		if err := enc.WriteChunk(values, 4); err != nil {
+			t.Fatal(err)
+		}
+		// An empty chunk has nothing to escalate.
+		if err := enc.WriteChunk(nil, 4); err != nil {
+			t.Fatal(err)
+		}
+
+		dec := NewFloatStreamDecoder(&buf)
+		got, bits, err := dec.ReadChunk()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bits <= 4 || bits > maxBits {
+			t.Errorf("entropy=%v: chunk written with %d bits", entropy, bits)
+		}
+		for i, v := range values {
+			if math.Abs(got[i]-v) > 1e-6*math.Abs(v) {
+				t.Errorf("entropy=%v: %g decoded as %g", entropy, v, got[i])
+			}
+		}
+		if _, bits, err := dec.ReadChunk(); err != nil || bits != 4 {
+			t.Errorf("empty chunk: bits %d, %v", bits, err)
+		}
+	}
+
+	// A budget no mantissa can meet fails after 52 bits.
+	enc := NewFloatStreamEncoder(io.Discard)
+	enc.MaxRelErr = 1e-300
+	enc.Verify = VerifyEscalate
+	if err := enc.WriteChunk(values, 10); err == nil {
+		t.Error("impossible budget met")
+	}
+
+	// The default budget follows the chunk's bits.
+	enc.MaxRelErr = 0
+	enc.Verify = VerifyError
+	if err := enc.WriteChunk(values, 10); err != nil {
+		t.Errorf("default budget: %v", err)
+	}
+}
+
+func TestVec3StreamVerifyEscalate(t *testing.T) {
+	vs := []Vec3{{X: 1.1, Y: -2.3, Z: 1e-4}, {X: 300, Y: 0.5, Z: 7}, {}}
+	for _, shared := range []bool{false, true} {
+		var buf bytes.Buffer
+		enc := NewVec3StreamEncoder(&buf)
+		enc.SharedExponent = shared
+		enc.MaxRelErr = 1e-5
+		enc.Verify = VerifyEscalate
+		if err := enc.WriteChunk(vs, 3); err != nil {
+			t.Fatal(err)
+		}
+		got, bits, err := NewVec3StreamDecoder(&buf).ReadChunk()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bits <= 3 {
+			t.Errorf("shared=%v: chunk written with %d bits", shared, bits)
+		}
+		if !vec3sWithinRelError(vs, got, 1e-5) {
+			t.Errorf("shared=%v: %v decoded as %v", shared, vs, got)
+		}
+	}
+
+	enc := NewVec3StreamEncoder(io.Discard)
+	enc.MaxRelErr = 1e-9
+	enc.Verify = VerifyError
+	if err := enc.WriteChunk(vs, 8); err == nil {
+		t.Error("VerifyError passed a chunk over budget")
+	}
+}
+
+func TestFloatEncoderVerifyConcurrent(t *testing.T) {
+	e := FloatEncoder{Bits: 20, MaxRelErr: 1e-5, Verify: VerifyError}
+	values := sampleFloats(200, 43)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := e.EncodeSlice(values); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestVerifyNonFinite(t *testing.T) {
+	// Inf and NaN round-trip exactly, so they must not force escalation.
+	values := []float64{1, math.Inf(1), math.Inf(-1), math.NaN(), math.Copysign(0, -1), 2}
+	var buf bytes.Buffer
+	enc := NewFloatStreamEncoder(&buf)
+	enc.MaxRelErr = 1e-2
+	enc.Verify = VerifyEscalate
+	if err := enc.WriteChunk(values, 8); err != nil {
+		t.Fatal(err)
+	}
+	got, bits, err := NewFloatStreamDecoder(&buf).ReadChunk()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bits != 8 {
+		t.Errorf("escalated to %d bits", bits)
+	}
+	for i, v := range values {
+		if !sameFloat(got[i], v) {
+			t.Errorf("%g decoded as %g", v, got[i])
+		}
+	}
+
+	vs := []Vec3{{X: math.Inf(1), Y: 1.5, Z: -2}, {X: math.NaN(), Y: 0, Z: 3}}
+	if !vec3sWithinRelError(vs, vs, 0) {
+		t.Error("exact vectors with non-finite components rejected")
+	}
+	for _, bad := range [][]Vec3{
+		{{X: math.Inf(-1), Y: 1.5, Z: -2}, vs[1]},
+		{vs[0], {X: 7, Y: 0, Z: 3}},
+		{{X: math.Inf(1), Y: 1.6, Z: -2}, vs[1]},
+	} {
+		if vec3sWithinRelError(vs, bad, 1e-3) {
+			t.Errorf("%v accepted as %v", bad, vs)
+		}
+	}
+	if floatsWithinRelError([]float64{math.MaxFloat64}, []float64{math.Inf(1)}, 0.5) {
+		t.Error("finite value decoded as Inf accepted")
+	}
+}